@@ -29,8 +29,10 @@ import (
 	"archive/tar"
 	"bufio"
 	"bytes"
+	"crypto"
 	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -38,6 +40,7 @@ import (
 	"io"
 	"net/mail"
 	"os"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"text/template"
@@ -46,7 +49,10 @@ import (
 	"github.com/goreleaser/nfpm/v2"
 	"github.com/goreleaser/nfpm/v2/files"
 	"github.com/goreleaser/nfpm/v2/internal/maps"
+	"github.com/goreleaser/nfpm/v2/internal/modtime"
+	"github.com/goreleaser/nfpm/v2/internal/shell"
 	"github.com/goreleaser/nfpm/v2/internal/sign"
+	"github.com/goreleaser/nfpm/v2/internal/systemd"
 	gzip "github.com/klauspost/pgzip"
 )
 
@@ -104,9 +110,14 @@ func (*Apk) Package(info *nfpm.Info, apk io.Writer) (err error) {
 	}
 	info = ensureValidArch(info)
 
+	info = nfpm.WithCopyrightIfRequested(info, fmt.Sprintf("/usr/share/licenses/%s/copyright", info.Name))
 	if err := nfpm.PrepareForPackager(info, packagerName); err != nil {
 		return err
 	}
+	nfpm.WarnUnsupportedFields(info, packagerName, "conflicts", "recommends", "suggests")
+	if _, err := signatureDigest(info); err != nil {
+		return err
+	}
 
 	var bufData bytes.Buffer
 
@@ -124,7 +135,7 @@ func (*Apk) Package(info *nfpm.Info, apk io.Writer) (err error) {
 		return err
 	}
 
-	if info.APK.Signature.KeyFile == "" && info.APK.Signature.SignFn == nil {
+	if !info.APK.Signature.IsSet() {
 		return combineToApk(apk, &bufControl, &bufData)
 	}
 
@@ -235,16 +246,20 @@ func createData(dataTgz io.Writer, info *nfpm.Info, sizep *int64) ([]byte, error
 }
 
 func createControl(controlTgz io.Writer, info *nfpm.Info, size int64, dataDigest []byte) ([]byte, error) {
+	algorithm, err := signatureDigest(info)
+	if err != nil {
+		return nil, err
+	}
 	builderControl := createBuilderControl(info, size, dataDigest)
-	controlDigest, err := writeTgz(controlTgz, tarCut, builderControl, sha1.New()) // nolint:gosec
+	controlDigest, err := writeTgz(controlTgz, tarCut, builderControl, algorithm.newHash())
 	if err != nil {
 		return nil, err
 	}
 	return controlDigest, nil
 }
 
-func createSignature(signatureTgz io.Writer, info *nfpm.Info, controlSHA1Digest []byte) error {
-	signatureBuilder := createSignatureBuilder(controlSHA1Digest, info)
+func createSignature(signatureTgz io.Writer, info *nfpm.Info, controlDigest []byte) error {
+	signatureBuilder := createSignatureBuilder(controlDigest, info)
 	// we don't actually need to produce a digest here, but writeTgz
 	// requires it so we just use SHA1 since it is already imported
 	_, err := writeTgz(signatureTgz, tarCut, signatureBuilder, sha1.New()) // nolint:gosec
@@ -257,48 +272,103 @@ func createSignature(signatureTgz io.Writer, info *nfpm.Info, controlSHA1Digest
 
 var errNoKeyAddress = errors.New("key name not set and maintainer mail address empty")
 
+// digestAlgorithm is a hash apk can sign the control tgz with, and the prefix
+// of the name of its signature.
+type digestAlgorithm struct {
+	hash    crypto.Hash
+	newHash func() hash.Hash
+	prefix  string
+}
+
+// nolint: gochecknoglobals
+var digestAlgorithms = map[string]digestAlgorithm{
+	"sha1":   {crypto.SHA1, sha1.New, ".SIGN.RSA."},
+	"sha256": {crypto.SHA256, sha256.New, ".SIGN.RSA256."},
+	"sha512": {crypto.SHA512, sha512.New, ".SIGN.RSA512."},
+}
+
+// signatureDigest returns the digest algorithm set in apk.signature.digest,
+// which defaults to sha1 as older apk versions only support it.
+func signatureDigest(info *nfpm.Info) (digestAlgorithm, error) {
+	name := info.APK.Signature.Digest
+	if name == "" {
+		name = "sha1"
+	}
+	algorithm, ok := digestAlgorithms[name]
+	if !ok {
+		return digestAlgorithm{}, fmt.Errorf("invalid apk signature digest: %s", name)
+	}
+	return algorithm, nil
+}
+
 func createSignatureBuilder(digest []byte, info *nfpm.Info) func(*tar.Writer) error {
 	return func(tw *tar.Writer) error {
-		var signature []byte
-		var err error
-		if signFn := info.APK.Signature.SignFn; signFn != nil {
-			signature, err = signFn(bytes.NewReader(digest))
-		} else {
-			signature, err = sign.RSASignSHA1Digest(digest,
-				info.APK.Signature.KeyFile, info.APK.Signature.KeyPassphrase)
-		}
+		algorithm, err := signatureDigest(info)
 		if err != nil {
 			return err
 		}
 
-		// needs to exist on the machine during installation: /etc/apk/keys/<keyname>.rsa.pub
-		keyname := info.APK.Signature.KeyName
-		if keyname == "" {
-			addr, err := mail.ParseAddress(info.Maintainer)
+		keys := make([]nfpm.APKSignatureKey, 0, len(info.APK.Signature.Keys)+1)
+		if info.APK.Signature.PackageSignature.IsSet() {
+			keys = append(keys, nfpm.APKSignatureKey{
+				PackageSignature: info.APK.Signature.PackageSignature,
+				KeyName:          info.APK.Signature.KeyName,
+			})
+		}
+		keys = append(keys, info.APK.Signature.Keys...)
+
+		written := map[string]bool{}
+		for _, key := range keys {
+			keyname, err := signatureKeyName(info, key.KeyName)
+			if err != nil {
+				return err
+			}
+			if written[keyname] {
+				return fmt.Errorf("more than one signing key named %s", keyname)
+			}
+			written[keyname] = true
+
+			var signature []byte
+			if signFn := sign.SignFn(key.PackageSignature, sign.RSADigest); signFn != nil {
+				signature, err = signFn(bytes.NewReader(digest))
+			} else {
+				signature, err = sign.RSASignDigest(digest, algorithm.hash, key.KeyFile, key.KeyPassphrase)
+			}
 			if err != nil {
-				return fmt.Errorf("key name not set and unable to parse maintainer mail address: %w", err)
-			} else if addr.Address == "" {
-				return errNoKeyAddress
+				return err
 			}
 
-			keyname = addr.Address
-		}
-		if !strings.HasSuffix(keyname, ".rsa.pub") {
-			keyname += ".rsa.pub"
+			signHeader := &tar.Header{
+				Name: algorithm.prefix + keyname,
+				Mode: 0o600,
+				Size: int64(len(signature)),
+			}
+			if err := writeFile(tw, signHeader, bytes.NewReader(signature)); err != nil {
+				return err
+			}
 		}
+		return nil
+	}
+}
 
-		// In principle apk supports RSA signatures over SHA256/512 keys, but in
-		// practice verification works but installation segfaults. If this is
-		// fixed at some point we should also upgrade the hash. In this case,
-		// the file name will have to start with .SIGN.RSA256 or .SIGN.RSA512.
-		signHeader := &tar.Header{
-			Name: fmt.Sprintf(".SIGN.RSA.%s", keyname),
-			Mode: 0o600,
-			Size: int64(len(signature)),
+// signatureKeyName returns the name of the public key file that needs to
+// exist on the machine during installation: /etc/apk/keys/<keyname>.rsa.pub.
+// It defaults to the maintainer email address.
+func signatureKeyName(info *nfpm.Info, keyname string) (string, error) {
+	if keyname == "" {
+		addr, err := mail.ParseAddress(info.Maintainer)
+		if err != nil {
+			return "", fmt.Errorf("key name not set and unable to parse maintainer mail address: %w", err)
+		} else if addr.Address == "" {
+			return "", errNoKeyAddress
 		}
 
-		return writeFile(tw, signHeader, bytes.NewReader(signature))
+		keyname = addr.Address
 	}
+	if !strings.HasSuffix(keyname, ".rsa.pub") {
+		keyname += ".rsa.pub"
+	}
+	return keyname, nil
 }
 
 func combineToApk(target io.Writer, readers ...io.Reader) error {
@@ -346,8 +416,37 @@ func createBuilderControl(info *nfpm.Info, size int64, dataDigest []byte) func(t
 			".pre-deinstall":  info.Scripts.PreRemove,
 			".post-deinstall": info.Scripts.PostRemove,
 		}
+		// users must exist before the files owned by them are extracted,
+		// which is also the case on upgrades
+		units := systemd.Systemctl(info.Systemd)
+		before := map[string]string{
+			".pre-install":   usersScript(info),
+			".pre-upgrade":   usersScript(info),
+			".pre-deinstall": units.PreRemove,
+		}
+		after := map[string]string{
+			".post-install":   units.PostInstall,
+			".post-upgrade":   units.PostUpgrade,
+			".post-deinstall": units.PostRemove,
+		}
 		for _, name := range maps.Keys(scripts) {
 			path := scripts[name]
+			if before[name] != "" || after[name] != "" {
+				content, err := nfpm.MergeScript(path, before[name], after[name])
+				if err != nil {
+					return err
+				}
+				if err := newItemInsideTarGz(tw, content, &tar.Header{
+					Name:     name,
+					Size:     int64(len(content)),
+					Mode:     0o755,
+					ModTime:  modtime.Get(info.MTime),
+					Typeflag: tar.TypeReg,
+				}); err != nil {
+					return err
+				}
+				continue
+			}
 			if path == "" {
 				continue
 			}
@@ -360,6 +459,46 @@ func createBuilderControl(info *nfpm.Info, size int64, dataDigest []byte) func(t
 	}
 }
 
+// usersScript returns the snippet creating the users and groups of the
+// package with the busybox applets, which fail if they already exist.
+func usersScript(info *nfpm.Info) string {
+	var b strings.Builder
+	addgroup := func(name string, gid uint32) {
+		args := "-S"
+		if gid != 0 {
+			args += " -g " + strconv.FormatUint(uint64(gid), 10)
+		}
+		fmt.Fprintf(&b, "addgroup %s %s 2>/dev/null || :\n", args, shell.Quote(name))
+	}
+	for _, group := range info.Groups {
+		addgroup(group.Name, group.GID)
+	}
+	for _, user := range info.Users {
+		if user.Group == "" {
+			addgroup(user.Name, user.UID)
+		}
+		args := []string{"-S", "-D", "-H"}
+		if user.UID != 0 {
+			args = append(args, "-u", strconv.FormatUint(uint64(user.UID), 10))
+		}
+		args = append(args, "-G", shell.Quote(user.PrimaryGroup()))
+		if user.Home != "" {
+			args = append(args, "-h", shell.Quote(user.Home))
+		}
+		if user.Shell != "" {
+			args = append(args, "-s", shell.Quote(user.Shell))
+		}
+		if user.Description != "" {
+			args = append(args, "-g", shell.Quote(user.Description))
+		}
+		fmt.Fprintf(&b, "adduser %s %s 2>/dev/null || :\n", strings.Join(args, " "), shell.Quote(user.Name))
+		for _, group := range user.Groups {
+			fmt.Fprintf(&b, "addgroup %s %s 2>/dev/null || :\n", shell.Quote(user.Name), shell.Quote(group))
+		}
+	}
+	return b.String()
+}
+
 func newScriptInsideTarGz(out *tar.Writer, path, dest string) error {
 	file, err := os.Stat(path) //nolint:gosec
 	if err != nil {
@@ -404,10 +543,15 @@ func createBuilderData(info *nfpm.Info, sizep *int64) func(tw *tar.Writer) error
 }
 
 func createFilesInsideTarGz(info *nfpm.Info, tw *tar.Writer, sizep *int64) (err error) {
+	// hard links are added last as their targets must come first in the tar
+	var hardlinks []*files.Content
+
 	for _, file := range info.Contents {
 		file.Destination = files.AsRelativePath(file.Destination)
 
 		switch file.Type {
+		case files.TypeHardlink:
+			hardlinks = append(hardlinks, file)
 		case files.TypeDir, files.TypeImplicitDir:
 			err = tw.WriteHeader(&tar.Header{
 				Name:     file.Destination,
@@ -415,6 +559,8 @@ func createFilesInsideTarGz(info *nfpm.Info, tw *tar.Writer, sizep *int64) (err
 				Typeflag: tar.TypeDir,
 				Uname:    file.FileInfo.Owner,
 				Gname:    file.FileInfo.Group,
+				Uid:      int(file.FileInfo.UID),
+				Gid:      int(file.FileInfo.GID),
 				ModTime:  file.FileInfo.MTime,
 			})
 		case files.TypeSymlink:
@@ -424,6 +570,21 @@ func createFilesInsideTarGz(info *nfpm.Info, tw *tar.Writer, sizep *int64) (err
 				Typeflag: tar.TypeSymlink,
 				ModTime:  file.FileInfo.MTime,
 			})
+		case files.TypeCharDevice, files.TypeBlockDevice, files.TypeFIFO:
+			err = tw.WriteHeader(&tar.Header{
+				Name:     file.Destination,
+				Mode:     int64(file.FileInfo.Mode),
+				Typeflag: files.SpecialFileTypeflag(file),
+				Devmajor: int64(file.FileInfo.DevMajor),
+				Devminor: int64(file.FileInfo.DevMinor),
+				Uname:    file.FileInfo.Owner,
+				Gname:    file.FileInfo.Group,
+				Uid:      int(file.FileInfo.UID),
+				Gid:      int(file.FileInfo.GID),
+				ModTime:  file.FileInfo.MTime,
+			})
+		case files.TypeCopyright:
+			err = createCopyrightInsideTarGz(info, file, tw, sizep)
 		default:
 			err = copyToTarAndDigest(file, tw, sizep)
 		}
@@ -432,11 +593,28 @@ func createFilesInsideTarGz(info *nfpm.Info, tw *tar.Writer, sizep *int64) (err
 		}
 	}
 
+	for _, file := range hardlinks {
+		err = tw.WriteHeader(&tar.Header{
+			Name:     file.Destination,
+			Linkname: files.AsRelativePath(file.Source),
+			Mode:     int64(file.FileInfo.Mode),
+			Typeflag: tar.TypeLink,
+			Uname:    file.FileInfo.Owner,
+			Gname:    file.FileInfo.Group,
+			Uid:      int(file.FileInfo.UID),
+			Gid:      int(file.FileInfo.GID),
+			ModTime:  file.FileInfo.MTime,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 func copyToTarAndDigest(file *files.Content, tw *tar.Writer, sizep *int64) error {
-	contents, err := os.ReadFile(file.Source)
+	contents, err := file.ReadData()
 	if err != nil {
 		return err
 	}
@@ -451,6 +629,8 @@ func copyToTarAndDigest(file *files.Content, tw *tar.Writer, sizep *int64) error
 	header.Name = files.AsRelativePath(file.Destination)
 	header.Uname = file.FileInfo.Owner
 	header.Gname = file.FileInfo.Group
+	header.Uid = int(file.FileInfo.UID)
+	header.Gid = int(file.FileInfo.GID)
 	if err = newItemInsideTarGz(tw, contents, header); err != nil {
 		return err
 	}
@@ -459,6 +639,30 @@ func copyToTarAndDigest(file *files.Content, tw *tar.Writer, sizep *int64) error
 	return nil
 }
 
+func createCopyrightInsideTarGz(info *nfpm.Info, file *files.Content, tw *tar.Writer, sizep *int64) error {
+	contents, err := info.FormatCopyright()
+	if err != nil {
+		return err
+	}
+
+	if err = newItemInsideTarGz(tw, contents, &tar.Header{
+		Name:     files.AsRelativePath(file.Destination),
+		Mode:     int64(file.Mode()),
+		Size:     int64(len(contents)),
+		Typeflag: tar.TypeReg,
+		Uname:    file.FileInfo.Owner,
+		Gname:    file.FileInfo.Group,
+		Uid:      int(file.FileInfo.UID),
+		Gid:      int(file.FileInfo.GID),
+		ModTime:  file.FileInfo.MTime,
+	}); err != nil {
+		return err
+	}
+
+	*sizep += int64(len(contents))
+	return nil
+}
+
 // reference: https://wiki.adelielinux.org/wiki/APK_internals#.PKGINFO
 const controlTemplate = `
 {{- /* Mandatory fields */ -}}
@@ -4,6 +4,7 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	"crypto"
 	"crypto/sha1" // nolint:gosec
 	"crypto/sha256"
 	"errors"
@@ -11,11 +12,14 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/deprecation"
 	"github.com/goreleaser/nfpm/v2/files"
 	"github.com/goreleaser/nfpm/v2/internal/sign"
 	"github.com/stretchr/testify/require"
@@ -117,6 +121,27 @@ func TestCreateBuilderData(t *testing.T) {
 	require.Equal(t, 15872, buf.Len(), buf.String())
 }
 
+func TestCreateBuilderDataCopyright(t *testing.T) {
+	info := exampleInfo()
+	info.Copyright.Files = []nfpm.CopyrightFiles{{
+		Paths:     []string{"*"},
+		Copyright: []string{"2023 Carlos A Becker"},
+		License:   "MIT",
+	}}
+	info = nfpm.WithCopyrightIfRequested(info, "/usr/share/licenses/foo/copyright")
+	require.NoError(t, nfpm.PrepareForPackager(info, "apk"))
+	size := int64(0)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, createBuilderData(info, &size)(tw))
+	require.NoError(t, tw.Close())
+
+	expected, err := info.FormatCopyright()
+	require.NoError(t, err)
+	require.Equal(t, expected, extractFromTar(t, buf.Bytes(), "usr/share/licenses/foo/copyright"))
+}
+
 func TestCombineToApk(t *testing.T) {
 	var bufData bytes.Buffer
 	bufData.Write([]byte{1})
@@ -175,6 +200,19 @@ func TestApkPlatform(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestUnsupportedFields(t *testing.T) {
+	warnings := &deprecation.Collector{}
+	previous := deprecation.SetSink(warnings)
+	t.Cleanup(func() { deprecation.SetSink(previous) })
+
+	require.NoError(t, Default.Package(exampleInfo(), io.Discard))
+	require.Equal(t, []deprecation.Warning{
+		{Code: deprecation.UnsupportedField, Path: "conflicts", Message: "apk packages do not support this field, it is ignored"},
+		{Code: deprecation.UnsupportedField, Path: "recommends", Message: "apk packages do not support this field, it is ignored"},
+		{Code: deprecation.UnsupportedField, Path: "suggests", Message: "apk packages do not support this field, it is ignored"},
+	}, warnings.Warnings())
+}
+
 func TestNoInfo(t *testing.T) {
 	err := Default.Package(nfpm.WithDefaults(&nfpm.Info{}), io.Discard)
 	require.Error(t, err)
@@ -306,6 +344,58 @@ func TestCreateBuilderControlScripts(t *testing.T) {
 	require.Contains(t, script, `echo "Postremove" > /dev/null`)
 }
 
+func TestCreateBuilderControlUsers(t *testing.T) {
+	info := exampleInfo()
+	info.Scripts.PreInstall = "../testdata/scripts/preinstall.sh"
+	info.Groups = []nfpm.Group{{Name: "foo-data", GID: 950}}
+	info.Users = []nfpm.User{
+		{Name: "foo", UID: 950, Group: "foo-data", Groups: []string{"adm"}, Home: "/var/lib/foo"},
+		{Name: "bar", Description: "Bar"},
+	}
+	require.NoError(t, nfpm.PrepareForPackager(info, "apk"))
+
+	var w bytes.Buffer
+	tw := tar.NewWriter(&w)
+	require.NoError(t, createBuilderControl(info, 0, sha256.New().Sum(nil))(tw))
+
+	users := `addgroup -S -g 950 'foo-data' 2>/dev/null || :
+adduser -S -D -H -u 950 -G 'foo-data' -h '/var/lib/foo' 'foo' 2>/dev/null || :
+addgroup 'foo' 'adm' 2>/dev/null || :
+addgroup -S 'bar' 2>/dev/null || :
+adduser -S -D -H -G 'bar' -g 'Bar' 'bar' 2>/dev/null || :
+`
+	require.Equal(t, "#!/bin/bash\n"+users+"\necho \"Preinstall\" > /dev/null\n",
+		string(extractFromTar(t, w.Bytes(), ".pre-install")))
+	require.Equal(t, "#!/bin/sh\n"+users, string(extractFromTar(t, w.Bytes(), ".pre-upgrade")))
+}
+
+func TestCreateBuilderControlSystemd(t *testing.T) {
+	info := exampleInfo()
+	info.Scripts.PostInstall = "../testdata/scripts/postinstall.sh"
+	info.Systemd = []nfpm.SystemdUnit{{Source: "../testdata/systemd/foo.service", Start: true, RestartOnUpgrade: true}}
+	require.NoError(t, nfpm.PrepareForPackager(info, "apk"))
+
+	var w bytes.Buffer
+	tw := tar.NewWriter(&w)
+	require.NoError(t, createBuilderControl(info, 0, sha256.New().Sum(nil))(tw))
+
+	require.Equal(t, `#!/bin/bash
+(
+
+echo "Postinstall" > /dev/null
+) || exit $?
+if [ -d /run/systemd/system ]; then
+	systemctl daemon-reload >/dev/null 2>&1 || :
+fi
+if [ -d /run/systemd/system ]; then
+	systemctl start 'foo.service' >/dev/null 2>&1 || :
+fi
+`, string(extractFromTar(t, w.Bytes(), ".post-install")))
+	require.Contains(t, string(extractFromTar(t, w.Bytes(), ".post-upgrade")), "systemctl try-restart 'foo.service'")
+	require.Contains(t, string(extractFromTar(t, w.Bytes(), ".pre-deinstall")), "systemctl stop 'foo.service'")
+	require.Contains(t, string(extractFromTar(t, w.Bytes(), ".post-deinstall")), "systemctl daemon-reload")
+}
+
 func TestControl(t *testing.T) {
 	var w bytes.Buffer
 	require.NoError(t, writeControl(&w, controlData{
@@ -418,6 +508,139 @@ func TestSignatureCallback(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestSignatureCommand(t *testing.T) {
+	if _, err := exec.LookPath("openssl"); err != nil {
+		t.Skip("openssl is not available")
+	}
+
+	info := exampleInfo()
+	info.APK.Signature.Command = []string{
+		"openssl", "pkeyutl", "-sign",
+		"-inkey", "../internal/sign/testdata/rsa_unprotected.priv",
+		"-pkeyopt", "digest:sha1",
+	}
+	info.APK.Signature.KeyName = "testkey.rsa.pub"
+	require.NoError(t, nfpm.PrepareForPackager(info, "apk"))
+
+	digest := sha1.New().Sum(nil) // nolint:gosec
+
+	var signatureTarGz bytes.Buffer
+	tw := tar.NewWriter(&signatureTarGz)
+	require.NoError(t, createSignatureBuilder(digest, info)(tw))
+
+	signature := extractFromTar(t, signatureTarGz.Bytes(), ".SIGN.RSA.testkey.rsa.pub")
+	require.NoError(t, sign.RSAVerifySHA1Digest(digest, signature, "../internal/sign/testdata/rsa_unprotected.pub"))
+}
+
+func TestSign(t *testing.T) {
+	info := exampleInfo()
+
+	var apk bytes.Buffer
+	require.NoError(t, Default.Package(info, &apk))
+	require.ErrorIs(t, Default.Sign(info, bytes.NewReader(apk.Bytes()), io.Discard), nfpm.ErrNoSigningKey)
+
+	info.APK.Signature.KeyFile = "../internal/sign/testdata/rsa.priv"
+	info.APK.Signature.KeyName = "testkey"
+	info.APK.Signature.KeyPassphrase = "hunter2"
+
+	var signed bytes.Buffer
+	require.NoError(t, Default.Sign(info, bytes.NewReader(apk.Bytes()), &signed))
+
+	// signing again replaces the signature
+	var resigned bytes.Buffer
+	require.NoError(t, Default.Sign(info, bytes.NewReader(signed.Bytes()), &resigned))
+
+	signatureSize, err := gzipMemberSize(resigned.Bytes())
+	require.NoError(t, err)
+	rest := resigned.Bytes()[signatureSize:]
+	require.Equal(t, apk.Bytes(), rest)
+	isSignature, err := isSignatureTgz(rest)
+	require.NoError(t, err)
+	require.False(t, isSignature)
+
+	controlSize, err := gzipMemberSize(rest)
+	require.NoError(t, err)
+	digest := sha1.Sum(rest[:controlSize]) // nolint:gosec
+
+	gzr, err := gzip.NewReader(bytes.NewReader(resigned.Bytes()[:signatureSize]))
+	require.NoError(t, err)
+	signatureTar, err := io.ReadAll(gzr)
+	require.NoError(t, err)
+	signature := extractFromTar(t, signatureTar, ".SIGN.RSA.testkey.rsa.pub")
+	require.NoError(t, sign.RSAVerifySHA1Digest(digest[:], signature, "../internal/sign/testdata/rsa.pub"))
+
+	require.Error(t, Default.Sign(info, strings.NewReader("not an apk"), io.Discard))
+}
+
+func TestSignatureDigest(t *testing.T) {
+	for _, tc := range []struct {
+		digest string
+		hash   crypto.Hash
+		name   string
+	}{
+		{"sha256", crypto.SHA256, ".SIGN.RSA256.testkey.rsa.pub"},
+		{"sha512", crypto.SHA512, ".SIGN.RSA512.testkey.rsa.pub"},
+	} {
+		t.Run(tc.digest, func(t *testing.T) {
+			info := exampleInfo()
+			info.APK.Signature.KeyFile = "../internal/sign/testdata/rsa.priv"
+			info.APK.Signature.KeyName = "testkey"
+			info.APK.Signature.KeyPassphrase = "hunter2"
+			info.APK.Signature.Digest = tc.digest
+
+			var apk bytes.Buffer
+			require.NoError(t, Default.Package(info, &apk))
+
+			signatureSize, err := gzipMemberSize(apk.Bytes())
+			require.NoError(t, err)
+			rest := apk.Bytes()[signatureSize:]
+			controlSize, err := gzipMemberSize(rest)
+			require.NoError(t, err)
+			h := tc.hash.New()
+			h.Write(rest[:controlSize])
+
+			gzr, err := gzip.NewReader(bytes.NewReader(apk.Bytes()[:signatureSize]))
+			require.NoError(t, err)
+			signatureTar, err := io.ReadAll(gzr)
+			require.NoError(t, err)
+			signature := extractFromTar(t, signatureTar, tc.name)
+			require.NoError(t, sign.RSAVerifyDigest(h.Sum(nil), tc.hash, signature, "../internal/sign/testdata/rsa.pub"))
+		})
+	}
+
+	info := exampleInfo()
+	info.APK.Signature.KeyFile = "../internal/sign/testdata/rsa.priv"
+	info.APK.Signature.Digest = "md5"
+	require.EqualError(t, Default.Package(info, io.Discard), "invalid apk signature digest: md5")
+}
+
+func TestSignatureKeys(t *testing.T) {
+	info := exampleInfo()
+	info.APK.Signature.KeyFile = "../internal/sign/testdata/rsa.priv"
+	info.APK.Signature.KeyName = "newkey"
+	info.APK.Signature.KeyPassphrase = "hunter2"
+	info.APK.Signature.Keys = []nfpm.APKSignatureKey{{
+		PackageSignature: nfpm.PackageSignature{KeyFile: "../internal/sign/testdata/rsa_pkcs8.priv"},
+		KeyName:          "oldkey",
+	}}
+	require.NoError(t, nfpm.PrepareForPackager(info, "apk"))
+
+	digest := sha1.New().Sum(nil) // nolint:gosec
+
+	var signatureTarGz bytes.Buffer
+	tw := tar.NewWriter(&signatureTarGz)
+	require.NoError(t, createSignatureBuilder(digest, info)(tw))
+
+	signature := extractFromTar(t, signatureTarGz.Bytes(), ".SIGN.RSA.newkey.rsa.pub")
+	require.NoError(t, sign.RSAVerifySHA1Digest(digest, signature, "../internal/sign/testdata/rsa.pub"))
+	signature = extractFromTar(t, signatureTarGz.Bytes(), ".SIGN.RSA.oldkey.rsa.pub")
+	require.NoError(t, sign.RSAVerifySHA1Digest(digest, signature, "../internal/sign/testdata/rsa_pkcs8.pub"))
+
+	info.APK.Signature.Keys[0].KeyName = "newkey.rsa.pub"
+	require.EqualError(t, createSignatureBuilder(digest, info)(tar.NewWriter(io.Discard)),
+		"more than one signing key named newkey.rsa.pub")
+}
+
 func TestDisableGlobbing(t *testing.T) {
 	info := exampleInfo()
 	info.DisableGlobbing = true
@@ -0,0 +1,90 @@
+package apk
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/goreleaser/nfpm/v2"
+)
+
+// Sign adds or replaces the signature of an existing apk package. The
+// control and data tgz are copied unchanged.
+func (*Apk) Sign(info *nfpm.Info, r io.Reader, w io.Writer) error {
+	if !info.APK.Signature.IsSet() {
+		return nfpm.ErrNoSigningKey
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	first, err := gzipMemberSize(data)
+	if err != nil {
+		return fmt.Errorf("not an apk package: %w", err)
+	}
+	signed, err := isSignatureTgz(data[:first])
+	if err != nil {
+		return fmt.Errorf("not an apk package: %w", err)
+	}
+	if signed {
+		data = data[first:]
+	}
+
+	control, err := gzipMemberSize(data)
+	if err != nil {
+		return fmt.Errorf("not an apk package: %w", err)
+	}
+	if control == len(data) {
+		return errors.New("not an apk package: data tgz is missing")
+	}
+
+	algorithm, err := signatureDigest(info)
+	if err != nil {
+		return err
+	}
+	controlHash := algorithm.newHash()
+	controlHash.Write(data[:control])
+	var bufSignature bytes.Buffer
+	if err := createSignature(&bufSignature, info, controlHash.Sum(nil)); err != nil {
+		return err
+	}
+
+	return combineToApk(w, &bufSignature, bytes.NewReader(data))
+}
+
+// gzipMemberSize returns the size of the first gzip member of the given
+// concatenated gzip streams.
+func gzipMemberSize(data []byte) (int, error) {
+	// bytes.Reader is an io.ByteReader, so gzip reads no further than the end
+	// of the member
+	br := bytes.NewReader(data)
+	gr, err := gzip.NewReader(br)
+	if err != nil {
+		return 0, err
+	}
+	gr.Multistream(false)
+	if _, err := io.Copy(io.Discard, gr); err != nil {
+		return 0, err
+	}
+	return len(data) - br.Len(), nil
+}
+
+// isSignatureTgz reports whether the given tgz holds the signature of the
+// package.
+func isSignatureTgz(tgz []byte) (bool, error) {
+	gr, err := gzip.NewReader(bytes.NewReader(tgz))
+	if err != nil {
+		return false, err
+	}
+	hdr, err := tar.NewReader(gr).Next()
+	if err != nil {
+		return false, err
+	}
+	return strings.HasPrefix(hdr.Name, ".SIGN."), nil
+}
@@ -9,7 +9,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -18,6 +17,7 @@ import (
 	"github.com/goreleaser/nfpm/v2/files"
 	"github.com/goreleaser/nfpm/v2/internal/maps"
 	"github.com/goreleaser/nfpm/v2/internal/modtime"
+	"github.com/goreleaser/nfpm/v2/internal/systemd"
 	"github.com/klauspost/compress/zstd"
 	"github.com/klauspost/pgzip"
 )
@@ -122,10 +122,12 @@ func (ArchLinux) Package(info *nfpm.Info, w io.Writer) error {
 	}
 	info = ensureValidArch(info)
 
+	info = nfpm.WithCopyrightIfRequested(info, fmt.Sprintf("/usr/share/licenses/%s/copyright", info.Name))
 	err := nfpm.PrepareForPackager(info, packagerName)
 	if err != nil {
 		return err
 	}
+	nfpm.WarnUnsupportedFields(info, packagerName, "recommends", "suggests")
 
 	if !nameIsValid(info.Name) {
 		return ErrInvalidPkgName
@@ -171,10 +173,17 @@ func createFilesInTar(info *nfpm.Info, tw *tar.Writer) ([]MtreeEntry, int64, err
 	entries := make([]MtreeEntry, 0, len(info.Contents))
 	var totalSize int64
 
+	// hard links are added last as their targets must come first in the tar,
+	// their mtree entries are the ones of the targets
+	var hardlinks []*files.Content
+	fileEntries := map[string]MtreeEntry{}
+
 	for _, content := range info.Contents {
 		content.Destination = files.AsRelativePath(content.Destination)
 
 		switch content.Type {
+		case files.TypeHardlink:
+			hardlinks = append(hardlinks, content)
 		case files.TypeDir, files.TypeImplicitDir:
 			entries = append(entries, MtreeEntry{
 				Destination: content.Destination,
@@ -190,9 +199,36 @@ func createFilesInTar(info *nfpm.Info, tw *tar.Writer) ([]MtreeEntry, int64, err
 				ModTime:  content.ModTime(),
 				Uname:    content.FileInfo.Owner,
 				Gname:    content.FileInfo.Group,
+				Uid:      int(content.FileInfo.UID),
+				Gid:      int(content.FileInfo.GID),
 			}); err != nil {
 				return nil, 0, err
 			}
+		case files.TypeCharDevice, files.TypeBlockDevice, files.TypeFIFO:
+			header := &tar.Header{
+				Name:     content.Destination,
+				Mode:     int64(content.Mode()),
+				ModTime:  content.ModTime(),
+				Uname:    content.FileInfo.Owner,
+				Gname:    content.FileInfo.Group,
+				Uid:      int(content.FileInfo.UID),
+				Gid:      int(content.FileInfo.GID),
+				Devmajor: int64(content.FileInfo.DevMajor),
+				Devminor: int64(content.FileInfo.DevMinor),
+				Typeflag: files.SpecialFileTypeflag(content),
+			}
+			if err := tw.WriteHeader(header); err != nil {
+				return nil, 0, err
+			}
+
+			entries = append(entries, MtreeEntry{
+				Destination: content.Destination,
+				Time:        content.ModTime().Unix(),
+				Mode:        int64(content.Mode()),
+				Type:        content.Type,
+				DevMajor:    content.FileInfo.DevMajor,
+				DevMinor:    content.FileInfo.DevMinor,
+			})
 		case files.TypeSymlink:
 			if err := tw.WriteHeader(&tar.Header{
 				Name:     content.Destination,
@@ -211,11 +247,22 @@ func createFilesInTar(info *nfpm.Info, tw *tar.Writer) ([]MtreeEntry, int64, err
 				Type:        content.Type,
 			})
 		default:
-			src, err := os.Open(content.Source)
-			if err != nil {
-				return nil, 0, err
+			var src io.Reader
+			if content.Type == files.TypeCopyright {
+				data, err := info.FormatCopyright()
+				if err != nil {
+					return nil, 0, err
+				}
+				content.FileInfo.Size = int64(len(data))
+				src = bytes.NewReader(data)
+			} else {
+				f, err := content.Open()
+				if err != nil {
+					return nil, 0, err
+				}
+				defer f.Close() // nolint: errcheck
+				src = f
 			}
-			defer src.Close() // nolint: errcheck
 
 			header := &tar.Header{
 				Name:     content.Destination,
@@ -237,7 +284,7 @@ func createFilesInTar(info *nfpm.Info, tw *tar.Writer) ([]MtreeEntry, int64, err
 				header.Size = content.Size()
 			}
 
-			err = tw.WriteHeader(header)
+			err := tw.WriteHeader(header)
 			if err != nil {
 				return nil, 0, err
 			}
@@ -252,7 +299,7 @@ func createFilesInTar(info *nfpm.Info, tw *tar.Writer) ([]MtreeEntry, int64, err
 				return nil, 0, err
 			}
 
-			entries = append(entries, MtreeEntry{
+			entry := MtreeEntry{
 				Destination: content.Destination,
 				Time:        content.ModTime().Unix(),
 				Mode:        int64(content.Mode()),
@@ -260,12 +307,35 @@ func createFilesInTar(info *nfpm.Info, tw *tar.Writer) ([]MtreeEntry, int64, err
 				Type:        content.Type,
 				MD5:         md5Hash.Sum(nil),
 				SHA256:      sha256Hash.Sum(nil),
-			})
+			}
+			entries = append(entries, entry)
+			fileEntries[content.Destination] = entry
 
 			totalSize += content.Size()
 		}
 	}
 
+	for _, content := range hardlinks {
+		target := files.AsRelativePath(content.Source)
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     content.Destination,
+			Linkname: target,
+			Mode:     int64(content.Mode()),
+			Typeflag: tar.TypeLink,
+			ModTime:  content.ModTime(),
+			Uname:    content.FileInfo.Owner,
+			Gname:    content.FileInfo.Group,
+			Uid:      int(content.FileInfo.UID),
+			Gid:      int(content.FileInfo.GID),
+		}); err != nil {
+			return nil, 0, err
+		}
+
+		entry := fileEntries[target]
+		entry.Destination = content.Destination
+		entries = append(entries, entry)
+	}
+
 	return entries, totalSize, nil
 }
 
@@ -445,6 +515,8 @@ type MtreeEntry struct {
 	Type        string
 	MD5         []byte
 	SHA256      []byte
+	DevMajor    uint32
+	DevMinor    uint32
 }
 
 func (me *MtreeEntry) WriteTo(w io.Writer) (int64, error) {
@@ -458,6 +530,31 @@ func (me *MtreeEntry) WriteTo(w io.Writer) (int64, error) {
 			me.Mode,
 		)
 		return int64(n), err
+	case files.TypeCharDevice, files.TypeBlockDevice:
+		typ := "char"
+		if me.Type == files.TypeBlockDevice {
+			typ = "block"
+		}
+		n, err := fmt.Fprintf(
+			w,
+			"./%s time=%d.0 mode=%o type=%s device=native,%d,%d\n",
+			me.Destination,
+			me.Time,
+			me.Mode,
+			typ,
+			me.DevMajor,
+			me.DevMinor,
+		)
+		return int64(n), err
+	case files.TypeFIFO:
+		n, err := fmt.Fprintf(
+			w,
+			"./%s time=%d.0 mode=%o type=fifo\n",
+			me.Destination,
+			me.Time,
+			me.Mode,
+		)
+		return int64(n), err
 	case files.TypeSymlink:
 		n, err := fmt.Fprintf(
 			w,
@@ -518,30 +615,27 @@ func createMtree(tw *tar.Writer, entries []MtreeEntry, mtime time.Time) error {
 }
 
 func createScripts(info *nfpm.Info, tw *tar.Writer) error {
-	scripts := map[string]string{}
-
-	if info.Scripts.PreInstall != "" {
-		scripts["pre_install"] = info.Scripts.PreInstall
-	}
-
-	if info.Scripts.PostInstall != "" {
-		scripts["post_install"] = info.Scripts.PostInstall
-	}
-
-	if info.Scripts.PreRemove != "" {
-		scripts["pre_remove"] = info.Scripts.PreRemove
-	}
-
-	if info.Scripts.PostRemove != "" {
-		scripts["post_remove"] = info.Scripts.PostRemove
-	}
-
-	if info.ArchLinux.Scripts.PreUpgrade != "" {
-		scripts["pre_upgrade"] = info.ArchLinux.Scripts.PreUpgrade
-	}
-
-	if info.ArchLinux.Scripts.PostUpgrade != "" {
-		scripts["post_upgrade"] = info.ArchLinux.Scripts.PostUpgrade
+	units := systemd.Systemctl(info.Systemd)
+	sources := map[string]struct {
+		path, before, after string
+	}{
+		"pre_install":  {path: info.Scripts.PreInstall},
+		"post_install": {path: info.Scripts.PostInstall, after: units.PostInstall},
+		"pre_remove":   {path: info.Scripts.PreRemove, before: units.PreRemove},
+		"post_remove":  {path: info.Scripts.PostRemove, after: units.PostRemove},
+		"pre_upgrade":  {path: info.ArchLinux.Scripts.PreUpgrade},
+		"post_upgrade": {path: info.ArchLinux.Scripts.PostUpgrade, after: units.PostUpgrade},
+	}
+
+	scripts := map[string][]byte{}
+	for name, source := range sources {
+		script, err := nfpm.MergeScript(source.path, source.before, source.after)
+		if err != nil {
+			return err
+		}
+		if script != nil {
+			scripts[name] = script
+		}
 	}
 
 	if len(scripts) == 0 {
@@ -570,22 +664,14 @@ func createScripts(info *nfpm.Info, tw *tar.Writer) error {
 	return err
 }
 
-func writeScripts(w io.Writer, scripts map[string]string) error {
+func writeScripts(w io.Writer, scripts map[string][]byte) error {
 	for _, script := range maps.Keys(scripts) {
 		fmt.Fprintf(w, "function %s() {\n", script)
 
-		fl, err := os.Open(scripts[script])
+		_, err := w.Write(scripts[script])
 		if err != nil {
 			return err
 		}
-		defer fl.Close() //nolint: errcheck
-
-		_, err = io.Copy(w, fl)
-		if err != nil {
-			return err
-		}
-
-		_ = fl.Close()
 
 		_, err = io.WriteString(w, "\n}\n\n")
 		if err != nil {
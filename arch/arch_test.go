@@ -3,6 +3,7 @@ package arch
 import (
 	"archive/tar"
 	"bytes"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"os"
@@ -11,7 +12,9 @@ import (
 	"time"
 
 	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/deprecation"
 	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/sign"
 	"github.com/klauspost/compress/zstd"
 	"github.com/klauspost/pgzip"
 	"github.com/stretchr/testify/require"
@@ -105,6 +108,24 @@ func TestArch(t *testing.T) {
 	}
 }
 
+func TestArchUnsupportedFields(t *testing.T) {
+	warnings := &deprecation.Collector{}
+	previous := deprecation.SetSink(warnings)
+	t.Cleanup(func() { deprecation.SetSink(previous) })
+
+	require.NoError(t, Default.Package(exampleInfo(), io.Discard))
+	require.Empty(t, warnings.Warnings())
+
+	info := exampleInfo()
+	info.Recommends = []string{"git"}
+	require.NoError(t, Default.Package(info, io.Discard))
+	require.Equal(t, []deprecation.Warning{{
+		Code:    deprecation.UnsupportedField,
+		Path:    "recommends",
+		Message: "archlinux packages do not support this field, it is ignored",
+	}}, warnings.Warnings())
+}
+
 func TestArchPlatform(t *testing.T) {
 	f, err := os.CreateTemp(t.TempDir(), "test*.pkg.tar.zstd")
 	require.NoError(t, err)
@@ -319,6 +340,91 @@ func TestArchMtree(t *testing.T) {
 	require.Equal(t, correctMtree, string(mtree))
 }
 
+func TestArchMtreeSpecialFiles(t *testing.T) {
+	var buf bytes.Buffer
+	for _, entry := range []MtreeEntry{
+		{Destination: "dev/null", Time: 1234, Mode: 0o666, Type: files.TypeCharDevice, DevMajor: 1, DevMinor: 3},
+		{Destination: "dev/loop0", Time: 1234, Mode: 0o660, Type: files.TypeBlockDevice, DevMajor: 7},
+		{Destination: "run/foo.fifo", Time: 1234, Mode: 0o600, Type: files.TypeFIFO},
+	} {
+		_, err := entry.WriteTo(&buf)
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, `./dev/null time=1234.0 mode=666 type=char device=native,1,3
+./dev/loop0 time=1234.0 mode=660 type=block device=native,7,0
+./run/foo.fifo time=1234.0 mode=600 type=fifo
+`, buf.String())
+}
+
+func TestArchCopyright(t *testing.T) {
+	info := exampleInfo()
+	info.Copyright.Files = []nfpm.CopyrightFiles{{
+		Paths:     []string{"*"},
+		Copyright: []string{"2023 Carlos A Becker"},
+		License:   "MIT",
+	}}
+	info = nfpm.WithCopyrightIfRequested(info, "/usr/share/licenses/foo-test/copyright")
+	require.NoError(t, nfpm.PrepareForPackager(info, packagerName))
+
+	var buf bytes.Buffer
+	entries, _, err := createFilesInTar(info, tar.NewWriter(&buf))
+	require.NoError(t, err)
+
+	expected, err := info.FormatCopyright()
+	require.NoError(t, err)
+	for _, entry := range entries {
+		if entry.Destination == "usr/share/licenses/foo-test/copyright" {
+			require.Equal(t, int64(len(expected)), entry.Size)
+			require.Equal(t, int64(0o644), entry.Mode)
+			sum := sha256.Sum256(expected)
+			require.Equal(t, sum[:], entry.SHA256)
+			return
+		}
+	}
+	t.Fatal("copyright file not found")
+}
+
+func TestArchSystemdScripts(t *testing.T) {
+	info := exampleInfo()
+	info.Scripts.PreRemove = ""
+	info.Scripts.PostInstall = "../testdata/scripts/postinstall.sh"
+	info.Systemd = []nfpm.SystemdUnit{{Source: "../testdata/systemd/foo.service", Enable: true, Start: true}}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, createScripts(info, tw))
+	require.NoError(t, tw.Close())
+
+	tr := tar.NewReader(&buf)
+	hdr, err := tr.Next()
+	require.NoError(t, err)
+	require.Equal(t, ".INSTALL", hdr.Name)
+	install, err := io.ReadAll(tr)
+	require.NoError(t, err)
+
+	require.Contains(t, string(install), `function post_install() {
+#!/bin/bash
+(
+
+echo "Postinstall" > /dev/null
+) || exit $?
+if [ -d /run/systemd/system ]; then
+	systemctl daemon-reload >/dev/null 2>&1 || :
+fi
+systemctl enable 'foo.service' >/dev/null 2>&1 || :
+if [ -d /run/systemd/system ]; then
+	systemctl start 'foo.service' >/dev/null 2>&1 || :
+fi
+
+}`)
+	require.Contains(t, string(install), `function pre_remove() {
+#!/bin/sh
+systemctl --no-reload disable 'foo.service' >/dev/null 2>&1 || :
+`)
+	require.Contains(t, string(install), "function post_upgrade() {\n")
+}
+
 func TestGlob(t *testing.T) {
 	var pkg bytes.Buffer
 	require.NoError(t, Default.Package(nfpm.WithDefaults(&nfpm.Info{
@@ -388,3 +494,22 @@ func TestGlob(t *testing.T) {
 		require.Equal(t, expect, strings.Split(line, " ")[1:], filename)
 	}
 }
+
+func TestSignDetached(t *testing.T) {
+	info := exampleInfo()
+
+	var pkg bytes.Buffer
+	require.NoError(t, Default.Package(info, &pkg))
+	require.ErrorIs(t, Default.SignDetached(info, bytes.NewReader(pkg.Bytes()), io.Discard), nfpm.ErrNoSigningKey)
+
+	info.ArchLinux.Signature.KeyFile = "../internal/sign/testdata/privkey.asc"
+	info.ArchLinux.Signature.KeyPassphrase = "hunter2"
+
+	var sig bytes.Buffer
+	require.NoError(t, Default.SignDetached(info, bytes.NewReader(pkg.Bytes()), &sig))
+	require.NoError(t, sign.PGPVerify(bytes.NewReader(pkg.Bytes()), sig.Bytes(), "../internal/sign/testdata/pubkey.asc"))
+
+	info.ArchLinux.Signature.KeyPassphrase = "wrongpass"
+	var expectedError *nfpm.ErrSigningFailure
+	require.ErrorAs(t, Default.SignDetached(info, bytes.NewReader(pkg.Bytes()), io.Discard), &expectedError)
+}
@@ -0,0 +1,19 @@
+package arch
+
+import (
+	"io"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/internal/sign"
+)
+
+// SignDetached writes a detached OpenPGP signature of an existing package,
+// which pacman expects next to the package as <package>.sig.
+func (ArchLinux) SignDetached(info *nfpm.Info, r io.Reader, w io.Writer) error {
+	sig, err := sign.PGPDetachSign(info.ArchLinux.Signature, r)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(sig)
+	return err
+}
@@ -15,6 +15,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
@@ -26,7 +27,9 @@ import (
 	"github.com/goreleaser/nfpm/v2/files"
 	"github.com/goreleaser/nfpm/v2/internal/maps"
 	"github.com/goreleaser/nfpm/v2/internal/modtime"
+	"github.com/goreleaser/nfpm/v2/internal/shell"
 	"github.com/goreleaser/nfpm/v2/internal/sign"
+	"github.com/goreleaser/nfpm/v2/internal/systemd"
 	"github.com/klauspost/compress/zstd"
 	"github.com/ulikunitz/xz"
 )
@@ -104,6 +107,8 @@ var ErrInvalidSignatureType = errors.New("invalid signature type")
 func (d *Deb) Package(info *nfpm.Info, deb io.Writer) (err error) { // nolint: funlen
 	info = ensureValidArch(info)
 
+	// https://www.debian.org/doc/debian-policy/ch-docs.html#copyright-information
+	info = nfpm.WithCopyrightIfRequested(info, fmt.Sprintf("/usr/share/doc/%s/copyright", info.Name))
 	err = nfpm.PrepareForPackager(withChangelogIfRequested(info), packagerName)
 	if err != nil {
 		return err
@@ -143,15 +148,17 @@ func (d *Deb) Package(info *nfpm.Info, deb io.Writer) (err error) { // nolint: f
 		return fmt.Errorf("cannot add data.tar.gz to deb: %w", err)
 	}
 
-	if info.Deb.Signature.KeyFile != "" || info.Deb.Signature.SignFn != nil {
-		sig, sigType, err := doSign(info, debianBinary, controlTarGz, dataTarball)
+	if info.Deb.Signature.IsSet() {
+		signatures, err := doSign(info, debianBinary, controlTarGz, dataTarball)
 		if err != nil {
 			return err
 		}
 
-		if err := addArFile(w, "_gpg"+sigType, sig, mtime); err != nil {
-			return &nfpm.ErrSigningFailure{
-				Err: fmt.Errorf("add signature to ar file: %w", err),
+		for _, sig := range signatures {
+			if err := addArFile(w, "_gpg"+sig.role, sig.data, mtime); err != nil {
+				return &nfpm.ErrSigningFailure{
+					Err: fmt.Errorf("add signature to ar file: %w", err),
+				}
 			}
 		}
 	}
@@ -159,31 +166,81 @@ func (d *Deb) Package(info *nfpm.Info, deb io.Writer) (err error) { // nolint: f
 	return nil
 }
 
-func doSign(info *nfpm.Info, debianBinary, controlTarGz, dataTarball []byte) ([]byte, string, error) {
-	switch info.Deb.Signature.Method {
-	case "dpkg-sig":
-		return dpkgSign(info, debianBinary, controlTarGz, dataTarball)
-	default:
-		return debSign(info, debianBinary, controlTarGz, dataTarball)
+// debSignature is the signature of a deb package for a signer role.
+type debSignature struct {
+	role string
+	data []byte
+}
+
+// doSign signs the package with the signing key and the additional keys. The
+// debsign signatures of keys with the same role are joined into one
+// signature, while dpkg-sig only supports a single key per role.
+func doSign(info *nfpm.Info, debianBinary, controlTarGz, dataTarball []byte) ([]debSignature, error) {
+	keys := make([]nfpm.DebSignatureKey, 0, len(info.Deb.Signature.Keys)+1)
+	if info.Deb.Signature.PackageSignature.IsSet() {
+		keys = append(keys, nfpm.DebSignatureKey{
+			PackageSignature: info.Deb.Signature.PackageSignature,
+			Type:             info.Deb.Signature.Type,
+		})
+	}
+	for _, key := range info.Deb.Signature.Keys {
+		if key.Type == "" {
+			key.Type = info.Deb.Signature.Type
+		}
+		keys = append(keys, key)
+	}
+
+	var signatures []debSignature
+	roles := map[string]int{}
+	for _, key := range keys {
+		var sig []byte
+		var sigType string
+		var err error
+		switch info.Deb.Signature.Method {
+		case "dpkg-sig":
+			sig, sigType, err = dpkgSign(info, key, debianBinary, controlTarGz, dataTarball)
+		default:
+			sig, sigType, err = debSign(key, debianBinary, controlTarGz, dataTarball)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		i, ok := roles[sigType]
+		if !ok {
+			roles[sigType] = len(signatures)
+			signatures = append(signatures, debSignature{role: sigType, data: sig})
+			continue
+		}
+		if info.Deb.Signature.Method == "dpkg-sig" {
+			return nil, &nfpm.ErrSigningFailure{
+				Err: fmt.Errorf("dpkg-sig supports a single key per role, got more for %s", sigType),
+			}
+		}
+		signatures[i].data, err = sign.PGPJoinArmoredSignatures(signatures[i].data, sig)
+		if err != nil {
+			return nil, &nfpm.ErrSigningFailure{Err: err}
+		}
 	}
+	return signatures, nil
 }
 
-func dpkgSign(info *nfpm.Info, debianBinary, controlTarGz, dataTarball []byte) ([]byte, string, error) {
+func dpkgSign(info *nfpm.Info, key nfpm.DebSignatureKey, debianBinary, controlTarGz, dataTarball []byte) ([]byte, string, error) {
 	sigType := "builder"
-	if info.Deb.Signature.Type != "" {
-		sigType = info.Deb.Signature.Type
+	if key.Type != "" {
+		sigType = key.Type
 	}
 
-	data, err := readDpkgSigData(info, debianBinary, controlTarGz, dataTarball)
+	data, err := readDpkgSigData(info, key.Type, debianBinary, controlTarGz, dataTarball)
 	if err != nil {
 		return nil, sigType, &nfpm.ErrSigningFailure{Err: err}
 	}
 
 	var sig []byte
-	if signFn := info.Deb.Signature.SignFn; signFn != nil {
+	if signFn := sign.SignFn(key.PackageSignature, sign.PGPClearSign); signFn != nil {
 		sig, err = signFn(data)
 	} else {
-		sig, err = sign.PGPClearSignWithKeyID(data, info.Deb.Signature.KeyFile, info.Deb.Signature.KeyPassphrase, info.Deb.Signature.KeyID)
+		sig, err = sign.PGPClearSignWithKeyID(data, key.KeyFile, key.KeyPassphrase, key.KeyID)
 	}
 	if err != nil {
 		return nil, sigType, &nfpm.ErrSigningFailure{Err: err}
@@ -191,12 +248,12 @@ func dpkgSign(info *nfpm.Info, debianBinary, controlTarGz, dataTarball []byte) (
 	return sig, sigType, nil
 }
 
-func debSign(info *nfpm.Info, debianBinary, controlTarGz, dataTarball []byte) ([]byte, string, error) {
+func debSign(key nfpm.DebSignatureKey, debianBinary, controlTarGz, dataTarball []byte) ([]byte, string, error) {
 	data := readDebsignData(debianBinary, controlTarGz, dataTarball)
 
 	sigType := "origin"
-	if info.Deb.Signature.Type != "" {
-		sigType = info.Deb.Signature.Type
+	if key.Type != "" {
+		sigType = key.Type
 	}
 
 	if sigType != "origin" && sigType != "maint" && sigType != "archive" {
@@ -207,10 +264,10 @@ func debSign(info *nfpm.Info, debianBinary, controlTarGz, dataTarball []byte) ([
 
 	var sig []byte
 	var err error
-	if signFn := info.Deb.Signature.SignFn; signFn != nil {
+	if signFn := sign.SignFn(key.PackageSignature, sign.PGPArmoredDetached); signFn != nil {
 		sig, err = signFn(data)
 	} else {
-		sig, err = sign.PGPArmoredDetachSignWithKeyID(data, info.Deb.Signature.KeyFile, info.Deb.Signature.KeyPassphrase, info.Deb.Signature.KeyID)
+		sig, err = sign.PGPArmoredDetachSignWithKeyID(data, key.KeyFile, key.KeyPassphrase, key.KeyID)
 	}
 	if err != nil {
 		return nil, sigType, &nfpm.ErrSigningFailure{Err: err}
@@ -261,11 +318,11 @@ func newDpkgSigFileLine(name string, fileContent []byte) dpkgSigFileLine {
 	}
 }
 
-func readDpkgSigData(info *nfpm.Info, debianBinary, controlTarGz, dataTarball []byte) (io.Reader, error) {
+func readDpkgSigData(info *nfpm.Info, role string, debianBinary, controlTarGz, dataTarball []byte) (io.Reader, error) {
 	data := dpkgSigData{
 		Signer: info.Deb.Signature.Signer,
 		Date:   modtime.Get(info.MTime),
-		Role:   info.Deb.Signature.Type,
+		Role:   role,
 		Files: []dpkgSigFileLine{
 			newDpkgSigFileLine("debian-binary", debianBinary),
 			newDpkgSigFileLine("control.tar.gz", controlTarGz),
@@ -296,7 +353,11 @@ func (*Deb) SetPackagerDefaults(info *nfpm.Info) {
 	// if in the long run we should be more strict about this and error when
 	// not set?
 	if info.Maintainer == "" {
-		deprecation.Println("Leaving the 'maintainer' field unset will not be allowed in a future version")
+		deprecation.Warn(deprecation.Warning{
+			Code:    deprecation.Deprecated,
+			Path:    "maintainer",
+			Message: "leaving the field unset will not be allowed in a future version",
+		})
 		info.Maintainer = "Unset Maintainer <unset@localhost>"
 	}
 }
@@ -387,10 +448,16 @@ func fillDataTar(info *nfpm.Info, w io.Writer) (md5sums []byte, instSize int64,
 }
 
 func createFilesInsideDataTar(info *nfpm.Info, tw *tar.Writer) (md5buf bytes.Buffer, instSize int64, err error) {
+	// hard links are added last as their targets must come first in the tar
+	var hardlinks []*files.Content
+	digests := map[string][]byte{}
+
 	for _, file := range info.Contents {
 		switch file.Type {
 		case files.TypeRPMGhost:
 			continue // skip ghost files in deb
+		case files.TypeHardlink:
+			hardlinks = append(hardlinks, file)
 		case files.TypeDir, files.TypeImplicitDir:
 			header, err := tarHeader(file, info.MTime)
 			if err != nil {
@@ -403,6 +470,17 @@ func createFilesInsideDataTar(info *nfpm.Info, tw *tar.Writer) (md5buf bytes.Buf
 				return md5buf, 0, fmt.Errorf("create directory %q in data tar: %w",
 					header.Name, err)
 			}
+		case files.TypeCharDevice, files.TypeBlockDevice, files.TypeFIFO:
+			header, err := tarHeader(file, info.MTime)
+			if err != nil {
+				return md5buf, 0, fmt.Errorf("build %s header for %q: %w",
+					file.Type, file.Destination, err)
+			}
+
+			if err := tw.WriteHeader(header); err != nil {
+				return md5buf, 0, fmt.Errorf("create %s %q in data tar: %w",
+					file.Type, header.Name, err)
+			}
 		case files.TypeSymlink:
 			header, err := tarHeader(file, info.MTime)
 			if err != nil {
@@ -421,44 +499,68 @@ func createFilesInsideDataTar(info *nfpm.Info, tw *tar.Writer) (md5buf bytes.Buf
 				return md5buf, 0, fmt.Errorf("write changelog to data tar: %w", err)
 			}
 
+			instSize += size
+		case files.TypeCopyright:
+			size, err := createCopyrightInsideDataTar(tw, &md5buf, info, file.Destination)
+			if err != nil {
+				return md5buf, 0, fmt.Errorf("write copyright to data tar: %w", err)
+			}
+
 			instSize += size
 		default:
-			size, err := copyToTarAndDigest(file, tw, &md5buf)
+			size, digest, err := copyToTarAndDigest(file, tw, &md5buf)
 			if err != nil {
 				return md5buf, 0, fmt.Errorf("write %q to data tar: %w", file.Destination, err)
 			}
 
+			digests[file.Destination] = digest
 			instSize += size
 		}
 	}
 
+	for _, file := range hardlinks {
+		header, err := tarHeader(file, info.MTime)
+		if err != nil {
+			return md5buf, 0, fmt.Errorf("build hardlink header for %q: %w",
+				file.Destination, err)
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return md5buf, 0, fmt.Errorf("create hardlink %q in data tar: %w",
+				header.Name, err)
+		}
+		if _, err := fmt.Fprintf(&md5buf, "%x  %s\n", digests[file.Source], header.Name); err != nil {
+			return md5buf, 0, fmt.Errorf("%s: failed to write md5: %w", file.Destination, err)
+		}
+	}
+
 	return md5buf, instSize, nil
 }
 
-func copyToTarAndDigest(file *files.Content, tw *tar.Writer, md5w io.Writer) (int64, error) {
-	tarFile, err := os.OpenFile(file.Source, os.O_RDONLY, 0o600) //nolint:gosec
+func copyToTarAndDigest(file *files.Content, tw *tar.Writer, md5w io.Writer) (int64, []byte, error) {
+	tarFile, err := file.Open()
 	if err != nil {
-		return 0, fmt.Errorf("could not add tarFile to the archive: %w", err)
+		return 0, nil, fmt.Errorf("could not add tarFile to the archive: %w", err)
 	}
 	// don't care if it errs while closing...
 	defer tarFile.Close() // nolint: errcheck,gosec
 
 	header, err := tarHeader(file)
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 
 	if err := tw.WriteHeader(header); err != nil {
-		return 0, fmt.Errorf("cannot write header of %s to data.tar.gz: %w", file.Source, err)
+		return 0, nil, fmt.Errorf("cannot write header of %s to data.tar.gz: %w", file.Source, err)
 	}
 	digest := md5.New() // nolint:gas
 	if _, err := io.Copy(tw, io.TeeReader(tarFile, digest)); err != nil {
-		return 0, fmt.Errorf("%s: failed to copy: %w", file.Source, err)
+		return 0, nil, fmt.Errorf("%s: failed to copy: %w", file.Source, err)
 	}
 	if _, err := fmt.Fprintf(md5w, "%x  %s\n", digest.Sum(nil), header.Name); err != nil {
-		return 0, fmt.Errorf("%s: failed to write md5: %w", file.Source, err)
+		return 0, nil, fmt.Errorf("%s: failed to write md5: %w", file.Source, err)
 	}
-	return file.Size(), nil
+	return file.Size(), digest.Sum(nil), nil
 }
 
 func withChangelogIfRequested(info *nfpm.Info) *nfpm.Info {
@@ -508,26 +610,49 @@ func createChangelogInsideDataTar(
 	}
 
 	changelogData := buf.Bytes()
+	if err = newDigestedFileInsideTar(tarw, g, fileName, changelogData, modtime.Get(info.MTime)); err != nil {
+		return 0, err
+	}
 
-	digest := md5.New() // nolint:gas
-	if _, err = digest.Write(changelogData); err != nil {
+	return int64(len(changelogData)), nil
+}
+
+func createCopyrightInsideDataTar(
+	tarw *tar.Writer,
+	g io.Writer,
+	info *nfpm.Info,
+	fileName string,
+) (int64, error) {
+	copyrightData, err := info.FormatCopyright()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = newDigestedFileInsideTar(tarw, g, fileName, copyrightData, modtime.Get(info.MTime)); err != nil {
 		return 0, err
 	}
 
-	if _, err = fmt.Fprintf(
+	return int64(len(copyrightData)), nil
+}
+
+// newDigestedFileInsideTar adds a generated file to the data tar and writes
+// its md5sum to g.
+func newDigestedFileInsideTar(tarw *tar.Writer, g io.Writer, fileName string, data []byte, mtime time.Time) error {
+	digest := md5.New() // nolint:gas
+	if _, err := digest.Write(data); err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(
 		g,
 		"%x  %s\n",
 		digest.Sum(nil),
 		files.AsExplicitRelativePath(fileName),
 	); err != nil {
-		return 0, err
-	}
-
-	if err = newFileInsideTar(tarw, fileName, changelogData, modtime.Get(info.MTime)); err != nil {
-		return 0, err
+		return err
 	}
 
-	return int64(len(changelogData)), nil
+	return newFileInsideTar(tarw, fileName, data, mtime)
 }
 
 func formatChangelog(info *nfpm.Info) (string, error) {
@@ -561,7 +686,7 @@ func createControl(instSize int64, md5sums []byte, info *nfpm.Info) (controlTarG
 
 	var body bytes.Buffer
 	if err = writeControl(&body, controlData{
-		Info:          info,
+		Info:          withAdduserDependency(info),
 		InstalledSize: instSize / 1024,
 	}); err != nil {
 		return nil, err
@@ -584,12 +709,56 @@ func createControl(instSize int64, md5sums []byte, info *nfpm.Info) (controlTarG
 		}
 	}
 
-	type fileAndMode struct {
-		fileName string
-		mode     int64
+	specialFiles := specialFiles(info)
+
+	for _, filename := range maps.Keys(specialFiles) {
+		dets := specialFiles[filename]
+		if dets.before != "" || dets.after != "" {
+			content, err := nfpm.MergeScript(dets.fileName, dets.before, dets.after)
+			if err != nil {
+				return nil, err
+			}
+			if err := newItemInsideTar(out, content, &tar.Header{
+				Name:     files.AsExplicitRelativePath(filename),
+				Size:     int64(len(content)),
+				Mode:     dets.mode,
+				ModTime:  mtime,
+				Typeflag: tar.TypeReg,
+				Format:   tar.FormatGNU,
+			}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if dets.fileName == "" {
+			continue
+		}
+		if err := newFilePathInsideTar(out, dets.fileName, filename, dets.mode, mtime); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := out.Close(); err != nil {
+		return nil, fmt.Errorf("closing control.tar.gz: %w", err)
+	}
+	if err := compress.Close(); err != nil {
+		return nil, fmt.Errorf("closing control.tar.gz: %w", err)
 	}
+	return buf.Bytes(), nil
+}
 
-	specialFiles := map[string]*fileAndMode{
+type fileAndMode struct {
+	fileName string
+	mode     int64
+	// before and after are generated snippets running before and after the
+	// script.
+	before, after string
+}
+
+// specialFiles returns the maintainer scripts and debconf files by their
+// name inside the control archive.
+func specialFiles(info *nfpm.Info) map[string]*fileAndMode {
+	return map[string]*fileAndMode{
 		"preinst": {
 			fileName: info.Scripts.PreInstall,
 			mode:     0o755,
@@ -597,14 +766,18 @@ func createControl(instSize int64, md5sums []byte, info *nfpm.Info) (controlTarG
 		"postinst": {
 			fileName: info.Scripts.PostInstall,
 			mode:     0o755,
+			before:   usersScript(info) + alternativesPostinst(info),
+			after:    systemdPostinst(info),
 		},
 		"prerm": {
 			fileName: info.Scripts.PreRemove,
 			mode:     0o755,
+			before:   systemdPrerm(info) + alternativesPrerm(info),
 		},
 		"postrm": {
 			fileName: info.Scripts.PostRemove,
 			mode:     0o755,
+			after:    systemdPostrm(info),
 		},
 		"rules": {
 			fileName: info.Overridables.Deb.Scripts.Rules,
@@ -619,24 +792,180 @@ func createControl(instSize int64, md5sums []byte, info *nfpm.Info) (controlTarG
 			mode:     0o755,
 		},
 	}
+}
 
-	for _, filename := range maps.Keys(specialFiles) {
-		dets := specialFiles[filename]
-		if dets.fileName == "" {
-			continue
+// usersScript returns the postinst snippet creating the users and groups of
+// the package with adduser.
+func usersScript(info *nfpm.Info) string {
+	if len(info.Users) == 0 && len(info.Groups) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("if [ \"$1\" = \"configure\" ]; then\n")
+	for _, group := range info.Groups {
+		args := []string{"--system"}
+		if group.GID != 0 {
+			args = append(args, "--gid", strconv.FormatUint(uint64(group.GID), 10))
 		}
-		if err := newFilePathInsideTar(out, dets.fileName, filename, dets.mode, mtime); err != nil {
-			return nil, err
+		fmt.Fprintf(&b, "\tgetent group %[1]s >/dev/null || addgroup %[2]s %[1]s\n",
+			shell.Quote(group.Name), strings.Join(args, " "))
+	}
+	for _, user := range info.Users {
+		args := []string{"--system"}
+		if user.UID != 0 {
+			args = append(args, "--uid", strconv.FormatUint(uint64(user.UID), 10))
+		}
+		if user.Group != "" {
+			args = append(args, "--ingroup", shell.Quote(user.Group))
+		} else {
+			args = append(args, "--group")
+		}
+		if user.Home != "" {
+			args = append(args, "--home", shell.Quote(user.Home))
+		}
+		args = append(args, "--no-create-home")
+		if user.Shell != "" {
+			args = append(args, "--shell", shell.Quote(user.Shell))
+		}
+		if user.Description != "" {
+			args = append(args, "--gecos", shell.Quote(user.Description))
+		}
+		fmt.Fprintf(&b, "\tgetent passwd %[1]s >/dev/null || adduser %[2]s %[1]s\n",
+			shell.Quote(user.Name), strings.Join(args, " "))
+		for _, group := range user.Groups {
+			fmt.Fprintf(&b, "\tadduser --quiet %s %s\n", shell.Quote(user.Name), shell.Quote(group))
 		}
 	}
+	b.WriteString("fi\n")
+	return b.String()
+}
 
-	if err := out.Close(); err != nil {
-		return nil, fmt.Errorf("closing control.tar.gz: %w", err)
+// systemdPostinst returns the postinst snippet enabling and starting the
+// systemd units on install and restarting them on upgrade, following what
+// debhelper generates.
+func systemdPostinst(info *nfpm.Info) string {
+	if len(info.Systemd) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("if [ \"$1\" = \"configure\" ] || [ \"$1\" = \"abort-upgrade\" ] || [ \"$1\" = \"abort-deconfigure\" ] || [ \"$1\" = \"abort-remove\" ]; then\n")
+	b.WriteString("\tif [ -x /usr/bin/deb-systemd-helper ]; then\n")
+	fmt.Fprintf(&b, "\t\tdeb-systemd-helper unmask %s >/dev/null || true\n", systemd.Names(info.Systemd, nil))
+	for _, unit := range info.Systemd {
+		name := shell.Quote(unit.Name())
+		if unit.Enable {
+			fmt.Fprintf(&b, "\t\tif deb-systemd-helper --quiet was-enabled %[1]s; then\n"+
+				"\t\t\tdeb-systemd-helper enable %[1]s >/dev/null || true\n"+
+				"\t\telse\n"+
+				"\t\t\tdeb-systemd-helper update-state %[1]s >/dev/null || true\n"+
+				"\t\tfi\n", name)
+		} else {
+			fmt.Fprintf(&b, "\t\tdeb-systemd-helper update-state %s >/dev/null || true\n", name)
+		}
 	}
-	if err := compress.Close(); err != nil {
-		return nil, fmt.Errorf("closing control.tar.gz: %w", err)
+	b.WriteString("\tfi\n")
+	b.WriteString("\tif [ -d /run/systemd/system ]; then\n")
+	b.WriteString("\t\tsystemctl --system daemon-reload >/dev/null || true\n")
+	start := systemd.Names(info.Systemd, func(unit nfpm.SystemdUnit) bool { return unit.Start })
+	restart := systemd.Names(info.Systemd, func(unit nfpm.SystemdUnit) bool { return unit.RestartOnUpgrade })
+	if start != "" {
+		fmt.Fprintf(&b, "\t\tif [ -z \"$2\" ]; then\n\t\t\tdeb-systemd-invoke start %s >/dev/null || true\n\t\tfi\n", start)
 	}
-	return buf.Bytes(), nil
+	if restart != "" {
+		fmt.Fprintf(&b, "\t\tif [ -n \"$2\" ]; then\n\t\t\tdeb-systemd-invoke try-restart %s >/dev/null || true\n\t\tfi\n", restart)
+	}
+	b.WriteString("\tfi\n")
+	b.WriteString("fi\n")
+	return b.String()
+}
+
+// systemdPrerm returns the prerm snippet stopping the systemd units when the
+// package is removed.
+func systemdPrerm(info *nfpm.Info) string {
+	if len(info.Systemd) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("if [ -d /run/systemd/system ] && [ \"$1\" = \"remove\" ]; then\n"+
+		"\tdeb-systemd-invoke stop %s >/dev/null || true\n"+
+		"fi\n", systemd.Names(info.Systemd, nil))
+}
+
+// systemdPostrm returns the postrm snippet masking the systemd units when the
+// package is removed and forgetting about them when it is purged.
+func systemdPostrm(info *nfpm.Info) string {
+	if len(info.Systemd) == 0 {
+		return ""
+	}
+	names := systemd.Names(info.Systemd, nil)
+	return fmt.Sprintf("if [ -d /run/systemd/system ]; then\n"+
+		"\tsystemctl --system daemon-reload >/dev/null || true\n"+
+		"fi\n"+
+		"if [ \"$1\" = \"remove\" ] && [ -x /usr/bin/deb-systemd-helper ]; then\n"+
+		"\tdeb-systemd-helper mask %[1]s >/dev/null || true\n"+
+		"fi\n"+
+		"if [ \"$1\" = \"purge\" ] && [ -x /usr/bin/deb-systemd-helper ]; then\n"+
+		"\tdeb-systemd-helper purge %[1]s >/dev/null || true\n"+
+		"\tdeb-systemd-helper unmask %[1]s >/dev/null || true\n"+
+		"fi\n", names)
+}
+
+// alternativesPostinst returns the postinst snippet adding the alternatives
+// of the package.
+func alternativesPostinst(info *nfpm.Info) string {
+	if len(info.Alternatives) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("if [ \"$1\" = \"configure\" ] || [ \"$1\" = \"abort-upgrade\" ] || [ \"$1\" = \"abort-deconfigure\" ] || [ \"$1\" = \"abort-remove\" ]; then\n")
+	for _, alternative := range info.Alternatives {
+		fmt.Fprintf(&b, "\tupdate-alternatives --install %s %s %s %d\n",
+			shell.Quote(alternative.LinkName),
+			shell.Quote(alternative.GenericName()),
+			shell.Quote(alternative.Target),
+			alternative.Priority,
+		)
+	}
+	b.WriteString("fi\n")
+	return b.String()
+}
+
+// alternativesPrerm returns the prerm snippet removing the alternatives of
+// the package.
+func alternativesPrerm(info *nfpm.Info) string {
+	if len(info.Alternatives) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("if [ \"$1\" = \"remove\" ] || [ \"$1\" = \"deconfigure\" ]; then\n")
+	for _, alternative := range info.Alternatives {
+		fmt.Fprintf(&b, "\tupdate-alternatives --remove %s %s\n",
+			shell.Quote(alternative.GenericName()),
+			shell.Quote(alternative.Target),
+		)
+	}
+	b.WriteString("fi\n")
+	return b.String()
+}
+
+// withAdduserDependency returns the info with a dependency on adduser if
+// the maintainer scripts create users or groups.
+func withAdduserDependency(info *nfpm.Info) *nfpm.Info {
+	if len(info.Users) == 0 && len(info.Groups) == 0 {
+		return info
+	}
+	for _, dep := range info.Depends {
+		if name, _, _ := strings.Cut(strings.TrimSpace(dep), " "); name == "adduser" {
+			return info
+		}
+	}
+
+	withAdduser := *info
+	withAdduser.Depends = append(append([]string{}, info.Depends...), "adduser")
+	return &withAdduser
 }
 
 func newItemInsideTar(out *tar.Writer, content []byte, header *tar.Header) error {
@@ -773,7 +1102,12 @@ type controlData struct {
 
 func writeControl(w io.Writer, data controlData) error {
 	tmpl := template.New("control")
-	tmpl.Funcs(template.FuncMap{
+	tmpl.Funcs(controlFuncs())
+	return template.Must(tmpl.Parse(controlTemplate)).Execute(w, data)
+}
+
+func controlFuncs() template.FuncMap {
+	return template.FuncMap{
 		"join": func(strs []string) string {
 			return strings.Trim(strings.Join(strs, ", "), " ")
 		},
@@ -804,8 +1138,7 @@ func writeControl(w io.Writer, data controlData) error {
 			}
 			return result
 		},
-	})
-	return template.Must(tmpl.Parse(controlTemplate)).Execute(w, data)
+	}
 }
 
 func tarHeader(content *files.Content, preferredModTimes ...time.Time) (*tar.Header, error) {
@@ -824,6 +1157,8 @@ func tarHeader(content *files.Content, preferredModTimes ...time.Time) (*tar.Hea
 		Mode:   int64(fm & 0o7777),
 		Uname:  content.FileInfo.Owner,
 		Gname:  content.FileInfo.Group,
+		Uid:    int(content.FileInfo.UID),
+		Gid:    int(content.FileInfo.GID),
 		Format: tar.FormatGNU,
 	}
 
@@ -831,18 +1166,28 @@ func tarHeader(content *files.Content, preferredModTimes ...time.Time) (*tar.Hea
 	case content.IsDir() || fm&fs.ModeDir != 0:
 		h.Typeflag = tar.TypeDir
 		h.Name = files.AsExplicitRelativePath(content.Destination)
+	case content.Type == files.TypeHardlink:
+		h.Typeflag = tar.TypeLink
+		h.Name = files.AsExplicitRelativePath(content.Destination)
+		h.Linkname = files.AsExplicitRelativePath(content.Source)
 	case content.Type == files.TypeSymlink || fm&fs.ModeSymlink != 0:
 		h.Typeflag = tar.TypeSymlink
 		h.Name = files.AsExplicitRelativePath(content.Destination)
 		h.Linkname = content.Source
+	case content.Type == files.TypeCharDevice || content.Type == files.TypeBlockDevice || content.Type == files.TypeFIFO:
+		h.Typeflag = files.SpecialFileTypeflag(content)
+		h.Name = files.AsExplicitRelativePath(content.Destination)
+		h.Devmajor = int64(content.FileInfo.DevMajor)
+		h.Devminor = int64(content.FileInfo.DevMinor)
+	case fm&fs.ModeCharDevice != 0:
+		h.Typeflag = tar.TypeChar
+		h.Name = files.AsExplicitRelativePath(content.Destination)
 	case fm&fs.ModeDevice != 0:
-		if fm&fs.ModeCharDevice != 0 {
-			h.Typeflag = tar.TypeChar
-		} else {
-			h.Typeflag = tar.TypeBlock
-		}
+		h.Typeflag = tar.TypeBlock
+		h.Name = files.AsExplicitRelativePath(content.Destination)
 	case fm&fs.ModeNamedPipe != 0:
 		h.Typeflag = tar.TypeFifo
+		h.Name = files.AsExplicitRelativePath(content.Destination)
 	case fm&fs.ModeSocket != 0:
 		return nil, fmt.Errorf("archive/tar: sockets not supported")
 	default:
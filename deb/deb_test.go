@@ -639,6 +639,255 @@ func TestDebNoChangelogDataWithoutChangelogConfigured(t *testing.T) {
 	require.False(t, tarContains(t, inflate(t, dataTarballName, dataTarball), changelogName))
 }
 
+func TestDebCopyright(t *testing.T) {
+	info := exampleInfo()
+	info.Copyright.Files = []nfpm.CopyrightFiles{{
+		Paths:     []string{"*"},
+		Copyright: []string{"2023 Carlos A Becker"},
+		License:   "MIT",
+	}}
+	err := nfpm.PrepareForPackager(nfpm.WithCopyrightIfRequested(info, "/usr/share/doc/foo/copyright"), packagerName)
+	require.NoError(t, err)
+
+	dataTarball, md5sums, _, dataTarballName, err := createDataTarball(info)
+	require.NoError(t, err)
+
+	expected, err := info.FormatCopyright()
+	require.NoError(t, err)
+	dataTar := inflate(t, dataTarballName, dataTarball)
+	require.Equal(t, expected, extractFileFromTar(t, dataTar, "/usr/share/doc/foo/copyright"))
+	require.Equal(t, int64(0o644), extractFileHeaderFromTar(t, dataTar, "/usr/share/doc/foo/copyright").Mode)
+	require.Contains(t, string(md5sums), fmt.Sprintf("%x  ./usr/share/doc/foo/copyright\n", md5.Sum(expected))) // nolint:gosec
+}
+
+func TestDebNoCopyrightWithoutFilesConfigured(t *testing.T) {
+	info := exampleInfo()
+	info.Copyright.UpstreamName = "bar"
+	var buf bytes.Buffer
+	require.NoError(t, Default.Package(info, &buf))
+
+	dataTarballName := findDataTarball(t, buf.Bytes())
+	dataTar := inflate(t, dataTarballName, extractFileFromAr(t, buf.Bytes(), dataTarballName))
+	require.False(t, tarContains(t, dataTar, "/usr/share/doc/foo/copyright"))
+}
+
+func TestDebManPage(t *testing.T) {
+	info := exampleInfo()
+	info.Contents = append(info.Contents, &files.Content{
+		Source: "../files/testdata/man/foo.1",
+		Type:   files.TypeManPage,
+	})
+	require.NoError(t, nfpm.PrepareForPackager(info, packagerName))
+
+	dataTarball, _, _, dataTarballName, err := createDataTarball(info)
+	require.NoError(t, err)
+
+	manPage := inflate(t, "gz", extractFileFromTar(t,
+		inflate(t, dataTarballName, dataTarball), "/usr/share/man/man1/foo.1.gz"))
+	expected, err := os.ReadFile("../files/testdata/man/foo.1")
+	require.NoError(t, err)
+	require.Equal(t, expected, manPage)
+}
+
+func TestDebHardlink(t *testing.T) {
+	info := exampleInfo()
+	info.Contents = append(info.Contents, &files.Content{
+		Source:      "/usr/bin/fake",
+		Destination: "/usr/bin/a-fake",
+		Type:        files.TypeHardlink,
+	})
+	require.NoError(t, nfpm.PrepareForPackager(info, packagerName))
+
+	dataTarball, md5sums, _, dataTarballName, err := createDataTarball(info)
+	require.NoError(t, err)
+
+	dataTar := inflate(t, dataTarballName, dataTarball)
+	header := extractFileHeaderFromTar(t, dataTar, "/usr/bin/a-fake")
+	require.Equal(t, byte(tar.TypeLink), header.Typeflag)
+	require.Equal(t, "./usr/bin/fake", header.Linkname)
+	require.Equal(t, int64(0), header.Size)
+
+	var names []string
+	tr := tar.NewReader(bytes.NewReader(dataTar))
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+	}
+	require.Equal(t, "./usr/bin/a-fake", names[len(names)-1], "hard links must follow their targets")
+
+	digest := md5.New() // nolint:gosec
+	_, err = digest.Write(extractFileFromTar(t, dataTar, "/usr/bin/fake"))
+	require.NoError(t, err)
+	require.Contains(t, string(md5sums), hex.EncodeToString(digest.Sum(nil))+"  ./usr/bin/a-fake\n")
+}
+
+func TestDebSpecialFiles(t *testing.T) {
+	info := exampleInfo()
+	info.Contents = append(info.Contents, &files.Content{
+		Destination: "/dev/null",
+		Type:        files.TypeCharDevice,
+		FileInfo:    &files.ContentFileInfo{Mode: 0o666, DevMajor: 1, DevMinor: 3},
+	}, &files.Content{
+		Destination: "/dev/loop0",
+		Type:        files.TypeBlockDevice,
+		FileInfo:    &files.ContentFileInfo{Mode: 0o660, DevMajor: 7},
+	}, &files.Content{
+		Destination: "/run/foo.fifo",
+		Type:        files.TypeFIFO,
+	})
+	require.NoError(t, nfpm.PrepareForPackager(info, packagerName))
+
+	dataTarball, md5sums, _, dataTarballName, err := createDataTarball(info)
+	require.NoError(t, err)
+	require.NotContains(t, string(md5sums), "dev/null")
+
+	dataTar := inflate(t, dataTarballName, dataTarball)
+	null := extractFileHeaderFromTar(t, dataTar, "/dev/null")
+	require.Equal(t, byte(tar.TypeChar), null.Typeflag)
+	require.Equal(t, "./dev/null", null.Name)
+	require.Equal(t, int64(0o666), null.Mode)
+	require.Equal(t, int64(1), null.Devmajor)
+	require.Equal(t, int64(3), null.Devminor)
+
+	loop := extractFileHeaderFromTar(t, dataTar, "/dev/loop0")
+	require.Equal(t, byte(tar.TypeBlock), loop.Typeflag)
+	require.Equal(t, int64(7), loop.Devmajor)
+
+	require.Equal(t, byte(tar.TypeFifo), extractFileHeaderFromTar(t, dataTar, "/run/foo.fifo").Typeflag)
+}
+
+func TestDebUsers(t *testing.T) {
+	info := exampleInfo()
+	info.Scripts.PostInstall = "../testdata/scripts/postinstall.sh"
+	info.Groups = []nfpm.Group{{Name: "foo-data", GID: 950}}
+	info.Users = []nfpm.User{
+		{Name: "foo", UID: 950, Group: "foo-data", Groups: []string{"adm"}, Home: "/var/lib/foo", Description: "Foo's daemon"},
+		{Name: "bar"},
+	}
+	info.Contents = append(info.Contents, &files.Content{
+		Destination: "/var/lib/foo",
+		Type:        files.TypeDir,
+		FileInfo:    &files.ContentFileInfo{Owner: "foo", Group: "foo-data", UID: 950, GID: 950},
+	})
+	require.NoError(t, nfpm.PrepareForPackager(info, packagerName))
+
+	dataTarball, md5sums, instSize, dataTarballName, err := createDataTarball(info)
+	require.NoError(t, err)
+	dataTar := inflate(t, dataTarballName, dataTarball)
+	header := extractFileHeaderFromTar(t, dataTar, "/var/lib/foo")
+	require.Equal(t, "foo", header.Uname)
+	require.Equal(t, 950, header.Uid)
+	require.Equal(t, 950, header.Gid)
+	require.Equal(t, string(info.FormatSysusers()),
+		string(extractFileFromTar(t, dataTar, "/usr/lib/sysusers.d/foo.conf")))
+
+	controlTarGz, err := createControl(instSize, md5sums, info)
+	require.NoError(t, err)
+	controlTar := inflate(t, "gz", controlTarGz)
+	require.Contains(t, string(extractFileFromTar(t, controlTar, "control")), "Depends: bash, adduser\n")
+	require.Equal(t, `#!/bin/bash
+if [ "$1" = "configure" ]; then
+	getent group 'foo-data' >/dev/null || addgroup --system --gid 950 'foo-data'
+	getent passwd 'foo' >/dev/null || adduser --system --uid 950 --ingroup 'foo-data' --home '/var/lib/foo' --no-create-home --gecos 'Foo'\''s daemon' 'foo'
+	adduser --quiet 'foo' 'adm'
+	getent passwd 'bar' >/dev/null || adduser --system --group --no-create-home 'bar'
+fi
+
+echo "Postinstall" > /dev/null
+`, string(extractFileFromTar(t, controlTar, "postinst")))
+}
+
+func TestDebSystemd(t *testing.T) {
+	info := exampleInfo()
+	info.Scripts.PostInstall = ""
+	info.Scripts.PreRemove = "../testdata/scripts/preremove.sh"
+	info.Scripts.PostRemove = "../testdata/scripts/postremove.sh"
+	info.Systemd = []nfpm.SystemdUnit{
+		{Source: "../testdata/systemd/foo.service", Enable: true, Start: true, RestartOnUpgrade: true},
+		{Source: "../testdata/systemd/foo-cleanup.timer"},
+	}
+	require.NoError(t, nfpm.PrepareForPackager(info, packagerName))
+
+	dataTarball, md5sums, instSize, dataTarballName, err := createDataTarball(info)
+	require.NoError(t, err)
+	dataTar := inflate(t, dataTarballName, dataTarball)
+	unit, err := os.ReadFile("../testdata/systemd/foo.service")
+	require.NoError(t, err)
+	require.Equal(t, unit, extractFileFromTar(t, dataTar, "/usr/lib/systemd/system/foo.service"))
+	require.Equal(t, int64(0o644), extractFileHeaderFromTar(t, dataTar, "/usr/lib/systemd/system/foo-cleanup.timer").Mode)
+
+	controlTarGz, err := createControl(instSize, md5sums, info)
+	require.NoError(t, err)
+	controlTar := inflate(t, "gz", controlTarGz)
+	require.Equal(t, `#!/bin/sh
+if [ "$1" = "configure" ] || [ "$1" = "abort-upgrade" ] || [ "$1" = "abort-deconfigure" ] || [ "$1" = "abort-remove" ]; then
+	if [ -x /usr/bin/deb-systemd-helper ]; then
+		deb-systemd-helper unmask 'foo.service' 'foo-cleanup.timer' >/dev/null || true
+		if deb-systemd-helper --quiet was-enabled 'foo.service'; then
+			deb-systemd-helper enable 'foo.service' >/dev/null || true
+		else
+			deb-systemd-helper update-state 'foo.service' >/dev/null || true
+		fi
+		deb-systemd-helper update-state 'foo-cleanup.timer' >/dev/null || true
+	fi
+	if [ -d /run/systemd/system ]; then
+		systemctl --system daemon-reload >/dev/null || true
+		if [ -z "$2" ]; then
+			deb-systemd-invoke start 'foo.service' >/dev/null || true
+		fi
+		if [ -n "$2" ]; then
+			deb-systemd-invoke try-restart 'foo.service' >/dev/null || true
+		fi
+	fi
+fi
+`, string(extractFileFromTar(t, controlTar, "postinst")))
+
+	prerm := string(extractFileFromTar(t, controlTar, "prerm"))
+	require.True(t, strings.HasPrefix(prerm, `#!/bin/bash
+if [ -d /run/systemd/system ] && [ "$1" = "remove" ]; then
+	deb-systemd-invoke stop 'foo.service' 'foo-cleanup.timer' >/dev/null || true
+fi
+`), prerm)
+	require.Contains(t, prerm, `echo "Preremove" > /dev/null`)
+
+	postrm := string(extractFileFromTar(t, controlTar, "postrm"))
+	require.Contains(t, postrm, "\n) || exit $?\n")
+	require.Contains(t, postrm, "\tdeb-systemd-helper purge 'foo.service' 'foo-cleanup.timer' >/dev/null || true\n")
+}
+
+func TestDebAlternatives(t *testing.T) {
+	info := exampleInfo()
+	info.Scripts.PreRemove = ""
+	info.Scripts.PostInstall = "../testdata/scripts/postinstall.sh"
+	info.Alternatives = []nfpm.Alternative{
+		{LinkName: "/usr/bin/java", Target: "/usr/lib/jvm/java-17/bin/java", Priority: 1700},
+		{Name: "javac-17", LinkName: "/usr/bin/javac", Target: "/usr/lib/jvm/java-17/bin/javac", Priority: 1700},
+	}
+	require.NoError(t, nfpm.PrepareForPackager(info, packagerName))
+
+	controlTarGz, err := createControl(0, nil, info)
+	require.NoError(t, err)
+	controlTar := inflate(t, "gz", controlTarGz)
+	require.Equal(t, `#!/bin/bash
+if [ "$1" = "configure" ] || [ "$1" = "abort-upgrade" ] || [ "$1" = "abort-deconfigure" ] || [ "$1" = "abort-remove" ]; then
+	update-alternatives --install '/usr/bin/java' 'java' '/usr/lib/jvm/java-17/bin/java' 1700
+	update-alternatives --install '/usr/bin/javac' 'javac-17' '/usr/lib/jvm/java-17/bin/javac' 1700
+fi
+
+echo "Postinstall" > /dev/null
+`, string(extractFileFromTar(t, controlTar, "postinst")))
+	require.Equal(t, `#!/bin/sh
+if [ "$1" = "remove" ] || [ "$1" = "deconfigure" ]; then
+	update-alternatives --remove 'java' '/usr/lib/jvm/java-17/bin/java'
+	update-alternatives --remove 'javac-17' '/usr/lib/jvm/java-17/bin/javac'
+fi
+`, string(extractFileFromTar(t, controlTar, "prerm")))
+}
+
 func TestDebTriggers(t *testing.T) {
 	info := &nfpm.Info{
 		Name:        "no-triggers-test",
@@ -962,6 +1211,39 @@ func TestDebsigsSignature(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestDebsigsSignatureKeys(t *testing.T) {
+	info := exampleInfo()
+	info.Deb.Signature.KeyFile = "../internal/sign/testdata/privkey.asc"
+	info.Deb.Signature.KeyPassphrase = "hunter2"
+	info.Deb.Signature.Keys = []nfpm.DebSignatureKey{
+		{PackageSignature: nfpm.PackageSignature{KeyFile: "../internal/sign/testdata/privkey_second.asc"}},
+		{PackageSignature: nfpm.PackageSignature{KeyFile: "../internal/sign/testdata/privkey_second.asc"}, Type: "maint"},
+	}
+
+	var deb bytes.Buffer
+	require.NoError(t, Default.Package(info, &deb))
+
+	debBinary := extractFileFromAr(t, deb.Bytes(), "debian-binary")
+	controlTarGz := extractFileFromAr(t, deb.Bytes(), "control.tar.gz")
+	dataTarball := extractFileFromAr(t, deb.Bytes(), findDataTarball(t, deb.Bytes()))
+	message := func() io.Reader {
+		return io.MultiReader(bytes.NewReader(debBinary),
+			bytes.NewReader(controlTarGz), bytes.NewReader(dataTarball))
+	}
+
+	origin := extractFileFromAr(t, deb.Bytes(), "_gpgorigin")
+	require.NoError(t, sign.PGPVerify(message(), origin, "../internal/sign/testdata/pubkey.asc"))
+	require.NoError(t, sign.PGPVerify(message(), origin, "../internal/sign/testdata/pubkey_second.asc"))
+
+	maint := extractFileFromAr(t, deb.Bytes(), "_gpgmaint")
+	require.NoError(t, sign.PGPVerify(message(), maint, "../internal/sign/testdata/pubkey_second.asc"))
+	require.Error(t, sign.PGPVerify(message(), maint, "../internal/sign/testdata/pubkey.asc"))
+
+	info.Deb.Signature.Method = "dpkg-sig"
+	info.Deb.Signature.Keys = info.Deb.Signature.Keys[:1]
+	require.ErrorContains(t, Default.Package(info, io.Discard), "dpkg-sig supports a single key per role")
+}
+
 func TestDebsigsSignatureError(t *testing.T) {
 	info := exampleInfo()
 	info.Deb.Signature.KeyFile = "/does/not/exist"
@@ -996,6 +1278,51 @@ func TestDebsigsSignatureCallback(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestSign(t *testing.T) {
+	info := exampleInfo()
+
+	var deb bytes.Buffer
+	require.NoError(t, Default.Package(info, &deb))
+	require.ErrorIs(t, Default.Sign(info, bytes.NewReader(deb.Bytes()), io.Discard), nfpm.ErrNoSigningKey)
+
+	info.Deb.Signature.KeyFile = "../internal/sign/testdata/privkey.asc"
+	info.Deb.Signature.KeyPassphrase = "hunter2"
+
+	var signed bytes.Buffer
+	require.NoError(t, Default.Sign(info, bytes.NewReader(deb.Bytes()), &signed))
+
+	// signing again replaces the signature
+	var resigned bytes.Buffer
+	require.NoError(t, Default.Sign(info, bytes.NewReader(signed.Bytes()), &resigned))
+
+	var names []string
+	tr := ar.NewReader(bytes.NewReader(resigned.Bytes()))
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+	}
+	require.Equal(t, []string{"debian-binary", "control.tar.gz", findDataTarball(t, deb.Bytes()), "_gpgorigin"}, names)
+
+	debBinary := extractFileFromAr(t, resigned.Bytes(), "debian-binary")
+	controlTarGz := extractFileFromAr(t, resigned.Bytes(), "control.tar.gz")
+	dataTarball := extractFileFromAr(t, resigned.Bytes(), findDataTarball(t, resigned.Bytes()))
+	signature := extractFileFromAr(t, resigned.Bytes(), "_gpgorigin")
+	require.Equal(t, extractFileFromAr(t, deb.Bytes(), "control.tar.gz"), controlTarGz)
+
+	message := io.MultiReader(bytes.NewReader(debBinary),
+		bytes.NewReader(controlTarGz), bytes.NewReader(dataTarball))
+
+	err := sign.PGPVerify(message, signature, "../internal/sign/testdata/pubkey.asc")
+	require.NoError(t, err)
+
+	err = Default.Sign(info, strings.NewReader("not a deb"), io.Discard)
+	require.Error(t, err)
+}
+
 func TestDpkgSigSignature(t *testing.T) {
 	info := exampleInfo()
 	info.Deb.Signature.KeyFile = "../internal/sign/testdata/privkey.asc"
@@ -0,0 +1,607 @@
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5" // nolint:gas
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/maps"
+	"github.com/goreleaser/nfpm/v2/internal/modtime"
+	"github.com/goreleaser/nfpm/v2/internal/sign"
+	"github.com/ulikunitz/xz"
+)
+
+const dscPackagerName = "dsc"
+
+// nolint: gochecknoinits
+func init() {
+	nfpm.RegisterPackager(dscPackagerName, DefaultDSC)
+}
+
+// DefaultDSC Debian source packager.
+// nolint: gochecknoglobals
+var DefaultDSC = &DSC{}
+
+// DSC is a Debian source packager implementation. It writes the .dsc file to
+// the given writer and the .orig.tar.* and .debian.tar.xz tarballs next to
+// the info target.
+type DSC struct{}
+
+// ErrNoTarget happens if a source package is created without a target path,
+// which is needed to know where to write the tarballs next to the .dsc file.
+var ErrNoTarget = errors.New("a target is required to create debian source packages")
+
+const dscStandardsVersion = "4.6.2"
+
+// ConventionalFileName returns a file name according
+// to the conventions for debian source packages.
+func (*DSC) ConventionalFileName(info *nfpm.Info) string {
+	return fmt.Sprintf("%s_%s.dsc", info.Name, sourceVersion(info))
+}
+
+// ConventionalExtension returns the file name conventionally used for Debian
+// source packages.
+func (*DSC) ConventionalExtension() string {
+	return ".dsc"
+}
+
+// upstreamVersion returns the version without epoch and debian revision.
+func upstreamVersion(info *nfpm.Info) string {
+	version := info.Version
+	if info.Prerelease != "" {
+		version += "~" + info.Prerelease
+	}
+	if info.VersionMetadata != "" {
+		version += "+" + info.VersionMetadata
+	}
+	return version
+}
+
+// debianRevision returns the debian revision, which is mandatory for source
+// packages in the 3.0 (quilt) format.
+func debianRevision(info *nfpm.Info) string {
+	if info.Release != "" {
+		return info.Release
+	}
+	return "1"
+}
+
+// sourceVersion returns the version without epoch as used in file names.
+func sourceVersion(info *nfpm.Info) string {
+	return upstreamVersion(info) + "-" + debianRevision(info)
+}
+
+// AdditionalFiles returns the paths of the tarballs written next to the .dsc
+// file.
+func (*DSC) AdditionalFiles(info *nfpm.Info) ([]string, error) {
+	origName, err := origTarballName(info)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(info.Target)
+	return []string{
+		filepath.Join(dir, origName),
+		filepath.Join(dir, debianTarballName(info)),
+	}, nil
+}
+
+// Package writes a new .dsc file to the given writer and the tarballs it
+// references next to info.Target.
+func (d *DSC) Package(info *nfpm.Info, dsc io.Writer) error {
+	if info.Target == "" {
+		return ErrNoTarget
+	}
+
+	info = ensureValidArch(info)
+
+	if err := nfpm.PrepareForPackager(info, dscPackagerName); err != nil {
+		return err
+	}
+
+	Default.SetPackagerDefaults(info)
+
+	dir := filepath.Dir(info.Target)
+	origName, err := origTarballName(info)
+	if err != nil {
+		return err
+	}
+	debianName := debianTarballName(info)
+
+	origTarball, err := createOrigTarball(info, origName)
+	if err != nil {
+		return err
+	}
+
+	debianTarball, err := createDebianTarball(info)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	if err := writeDSC(&body, dscData{
+		Info:    info,
+		Version: dscVersion(info),
+		Files: []dscFile{
+			newDSCFile(origName, origTarball),
+			newDSCFile(debianName, debianTarball),
+		},
+	}); err != nil {
+		return err
+	}
+
+	content, err := signDSC(info.Deb.Signature, &body)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, origName), origTarball, 0o644); err != nil {
+		return fmt.Errorf("cannot write %s: %w", origName, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, debianName), debianTarball, 0o644); err != nil {
+		return fmt.Errorf("cannot write %s: %w", debianName, err)
+	}
+
+	_, err = dsc.Write(content)
+	return err
+}
+
+// signDSC returns the .dsc clear-signed with the given signature, or as is if
+// no signature is configured.
+func signDSC(signature nfpm.DebSignature, body *bytes.Buffer) ([]byte, error) {
+	if !signature.IsSet() {
+		return body.Bytes(), nil
+	}
+	if len(signature.Keys) > 0 {
+		return nil, &nfpm.ErrSigningFailure{
+			Err: errors.New("debian source packages are signed by a single key, additional signing keys are not supported"),
+		}
+	}
+
+	var (
+		content []byte
+		err     error
+	)
+	if signFn := sign.SignFn(signature.PackageSignature, sign.PGPClearSign); signFn != nil {
+		content, err = signFn(body)
+	} else {
+		content, err = sign.PGPClearSignWithKeyID(body, signature.KeyFile, signature.KeyPassphrase, signature.KeyID)
+	}
+	if err != nil {
+		return nil, &nfpm.ErrSigningFailure{Err: err}
+	}
+	return content, nil
+}
+
+func dscVersion(info *nfpm.Info) string {
+	if info.Epoch != "" {
+		return info.Epoch + ":" + sourceVersion(info)
+	}
+	return sourceVersion(info)
+}
+
+func debianTarballName(info *nfpm.Info) string {
+	return fmt.Sprintf("%s_%s.debian.tar.xz", info.Name, sourceVersion(info))
+}
+
+func origTarballName(info *nfpm.Info) (string, error) {
+	name := fmt.Sprintf("%s_%s.orig.tar", info.Name, upstreamVersion(info))
+	switch info.Deb.Compression {
+	case "", "gzip":
+		return name + ".gz", nil
+	case "xz":
+		return name + ".xz", nil
+	default:
+		return "", fmt.Errorf("compression algorithm not supported by debian source packages: %s", info.Deb.Compression)
+	}
+}
+
+// createOrigTarball creates the upstream tarball with all contents placed
+// relative to a single top level directory.
+func createOrigTarball(info *nfpm.Info, name string) ([]byte, error) {
+	var (
+		buf bytes.Buffer
+		wc  io.WriteCloser
+		err error
+	)
+	if strings.HasSuffix(name, ".xz") {
+		wc, err = xz.NewWriter(&buf)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		wc = gzip.NewWriter(&buf)
+	}
+	// the writers are properly closed later, this is just in case that we have
+	// an error in another part of the code.
+	defer wc.Close() // nolint: errcheck
+
+	tw := tar.NewWriter(wc)
+	defer tw.Close() // nolint: errcheck
+
+	prefix := fmt.Sprintf("%s-%s", info.Name, upstreamVersion(info))
+	// hard links are added last as their targets must come first in the tar
+	contents := make(files.Contents, 0, len(info.Contents))
+	var hardlinks files.Contents
+	for _, file := range info.Contents {
+		if file.Type == files.TypeHardlink {
+			hardlinks = append(hardlinks, file)
+			continue
+		}
+		contents = append(contents, file)
+	}
+
+	for _, file := range append(contents, hardlinks...) {
+		switch file.Type {
+		case files.TypeRPMGhost, files.TypeDebChangelog:
+			continue
+		case files.TypeCharDevice, files.TypeBlockDevice:
+			// unpacking the source must not require root privileges
+			return nil, fmt.Errorf("%s: device nodes are not supported in source packages", file.Destination)
+		}
+
+		rel := strings.Trim(files.AsRelativePath(file.Destination), "/")
+		if rel == "" {
+			continue
+		}
+
+		header, err := tarHeader(file, info.MTime)
+		if err != nil {
+			return nil, err
+		}
+		header.Name = path.Join(prefix, rel)
+		if header.Typeflag == tar.TypeDir {
+			header.Name += "/"
+		}
+		if header.Typeflag == tar.TypeLink {
+			header.Linkname = path.Join(prefix, strings.Trim(files.AsRelativePath(file.Source), "/"))
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, fmt.Errorf("cannot write header of %s to %s: %w", header.Name, name, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := copyFile(tw, file); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("closing %s: %w", name, err)
+	}
+	if err := wc.Close(); err != nil {
+		return nil, fmt.Errorf("closing %s: %w", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func copyFile(w io.Writer, file *files.Content) error {
+	f, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("could not add file to the archive: %w", err)
+	}
+	// don't care if it errs while closing...
+	defer f.Close() // nolint: errcheck,gosec
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("%s: failed to copy: %w", file.Source, err)
+	}
+	return nil
+}
+
+// createDebianTarball creates the debian directory of the source package.
+func createDebianTarball(info *nfpm.Info) ([]byte, error) { // nolint: funlen
+	var buf bytes.Buffer
+	compress, err := xz.NewWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+	out := tar.NewWriter(compress)
+	// the writers are properly closed later, this is just in case that we have
+	// an error in another part of the code.
+	defer out.Close()      // nolint: errcheck
+	defer compress.Close() // nolint: errcheck
+
+	mtime := modtime.Get(info.MTime)
+
+	var control bytes.Buffer
+	if err := writeSourceControl(&control, info); err != nil {
+		return nil, err
+	}
+
+	changelog, err := sourceChangelog(info)
+	if err != nil {
+		return nil, err
+	}
+
+	copyright, err := info.FormatCopyright()
+	if err != nil {
+		return nil, err
+	}
+
+	debianFiles := []debianFile{
+		{"debian/control", control.Bytes()},
+		{"debian/changelog", []byte(changelog)},
+		{"debian/copyright", copyright},
+		{"debian/source/format", []byte("3.0 (quilt)\n")},
+		{"debian/install", sourceInstall(info)},
+	}
+	if confs := sourceConffiles(info); len(confs) > 0 {
+		debianFiles = append(debianFiles, debianFile{"debian/conffiles", confs})
+	}
+	if triggers := createTriggers(info); len(triggers) > 0 {
+		debianFiles = append(debianFiles, debianFile{"debian/triggers", triggers})
+	}
+
+	for _, dir := range []string{"debian/", "debian/source/"} {
+		if err := out.WriteHeader(&tar.Header{
+			Name:     dir,
+			Mode:     0o755,
+			ModTime:  mtime,
+			Typeflag: tar.TypeDir,
+			Format:   tar.FormatGNU,
+		}); err != nil {
+			return nil, fmt.Errorf("cannot write header of %s to debian.tar.xz: %w", dir, err)
+		}
+	}
+
+	for _, file := range debianFiles {
+		if err := newItemInsideTar(out, file.content, &tar.Header{
+			Name:     file.name,
+			Size:     int64(len(file.content)),
+			Mode:     0o644,
+			ModTime:  mtime,
+			Typeflag: tar.TypeReg,
+			Format:   tar.FormatGNU,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if info.Deb.Scripts.Rules == "" {
+		if err := newItemInsideTar(out, []byte(defaultRules), &tar.Header{
+			Name:     "debian/rules",
+			Size:     int64(len(defaultRules)),
+			Mode:     0o755,
+			ModTime:  mtime,
+			Typeflag: tar.TypeReg,
+			Format:   tar.FormatGNU,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	specialFiles := specialFiles(info)
+	for _, name := range maps.Keys(specialFiles) {
+		dets := specialFiles[name]
+		content, err := nfpm.MergeScript(dets.fileName, dets.before, dets.after)
+		if err != nil {
+			return nil, err
+		}
+		if content == nil {
+			continue
+		}
+		if err := newItemInsideTar(out, content, &tar.Header{
+			Name:     "debian/" + name,
+			Size:     int64(len(content)),
+			Mode:     dets.mode,
+			ModTime:  mtime,
+			Typeflag: tar.TypeReg,
+			Format:   tar.FormatGNU,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := out.Close(); err != nil {
+		return nil, fmt.Errorf("closing debian.tar.xz: %w", err)
+	}
+	if err := compress.Close(); err != nil {
+		return nil, fmt.Errorf("closing debian.tar.xz: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+type debianFile struct {
+	name    string
+	content []byte
+}
+
+const defaultRules = `#!/usr/bin/make -f
+
+%:
+	dh $@
+`
+
+// sourceInstall lists the top level paths of the orig tarball so dh_install
+// puts them into the binary package.
+func sourceInstall(info *nfpm.Info) []byte {
+	seen := map[string]bool{}
+	var paths []string
+	for _, file := range info.Contents {
+		switch file.Type {
+		case files.TypeRPMGhost, files.TypeDebChangelog:
+			continue
+		}
+		top := strings.SplitN(files.AsRelativePath(file.Destination), "/", 2)[0]
+		if top == "" || seen[top] {
+			continue
+		}
+		seen[top] = true
+		paths = append(paths, top)
+	}
+	sort.Strings(paths)
+	if len(paths) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(paths, "\n") + "\n")
+}
+
+// sourceConffiles lists config files outside of /etc, which debhelper does
+// not flag as conffiles on its own.
+func sourceConffiles(info *nfpm.Info) []byte {
+	var confs []string
+	for _, file := range info.Contents {
+		switch file.Type {
+		case files.TypeConfig, files.TypeConfigNoReplace, files.TypeConfigMissingOK:
+			dst := files.NormalizeAbsoluteFilePath(file.Destination)
+			if !strings.HasPrefix(dst, "/etc/") {
+				confs = append(confs, dst)
+			}
+		}
+	}
+	if len(confs) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(confs, "\n") + "\n")
+}
+
+// sourceChangelog returns the configured changelog or a single entry
+// changelog for the current version, as debian/changelog is mandatory.
+func sourceChangelog(info *nfpm.Info) (string, error) {
+	if info.Changelog != "" {
+		return formatChangelog(info)
+	}
+
+	return fmt.Sprintf(
+		"%s (%s) unstable; urgency=medium\n\n  * Package created with nFPM\n\n -- %s  %s\n",
+		info.Name,
+		dscVersion(info),
+		info.Maintainer,
+		modtime.Get(info.MTime).UTC().Format(time.RFC1123Z),
+	), nil
+}
+
+const sourceControlTemplate = `
+{{- /* Source stanza */ -}}
+Source: {{.Info.Name}}
+Section: {{or .Info.Section "misc"}}
+Priority: {{.Info.Priority}}
+Maintainer: {{.Info.Maintainer}}
+Build-Depends: debhelper-compat (= 13)
+Standards-Version: {{.StandardsVersion}}
+{{- if .Info.Homepage}}
+Homepage: {{.Info.Homepage}}
+{{- end }}
+
+{{- /* Binary stanza */}}
+
+Package: {{.Info.Name}}
+Architecture: {{.Info.Arch}}
+{{- with .Info.Deb.Predepends}}
+Pre-Depends: {{join .}}
+{{- end }}
+Depends: {{join (prepend "${misc:Depends}" .Info.Depends)}}
+{{- with .Info.Recommends}}
+Recommends: {{join .}}
+{{- end }}
+{{- with .Info.Suggests}}
+Suggests: {{join .}}
+{{- end }}
+{{- with .Info.Conflicts}}
+Conflicts: {{join .}}
+{{- end }}
+{{- with .Info.Deb.Breaks}}
+Breaks: {{join .}}
+{{- end }}
+{{- with .Info.Replaces}}
+Replaces: {{join .}}
+{{- end }}
+{{- with nonEmpty .Info.Provides}}
+Provides: {{join .}}
+{{- end }}
+Description: {{multiline .Info.Description}}
+`
+
+func writeSourceControl(w io.Writer, info *nfpm.Info) error {
+	funcs := controlFuncs()
+	funcs["prepend"] = func(s string, strs []string) []string {
+		return append([]string{s}, strs...)
+	}
+	tmpl := template.New("source-control").Funcs(funcs)
+	return template.Must(tmpl.Parse(sourceControlTemplate)).Execute(w, struct {
+		Info             *nfpm.Info
+		StandardsVersion string
+	}{withAdduserDependency(info), dscStandardsVersion})
+}
+
+const dscTemplate = `
+{{- /* Mandatory fields */ -}}
+Format: 3.0 (quilt)
+Source: {{.Info.Name}}
+Binary: {{.Info.Name}}
+Architecture: {{.Info.Arch}}
+Version: {{.Version}}
+Maintainer: {{.Info.Maintainer}}
+{{- if .Info.Homepage}}
+Homepage: {{.Info.Homepage}}
+{{- end }}
+Standards-Version: {{.StandardsVersion}}
+Build-Depends: debhelper-compat (= 13)
+Package-List:
+ {{.Info.Name}} deb {{or .Info.Section "misc"}} {{.Info.Priority}} arch={{.Info.Arch}}
+Checksums-Sha1:
+{{- range .Files }}
+ {{.SHA1}} {{.Size}} {{.Name}}
+{{- end }}
+Checksums-Sha256:
+{{- range .Files }}
+ {{.SHA256}} {{.Size}} {{.Name}}
+{{- end }}
+Files:
+{{- range .Files }}
+ {{.MD5}} {{.Size}} {{.Name}}
+{{- end }}
+`
+
+type dscData struct {
+	Info    *nfpm.Info
+	Version string
+	Files   []dscFile
+}
+
+type dscFile struct {
+	Name   string
+	Size   int
+	MD5    string
+	SHA1   string
+	SHA256 string
+}
+
+func newDSCFile(name string, content []byte) dscFile {
+	sum := func(h hash.Hash) string {
+		_, _ = h.Write(content)
+		return fmt.Sprintf("%x", h.Sum(nil))
+	}
+	return dscFile{
+		Name:   name,
+		Size:   len(content),
+		MD5:    sum(md5.New()), // nolint:gas
+		SHA1:   sum(sha1.New()),
+		SHA256: sum(sha256.New()),
+	}
+}
+
+func writeDSC(w io.Writer, data dscData) error {
+	tmpl := template.New("dsc")
+	return template.Must(tmpl.Parse(dscTemplate)).Execute(w, struct {
+		dscData
+		StandardsVersion string
+	}{data, dscStandardsVersion})
+}
@@ -0,0 +1,194 @@
+package deb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/internal/sign"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+)
+
+func TestDSCConventionalFileName(t *testing.T) {
+	info := exampleInfo()
+	require.Equal(t, "foo_1.0.0-1.dsc", DefaultDSC.ConventionalFileName(info))
+	info.Release = "2"
+	info.Prerelease = "rc1"
+	require.Equal(t, "foo_1.0.0~rc1-2.dsc", DefaultDSC.ConventionalFileName(info))
+	require.Equal(t, ".dsc", DefaultDSC.ConventionalExtension())
+}
+
+func TestDSCNoTarget(t *testing.T) {
+	require.ErrorIs(t, DefaultDSC.Package(exampleInfo(), io.Discard), ErrNoTarget)
+}
+
+func packageDSC(tb testing.TB, info *nfpm.Info) (string, []byte) {
+	tb.Helper()
+
+	dir := tb.TempDir()
+	info.Target = filepath.Join(dir, DefaultDSC.ConventionalFileName(info))
+	var dsc bytes.Buffer
+	require.NoError(tb, DefaultDSC.Package(info, &dsc))
+	return dir, dsc.Bytes()
+}
+
+func TestDSC(t *testing.T) {
+	info := exampleInfo()
+	info.MTime = mtime
+	dir, dsc := packageDSC(t, info)
+
+	origTarball, err := os.ReadFile(filepath.Join(dir, "foo_1.0.0.orig.tar.gz"))
+	require.NoError(t, err)
+	debianTarball, err := os.ReadFile(filepath.Join(dir, "foo_1.0.0-1.debian.tar.xz"))
+	require.NoError(t, err)
+
+	require.Contains(t, string(dsc), "Format: 3.0 (quilt)\nSource: foo\nBinary: foo\nArchitecture: amd64\nVersion: 1.0.0-1\n")
+	require.Contains(t, string(dsc), " foo deb default extra arch=amd64\n")
+	origSum := sha256.Sum256(origTarball)
+	require.Contains(t, string(dsc), fmt.Sprintf(" %x %d foo_1.0.0.orig.tar.gz\n", origSum, len(origTarball)))
+	debianSum := sha256.Sum256(debianTarball)
+	require.Contains(t, string(dsc), fmt.Sprintf(" %x %d foo_1.0.0-1.debian.tar.xz\n", debianSum, len(debianTarball)))
+
+	gz, err := gzip.NewReader(bytes.NewReader(origTarball))
+	require.NoError(t, err)
+	orig, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"foo-1.0.0/etc/",
+		"foo-1.0.0/etc/fake/",
+		"foo-1.0.0/etc/fake/fake.conf",
+		"foo-1.0.0/etc/fake/fake2.conf",
+		"foo-1.0.0/etc/fake/fake3.conf",
+		"foo-1.0.0/usr/",
+		"foo-1.0.0/usr/bin/",
+		"foo-1.0.0/usr/bin/fake",
+		"foo-1.0.0/usr/share/",
+		"foo-1.0.0/usr/share/doc/",
+		"foo-1.0.0/usr/share/doc/fake/",
+		"foo-1.0.0/usr/share/doc/fake/fake.txt",
+		"foo-1.0.0/usr/share/whatever/",
+		"foo-1.0.0/var/",
+		"foo-1.0.0/var/log/",
+		"foo-1.0.0/var/log/whatever/",
+	}, tarContents(t, orig))
+	fake, err := os.ReadFile("../testdata/fake")
+	require.NoError(t, err)
+	require.Equal(t, fake, extractFileFromTar(t, orig, "foo-1.0.0/usr/bin/fake"))
+
+	xzr, err := xz.NewReader(bytes.NewReader(debianTarball))
+	require.NoError(t, err)
+	debian, err := io.ReadAll(xzr)
+	require.NoError(t, err)
+	require.Equal(t, "3.0 (quilt)\n", string(extractFileFromTar(t, debian, "debian/source/format")))
+	require.Equal(t, "etc\nusr\nvar\n", string(extractFileFromTar(t, debian, "debian/install")))
+	require.Equal(t, defaultRules, string(extractFileFromTar(t, debian, "debian/rules")))
+	require.Equal(t, int64(0o755), extractFileHeaderFromTar(t, debian, "debian/rules").Mode)
+	require.False(t, tarContains(t, debian, "debian/conffiles"))
+	require.Equal(t, "foo (1.0.0-1) unstable; urgency=medium\n\n"+
+		"  * Package created with nFPM\n\n"+
+		" -- Carlos A Becker <pkg@carlosbecker.com>  Sun, 05 Nov 2023 23:15:17 +0000\n",
+		string(extractFileFromTar(t, debian, "debian/changelog")))
+
+	control := string(extractFileFromTar(t, debian, "debian/control"))
+	require.True(t, strings.HasPrefix(control, "Source: foo\nSection: default\nPriority: extra\n"), control)
+	require.Contains(t, control, "\nPackage: foo\nArchitecture: amd64\nPre-Depends: less\nDepends: ${misc:Depends}, bash\n")
+
+	copyright := string(extractFileFromTar(t, debian, "debian/copyright"))
+	require.Contains(t, copyright, "Files: *\nCopyright: nope\nLicense: MIT\n")
+}
+
+func TestDSCScriptsAndChangelog(t *testing.T) {
+	info := exampleInfo()
+	info.Changelog = "../testdata/changelog.yaml"
+	info.Deb.Scripts.Rules = "../testdata/scripts/preinstall.sh"
+	info.Scripts.PostInstall = "../testdata/scripts/postinstall.sh"
+	info.Deb.Compression = "xz"
+	dir, _ := packageDSC(t, info)
+
+	debianTarball, err := os.ReadFile(filepath.Join(dir, "foo_1.0.0-1.debian.tar.xz"))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "foo_1.0.0.orig.tar.xz"))
+	require.NoError(t, err)
+
+	xzr, err := xz.NewReader(bytes.NewReader(debianTarball))
+	require.NoError(t, err)
+	debian, err := io.ReadAll(xzr)
+	require.NoError(t, err)
+
+	rules, err := os.ReadFile("../testdata/scripts/preinstall.sh")
+	require.NoError(t, err)
+	require.Equal(t, rules, extractFileFromTar(t, debian, "debian/rules"))
+	postinst, err := os.ReadFile("../testdata/scripts/postinstall.sh")
+	require.NoError(t, err)
+	require.Equal(t, postinst, extractFileFromTar(t, debian, "debian/postinst"))
+
+	changelog, err := formatChangelog(info)
+	require.NoError(t, err)
+	require.Equal(t, changelog, string(extractFileFromTar(t, debian, "debian/changelog")))
+}
+
+func TestDSCAdditionalFiles(t *testing.T) {
+	info := exampleInfo()
+	dir, _ := packageDSC(t, info)
+
+	paths, err := DefaultDSC.AdditionalFiles(info)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(dir, "foo_1.0.0.orig.tar.gz"),
+		filepath.Join(dir, "foo_1.0.0-1.debian.tar.xz"),
+	}, paths)
+	for _, path := range paths {
+		require.FileExists(t, path)
+	}
+}
+
+func TestDSCUnsupportedCompression(t *testing.T) {
+	info := exampleInfo()
+	info.Deb.Compression = "zstd"
+	info.Target = filepath.Join(t.TempDir(), "foo.dsc")
+	require.EqualError(t, DefaultDSC.Package(info, io.Discard),
+		"compression algorithm not supported by debian source packages: zstd")
+}
+
+func TestDSCSignature(t *testing.T) {
+	info := exampleInfo()
+	info.Deb.Signature.KeyFile = "../internal/sign/testdata/privkey.asc"
+	info.Deb.Signature.KeyPassphrase = "hunter2"
+	_, dsc := packageDSC(t, info)
+
+	require.True(t, bytes.HasPrefix(dsc, []byte("-----BEGIN PGP SIGNED MESSAGE-----")))
+	_, err := sign.PGPReadMessage(dsc, "../internal/sign/testdata/pubkey.asc")
+	require.NoError(t, err)
+}
+
+func TestDSCSignFn(t *testing.T) {
+	info := exampleInfo()
+	info.Deb.Signature.SignFn = func(message io.Reader) ([]byte, error) {
+		data, err := io.ReadAll(message)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte("signed\n"), data...), nil
+	}
+	_, dsc := packageDSC(t, info)
+	require.True(t, bytes.HasPrefix(dsc, []byte("signed\nFormat: 3.0 (quilt)\n")))
+}
+
+func TestDSCSignatureKeys(t *testing.T) {
+	info := exampleInfo()
+	info.Target = filepath.Join(t.TempDir(), "foo.dsc")
+	info.Deb.Signature.Keys = []nfpm.DebSignatureKey{{
+		PackageSignature: nfpm.PackageSignature{KeyFile: "../internal/sign/testdata/privkey.asc"},
+	}}
+	err := DefaultDSC.Package(info, io.Discard)
+	require.ErrorAs(t, err, new(*nfpm.ErrSigningFailure))
+	require.ErrorContains(t, err, "additional signing keys are not supported")
+}
@@ -0,0 +1,99 @@
+package deb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/blakesmith/ar"
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/internal/modtime"
+)
+
+type arMember struct {
+	header *ar.Header
+	body   []byte
+}
+
+// Sign adds or replaces the signature of an existing deb package. All members
+// besides the signatures are copied unchanged.
+func (*Deb) Sign(info *nfpm.Info, r io.Reader, w io.Writer) error {
+	if !info.Deb.Signature.IsSet() {
+		return nfpm.ErrNoSigningKey
+	}
+
+	members, err := readArMembers(r)
+	if err != nil {
+		return fmt.Errorf("cannot read deb file: %w", err)
+	}
+
+	var debianBinary, controlTarball, dataTarball *arMember
+	kept := make([]arMember, 0, len(members))
+	for _, m := range members {
+		switch name := m.header.Name; {
+		case strings.HasPrefix(name, "_gpg"):
+			// replaced below
+			continue
+		case name == "debian-binary":
+			debianBinary = &m
+		case strings.HasPrefix(name, "control.tar"):
+			controlTarball = &m
+		case strings.HasPrefix(name, "data.tar"):
+			dataTarball = &m
+		}
+		kept = append(kept, m)
+	}
+	if debianBinary == nil || controlTarball == nil || dataTarball == nil {
+		return errors.New("not a deb package: debian-binary, control and data tarballs are required")
+	}
+
+	signatures, err := doSign(info, debianBinary.body, controlTarball.body, dataTarball.body)
+	if err != nil {
+		return err
+	}
+
+	aw := ar.NewWriter(w)
+	if err := aw.WriteGlobalHeader(); err != nil {
+		return fmt.Errorf("cannot write ar header to deb file: %w", err)
+	}
+	for _, m := range kept {
+		if err := aw.WriteHeader(m.header); err != nil {
+			return fmt.Errorf("cannot write file header: %w", err)
+		}
+		if _, err := aw.Write(m.body); err != nil {
+			return fmt.Errorf("cannot write %s: %w", m.header.Name, err)
+		}
+	}
+
+	for _, sig := range signatures {
+		if err := addArFile(aw, "_gpg"+sig.role, sig.data, modtime.Get(info.MTime)); err != nil {
+			return &nfpm.ErrSigningFailure{
+				Err: fmt.Errorf("add signature to ar file: %w", err),
+			}
+		}
+	}
+	return nil
+}
+
+func readArMembers(r io.Reader) ([]arMember, error) {
+	var members []arMember
+	rd := ar.NewReader(r)
+	for {
+		header, err := rd.Next()
+		if errors.Is(err, io.EOF) {
+			return members, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var body bytes.Buffer
+		if _, err := io.Copy(&body, rd); err != nil {
+			return nil, err
+		}
+		header.Name = strings.TrimSuffix(header.Name, "/")
+		members = append(members, arMember{header: header, body: body.Bytes()})
+	}
+}
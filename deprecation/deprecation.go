@@ -1,10 +1,17 @@
-// Package deprecation provides centralized deprecation notice messaging for nfpm.
+// Package deprecation provides centralized deprecation notice and warning
+// messaging for nfpm.
+//
+// Warnings are emitted while parsing the config, preparing the contents and
+// creating packages. They are passed to a Sink, which prints them to stderr
+// by default and can be replaced with SetSink, for example to collect them.
 package deprecation
 
 import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
+	"sync"
 )
 
 type prefixed struct{ io.Writer }
@@ -13,19 +20,126 @@ func (p prefixed) Write(b []byte) (int, error) {
 	return p.Writer.Write(append([]byte("DEPRECATION WARNING: "), b...))
 }
 
+// Noticer is where the default sink prints deprecation notices.
 var Noticer io.Writer = prefixed{os.Stderr}
 
-// Print prints the given string to the Noticer.
+// Warner is where the default sink prints the other warnings.
+var Warner io.Writer = os.Stderr
+
+// Code identifies the kind of a warning.
+type Code string
+
+const (
+	// Deprecated warns about a field or behavior that will be removed.
+	Deprecated Code = "deprecated"
+	// UnsupportedField warns about a field the packager ignores.
+	UnsupportedField Code = "unsupported-field"
+)
+
+// Warning is a structured diagnostic about the configuration.
+type Warning struct {
+	Code Code `json:"code"`
+	// Path is the config path of the field the warning is about, e.g.
+	// "apk.signature.key_file", if there is one.
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+func (w Warning) String() string {
+	if w.Path == "" {
+		return w.Message
+	}
+	return w.Path + ": " + w.Message
+}
+
+// Sink receives the warnings. Packages may be created concurrently, so
+// sinks must be safe for concurrent use.
+type Sink interface {
+	Warn(w Warning)
+}
+
+// SinkFunc adapts a function to a Sink.
+type SinkFunc func(w Warning)
+
+// Warn calls f(w).
+func (f SinkFunc) Warn(w Warning) {
+	f(w)
+}
+
+type defaultSink struct{}
+
+func (defaultSink) Warn(w Warning) {
+	if w.Code == Deprecated {
+		fmt.Fprintln(Noticer, w)
+		return
+	}
+	fmt.Fprintf(Warner, "WARNING: %s\n", w)
+}
+
+// nolint: gochecknoglobals
+var (
+	sink     Sink = defaultSink{}
+	sinkLock sync.RWMutex
+)
+
+// SetSink replaces the sink warnings are passed to and returns the previous
+// one. A nil sink restores the default one, which prints to stderr.
+func SetSink(s Sink) Sink {
+	if s == nil {
+		s = defaultSink{}
+	}
+	sinkLock.Lock()
+	defer sinkLock.Unlock()
+	previous := sink
+	sink = s
+	return previous
+}
+
+// Warn passes the warning to the sink.
+func Warn(w Warning) {
+	sinkLock.RLock()
+	s := sink
+	sinkLock.RUnlock()
+	s.Warn(w)
+}
+
+// Collector is a Sink that keeps the warnings, and passes them on to the
+// next sink if it is set.
+type Collector struct {
+	Next Sink
+
+	lock     sync.Mutex
+	warnings []Warning
+}
+
+// Warn keeps the warning and passes it on to the next sink.
+func (c *Collector) Warn(w Warning) {
+	c.lock.Lock()
+	c.warnings = append(c.warnings, w)
+	c.lock.Unlock()
+	if c.Next != nil {
+		c.Next.Warn(w)
+	}
+}
+
+// Warnings returns the warnings collected so far.
+func (c *Collector) Warnings() []Warning {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]Warning(nil), c.warnings...)
+}
+
+// Print prints the given string as a deprecation notice.
 func Print(s string) {
-	fmt.Fprint(Noticer, s)
+	Warn(Warning{Code: Deprecated, Message: strings.TrimSuffix(s, "\n")})
 }
 
-// Println printslns the given string to the Noticer.
+// Println printslns the given string as a deprecation notice.
 func Println(s string) {
-	fmt.Fprintln(Noticer, s)
+	Print(s)
 }
 
-// Printf printfs the given string to the Noticer.
+// Printf printfs the given string as a deprecation notice.
 func Printf(format string, a ...interface{}) {
-	fmt.Fprintf(Noticer, format, a...)
+	Print(fmt.Sprintf(format, a...))
 }
@@ -2,6 +2,7 @@ package deprecation
 
 import (
 	"bytes"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -15,3 +16,31 @@ func TestNotice(t *testing.T) {
 	Println("foobar")
 	require.Equal(t, "DEPRECATION WARNING: blah\nDEPRECATION WARNING: blah: true\nDEPRECATION WARNING: foobar\n", b.String())
 }
+
+func TestSink(t *testing.T) {
+	var b bytes.Buffer
+	Warner = &b
+	t.Cleanup(func() { Warner = os.Stderr })
+
+	var forwarded []Warning
+	collector := &Collector{Next: SinkFunc(func(w Warning) {
+		forwarded = append(forwarded, w)
+	})}
+	previous := SetSink(collector)
+	t.Cleanup(func() { SetSink(previous) })
+
+	Println("foo")
+	Warn(Warning{Code: UnsupportedField, Path: "conflicts", Message: "ignored"})
+	expected := []Warning{
+		{Code: Deprecated, Message: "foo"},
+		{Code: UnsupportedField, Path: "conflicts", Message: "ignored"},
+	}
+	require.Equal(t, expected, collector.Warnings())
+	require.Equal(t, expected, forwarded)
+	require.Empty(t, b.String())
+
+	require.Same(t, collector, SetSink(nil))
+	Warn(Warning{Code: UnsupportedField, Path: "conflicts", Message: "ignored"})
+	require.Equal(t, "WARNING: conflicts: ignored\n", b.String())
+	require.Len(t, collector.Warnings(), 2)
+}
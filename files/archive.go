@@ -0,0 +1,509 @@
+package files
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2/internal/glob"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// ErrUnsupportedArchive happens when the source of an archive content is
+// neither a supported archive file nor an OCI image layout.
+var ErrUnsupportedArchive = errors.New("unsupported archive")
+
+// archiveEntry is a member of an archive with its recorded file information.
+type archiveEntry struct {
+	name     string
+	typ      string
+	linkname string
+	mode     fs.FileMode
+	owner    string
+	group    string
+	uid      uint32
+	gid      uint32
+	mtime    time.Time
+	data     []byte
+	devMajor uint32
+	devMinor uint32
+}
+
+// archiveEntries are the members of an archive by their cleaned name. Later
+// members replace earlier ones with the same name, just like when unpacking.
+type archiveEntries map[string]*archiveEntry
+
+// addArchive adds the members of the archive below its destination, similar
+// to addTree but with the file information recorded in the archive.
+func addArchive(
+	all map[string]*Content,
+	archive *Content,
+	mtime time.Time,
+	owned ownedPaths,
+) error {
+	if archive.Destination != "/" && archive.Destination != "" {
+		presentContent, destinationOccupied := all[NormalizeAbsoluteDirPath(archive.Destination)]
+		if destinationOccupied && presentContent.Type != TypeImplicitDir {
+			return contentCollisionError(archive, presentContent)
+		}
+	}
+
+	if err := addParents(all, archive.Destination, mtime); err != nil {
+		return err
+	}
+
+	entries, err := readArchive(archive.Source)
+	if err != nil {
+		return err
+	}
+
+	for name := range entries {
+		excluded, err := glob.Excluded(name, archive.Exclude)
+		if err != nil {
+			return err
+		}
+		if excluded {
+			delete(entries, name)
+		}
+	}
+
+	for _, entry := range entries {
+		destination := path.Join("/", archive.Destination, entry.name)
+
+		c := &Content{
+			Type:     entry.typ,
+			Packager: archive.Packager,
+			FileInfo: &ContentFileInfo{
+				Owner:    entry.owner,
+				Group:    entry.group,
+				UID:      entry.uid,
+				GID:      entry.gid,
+				Mode:     entry.mode,
+				MTime:    entry.mtime,
+				DevMajor: entry.devMajor,
+				DevMinor: entry.devMinor,
+			},
+		}
+		if archive.FileInfo != nil && !owned.has(archive.Destination) {
+			// names and ids are replaced together to keep them consistent
+			if archive.FileInfo.Owner != "" || archive.FileInfo.UID != 0 {
+				c.FileInfo.Owner = archive.FileInfo.Owner
+				c.FileInfo.UID = archive.FileInfo.UID
+			}
+			if archive.FileInfo.Group != "" || archive.FileInfo.GID != 0 {
+				c.FileInfo.Group = archive.FileInfo.Group
+				c.FileInfo.GID = archive.FileInfo.GID
+			}
+		}
+		if archive.FileInfo != nil {
+			c.FileInfo.Strip = archive.FileInfo.Strip
+		}
+
+		switch entry.typ {
+		case TypeDir:
+			c.Destination = NormalizeAbsoluteDirPath(destination)
+			if owned.has(c.Destination) {
+				c.Type = TypeImplicitDir
+			}
+		case TypeSymlink:
+			c.Source = entry.linkname
+			c.Destination = NormalizeAbsoluteFilePath(destination)
+		case TypeHardlink:
+			c.Destination = NormalizeAbsoluteFilePath(destination)
+			c.Source = NormalizeAbsoluteFilePath(path.Join("/", archive.Destination, entry.linkname))
+			// the target may have been excluded, then a copy is added
+			if target, ok := entries[entry.linkname]; !ok || target.typ != TypeFile {
+				c.Type = TypeFile
+				c.Source = ""
+				c.Data = entry.data
+			}
+		default:
+			c.Destination = NormalizeAbsoluteFilePath(destination)
+			c.Data = entry.data
+		}
+
+		if archive.FileInfo != nil && archive.FileInfo.Mode != 0 && c.Type != TypeSymlink {
+			c.FileInfo.Mode = archive.FileInfo.Mode
+		}
+
+		if err := addParents(all, c.Destination, mtime); err != nil {
+			return err
+		}
+
+		if present, ok := all[c.Destination]; ok && present.Type != TypeImplicitDir {
+			// directories are merged, the configured one is kept
+			if isDir(present) && isDir(c) {
+				continue
+			}
+			return contentCollisionError(c, present)
+		}
+
+		all[c.Destination] = c.WithFileInfoDefaults(0, mtime)
+	}
+
+	return nil
+}
+
+func isDir(c *Content) bool {
+	return c.Type == TypeDir || c.Type == TypeImplicitDir
+}
+
+// readArchive reads the members of a tar or zip archive or the merged layers
+// of an OCI image layout directory.
+func readArchive(src string) (archiveEntries, error) {
+	entries := archiveEntries{}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return entries, entries.addOCILayout(src)
+	}
+
+	name := strings.ToLower(filepath.Base(src))
+	if strings.HasSuffix(name, ".zip") {
+		return entries, entries.addZip(src)
+	}
+
+	f, err := os.Open(src) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // nolint: errcheck
+
+	var r io.Reader
+	switch {
+	case strings.HasSuffix(name, ".tar"):
+		r = f
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", src, err)
+		}
+		defer gz.Close() // nolint: errcheck
+		r = gz
+	case strings.HasSuffix(name, ".tar.zst"), strings.HasSuffix(name, ".tzst"):
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", src, err)
+		}
+		defer zr.Close()
+		r = zr
+	case strings.HasSuffix(name, ".tar.xz"), strings.HasSuffix(name, ".txz"):
+		xr, err := xz.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", src, err)
+		}
+		r = xr
+	case strings.HasSuffix(name, ".tar.bz2"), strings.HasSuffix(name, ".tbz2"):
+		r = bzip2.NewReader(f)
+	default:
+		return nil, fmt.Errorf("%s: %w: expected a .tar, .tar.gz, .tar.zst, .tar.xz, .tar.bz2 or .zip file or an OCI image layout directory", src, ErrUnsupportedArchive)
+	}
+
+	if err := entries.addTar(r, false); err != nil {
+		return nil, fmt.Errorf("%s: %w", src, err)
+	}
+	return entries, nil
+}
+
+// cleanArchiveName returns the slash separated name of a member relative to
+// the archive root, the root itself is empty.
+func cleanArchiveName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// addTar adds the members of a tar archive. If whiteouts is set, OCI
+// whiteout files delete previously added members.
+func (e archiveEntries) addTar(r io.Reader, whiteouts bool) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := cleanArchiveName(hdr.Name)
+		if name == "" {
+			// the root directory is not taken from the archive
+			continue
+		}
+
+		if whiteouts {
+			dir, base := path.Split(name)
+			if base == ".wh..wh..opq" {
+				e.removeChildren(path.Clean(dir))
+				continue
+			}
+			if strings.HasPrefix(base, ".wh.") {
+				e.remove(path.Join(dir, strings.TrimPrefix(base, ".wh.")))
+				continue
+			}
+		}
+
+		entry := &archiveEntry{
+			name:     name,
+			mode:     fs.FileMode(hdr.Mode & 0o7777),
+			owner:    hdr.Uname,
+			group:    hdr.Gname,
+			uid:      uint32(hdr.Uid),
+			gid:      uint32(hdr.Gid),
+			mtime:    hdr.ModTime,
+			devMajor: uint32(hdr.Devmajor),
+			devMinor: uint32(hdr.Devminor),
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			entry.typ = TypeDir
+		case tar.TypeSymlink:
+			entry.typ = TypeSymlink
+			entry.linkname = hdr.Linkname
+		case tar.TypeLink:
+			target, ok := e[cleanArchiveName(hdr.Linkname)]
+			if ok && target.typ == TypeHardlink {
+				target, ok = e[target.linkname]
+			}
+			if !ok || target.typ != TypeFile {
+				return fmt.Errorf("hard link %s: target %s not found", hdr.Name, hdr.Linkname)
+			}
+			entry.typ = TypeHardlink
+			entry.linkname = target.name
+			entry.data = target.data
+		case tar.TypeReg:
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return fmt.Errorf("read %s: %w", hdr.Name, err)
+			}
+			entry.typ = TypeFile
+			entry.data = data
+		case tar.TypeChar:
+			entry.typ = TypeCharDevice
+		case tar.TypeBlock:
+			entry.typ = TypeBlockDevice
+		case tar.TypeFifo:
+			entry.typ = TypeFIFO
+		case tar.TypeXGlobalHeader:
+			continue
+		default:
+			return fmt.Errorf("%s: unsupported tar entry type %q", hdr.Name, hdr.Typeflag)
+		}
+
+		e.put(entry)
+	}
+}
+
+// put adds the entry, replacing a directory with something else also removes
+// its children while directories are merged.
+func (e archiveEntries) put(entry *archiveEntry) {
+	if present, ok := e[entry.name]; ok && present.typ == TypeDir && entry.typ != TypeDir {
+		e.removeChildren(entry.name)
+	}
+	e[entry.name] = entry
+}
+
+// remove removes the member with the given name and, if it is a directory,
+// its children.
+func (e archiveEntries) remove(name string) {
+	delete(e, name)
+	e.removeChildren(name)
+}
+
+func (e archiveEntries) removeChildren(dir string) {
+	for name := range e {
+		if dir == "." || strings.HasPrefix(name, dir+"/") {
+			delete(e, name)
+		}
+	}
+}
+
+// addZip adds the members of a zip archive, zip files do not record owners.
+func (e archiveEntries) addZip(src string) error {
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		return fmt.Errorf("%s: %w", src, err)
+	}
+	defer zr.Close() // nolint: errcheck
+
+	for _, f := range zr.File {
+		name := cleanArchiveName(f.Name)
+		if name == "" {
+			continue
+		}
+
+		entry := &archiveEntry{
+			name:  name,
+			mode:  unixMode(f.Mode()),
+			mtime: f.Modified,
+		}
+
+		switch {
+		case f.Mode().IsDir():
+			entry.typ = TypeDir
+		default:
+			data, err := readZipFile(f)
+			if err != nil {
+				return fmt.Errorf("%s: read %s: %w", src, f.Name, err)
+			}
+			entry.typ = TypeFile
+			entry.data = data
+			if f.Mode()&fs.ModeSymlink != 0 {
+				entry.typ = TypeSymlink
+				entry.linkname = string(data)
+				entry.data = nil
+			}
+		}
+
+		e.put(entry)
+	}
+
+	return nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close() // nolint: errcheck
+	return io.ReadAll(rc)
+}
+
+// unixMode converts the mode bits of a fs.FileMode to the unix permission
+// bits including setuid, setgid and sticky as used in the file info.
+func unixMode(mode fs.FileMode) fs.FileMode {
+	m := mode.Perm()
+	if mode&fs.ModeSetuid != 0 {
+		m |= 0o4000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		m |= 0o2000
+	}
+	if mode&fs.ModeSticky != 0 {
+		m |= 0o1000
+	}
+	return m
+}
+
+const (
+	ociImageIndex      = "application/vnd.oci.image.index.v1+json"
+	dockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+}
+
+// ociManifest covers both image indexes and image manifests.
+type ociManifest struct {
+	Manifests []ociDescriptor `json:"manifests"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+// addOCILayout adds the files of the image in the OCI image layout directory
+// by applying its layers in order.
+// See https://github.com/opencontainers/image-spec/blob/main/image-layout.md
+func (e archiveEntries) addOCILayout(dir string) error {
+	if _, err := os.Stat(filepath.Join(dir, "oci-layout")); err != nil {
+		return fmt.Errorf("%s: %w: directory is not an OCI image layout", dir, ErrUnsupportedArchive)
+	}
+
+	var manifest ociManifest
+	if err := readJSON(filepath.Join(dir, "index.json"), &manifest); err != nil {
+		return fmt.Errorf("%s: read index: %w", dir, err)
+	}
+
+	// follow the (possibly nested) indexes to the single image manifest
+	for {
+		if len(manifest.Manifests) != 1 {
+			return fmt.Errorf("%s: the OCI image layout must contain exactly one image, found %d manifests", dir, len(manifest.Manifests))
+		}
+
+		descriptor := manifest.Manifests[0]
+		blob, err := ociBlobPath(dir, descriptor.Digest)
+		if err != nil {
+			return err
+		}
+		manifest = ociManifest{}
+		if err := readJSON(blob, &manifest); err != nil {
+			return fmt.Errorf("%s: read manifest %s: %w", dir, descriptor.Digest, err)
+		}
+		if descriptor.MediaType != ociImageIndex && descriptor.MediaType != dockerManifestList {
+			break
+		}
+	}
+
+	for _, layer := range manifest.Layers {
+		if err := e.addOCILayer(dir, layer); err != nil {
+			return fmt.Errorf("%s: layer %s: %w", dir, layer.Digest, err)
+		}
+	}
+
+	return nil
+}
+
+func (e archiveEntries) addOCILayer(dir string, layer ociDescriptor) error {
+	blob, err := ociBlobPath(dir, layer.Digest)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(blob) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint: errcheck
+
+	var r io.Reader = f
+	switch {
+	case strings.HasSuffix(layer.MediaType, "gzip"):
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close() // nolint: errcheck
+		r = gz
+	case strings.HasSuffix(layer.MediaType, "zstd"):
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	return e.addTar(r, true)
+}
+
+func ociBlobPath(dir, digest string) (string, error) {
+	algorithm, hash, ok := strings.Cut(digest, ":")
+	if !ok || algorithm == "" || hash == "" || strings.ContainsAny(digest, `/\`) {
+		return "", fmt.Errorf("%s: invalid digest %q", dir, digest)
+	}
+	return filepath.Join(dir, "blobs", algorithm, hash), nil
+}
+
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
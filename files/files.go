@@ -1,7 +1,10 @@
 package files
 
 import (
+	"archive/tar"
+	"bytes"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -25,9 +28,26 @@ const (
 	TypeImplicitDir = "implicit dir"
 	// TypeTree is the type of a whole directory tree structure.
 	TypeTree = "tree"
+	// TypeArchive is the type of a tar or zip archive or an OCI image layout
+	// whose members are added below the destination with the modes, owners
+	// and modification times recorded in the archive.
+	TypeArchive = "archive"
 	// TypeSymlink is the type of a symlink that is created at the destination
 	// path and points to the source path.
 	TypeSymlink = "symlink"
+	// TypeHardlink is the type of a hard link that is created at the
+	// destination path and shares the regular file at the source path inside
+	// the package. Sources that are hard links to each other are added with
+	// this type automatically.
+	TypeHardlink = "hardlink"
+	// TypeCharDevice is the type of a character device node with the major
+	// and minor number from the file info.
+	TypeCharDevice = "chardev"
+	// TypeBlockDevice is the type of a block device node with the major and
+	// minor number from the file info.
+	TypeBlockDevice = "blockdev"
+	// TypeFIFO is the type of a named pipe.
+	TypeFIFO = "fifo"
 	// TypeConfig is the type of a configuration file that may be changed by the
 	// user of the package.
 	TypeConfig = "config"
@@ -53,25 +73,61 @@ const (
 	// ignored by other packagers. This type should never be set for a content
 	// entry as it is automatically added when a changelog is configred.
 	TypeDebChangelog = "debian changelog"
+	// TypeManPage is the type of a man page in roff or markdown format that is
+	// compressed and installed into the directory of its section, which is
+	// taken from the file extension.
+	TypeManPage = "man_page"
+	// TypeCopyright is the type of the machine-readable copyright file that
+	// is generated from the copyright configuration. This type should never
+	// be set for a content entry as it is automatically added when copyright
+	// files are configured.
+	TypeCopyright = "copyright"
 )
 
+// SpecialFileTypeflag returns the tar entry type of content of the
+// TypeCharDevice, TypeBlockDevice or TypeFIFO type.
+func SpecialFileTypeflag(c *Content) byte {
+	switch c.Type {
+	case TypeCharDevice:
+		return tar.TypeChar
+	case TypeBlockDevice:
+		return tar.TypeBlock
+	default:
+		return tar.TypeFifo
+	}
+}
+
 // Content describes the source and destination
 // of one file to copy into a package.
 type Content struct {
 	Source      string           `yaml:"src,omitempty" json:"src,omitempty"`
 	Destination string           `yaml:"dst" json:"dst"`
-	Type        string           `yaml:"type,omitempty" json:"type,omitempty" jsonschema:"enum=symlink,enum=ghost,enum=config,enum=config|noreplace,enum=dir,enum=tree,enum=,default="`
+	Type        string           `yaml:"type,omitempty" json:"type,omitempty" jsonschema:"enum=symlink,enum=hardlink,enum=chardev,enum=blockdev,enum=fifo,enum=ghost,enum=config,enum=config|noreplace,enum=dir,enum=tree,enum=archive,enum=man_page,enum=,default="`
 	Packager    string           `yaml:"packager,omitempty" json:"packager,omitempty"`
 	FileInfo    *ContentFileInfo `yaml:"file_info,omitempty" json:"file_info,omitempty"`
 	Expand      bool             `yaml:"expand,omitempty" json:"expand,omitempty"`
+	Inline      string           `yaml:"content,omitempty" json:"content,omitempty" jsonschema:"title=inline file content used instead of src"`
+	Exclude     []string         `yaml:"exclude,omitempty" json:"exclude,omitempty" jsonschema:"title=patterns of files to leave out when globbing or walking trees and archives"`
+	// Data holds the content of generated files. If set, it is used instead
+	// of reading the source.
+	Data []byte `yaml:"-" json:"-"`
 }
 
 type ContentFileInfo struct {
-	Owner string      `yaml:"owner,omitempty" json:"owner,omitempty"`
-	Group string      `yaml:"group,omitempty" json:"group,omitempty"`
+	Owner string `yaml:"owner,omitempty" json:"owner,omitempty"`
+	Group string `yaml:"group,omitempty" json:"group,omitempty"`
+	// UID and GID are the numeric owner and group, used by formats that
+	// record them next to, or instead of, the names.
+	UID   uint32      `yaml:"uid,omitempty" json:"uid,omitempty"`
+	GID   uint32      `yaml:"gid,omitempty" json:"gid,omitempty"`
 	Mode  os.FileMode `yaml:"mode,omitempty" json:"mode,omitempty"`
 	MTime time.Time   `yaml:"mtime,omitempty" json:"mtime,omitempty"`
 	Size  int64       `yaml:"-" json:"-"`
+	// DevMajor and DevMinor are the device numbers of device nodes.
+	DevMajor uint32 `yaml:"devmajor,omitempty" json:"devmajor,omitempty"`
+	DevMinor uint32 `yaml:"devminor,omitempty" json:"devminor,omitempty"`
+	// Strip removes the symbol tables and debug sections of ELF files.
+	Strip bool `yaml:"strip,omitempty" json:"strip,omitempty" jsonschema:"title=strip symbols and debug information from ELF files"`
 }
 
 // Contents list of Content to process.
@@ -116,6 +172,7 @@ func (c *Content) WithFileInfoDefaults(umask fs.FileMode, mtime time.Time) *Cont
 		Type:        c.Type,
 		Packager:    c.Packager,
 		FileInfo:    c.FileInfo,
+		Data:        c.Data,
 	}
 	if cc.Type == "" {
 		cc.Type = TypeFile
@@ -123,10 +180,10 @@ func (c *Content) WithFileInfoDefaults(umask fs.FileMode, mtime time.Time) *Cont
 	if cc.FileInfo == nil {
 		cc.FileInfo = &ContentFileInfo{}
 	}
-	if cc.FileInfo.Owner == "" {
+	if cc.FileInfo.Owner == "" && cc.FileInfo.UID == 0 {
 		cc.FileInfo.Owner = "root"
 	}
-	if cc.FileInfo.Group == "" {
+	if cc.FileInfo.Group == "" && cc.FileInfo.GID == 0 {
 		cc.FileInfo.Group = "root"
 	}
 	if (cc.Type == TypeDir || cc.Type == TypeImplicitDir) && cc.FileInfo.Mode == 0 {
@@ -141,8 +198,15 @@ func (c *Content) WithFileInfoDefaults(umask fs.FileMode, mtime time.Time) *Cont
 		cc.FileInfo.Mode != 0 &&
 		(cc.FileInfo.Size != 0 || (cc.Type == TypeDir || cc.Type == TypeImplicitDir)))
 
-	// only stat source when we actually need more information
-	if cc.Source != "" && !fileInfoAlreadyComplete {
+	switch {
+	case cc.Data != nil:
+		// generated content has no source to take the information from
+		if cc.FileInfo.Mode == 0 {
+			cc.FileInfo.Mode = 0o644 &^ umask
+		}
+		cc.FileInfo.Size = int64(len(cc.Data))
+	case cc.Source != "" && !fileInfoAlreadyComplete:
+		// only stat source when we actually need more information
 		info, err := os.Stat(cc.Source)
 		if err == nil {
 			if cc.FileInfo.MTime.IsZero() {
@@ -161,6 +225,24 @@ func (c *Content) WithFileInfoDefaults(umask fs.FileMode, mtime time.Time) *Cont
 	return cc
 }
 
+// Open opens the data of the content, which is either the generated data or
+// the source file.
+func (c *Content) Open() (io.ReadCloser, error) {
+	if c.Data != nil {
+		return io.NopCloser(bytes.NewReader(c.Data)), nil
+	}
+	return os.Open(c.Source)
+}
+
+// ReadData returns the data of the content, which is either the generated
+// data or the contents of the source file.
+func (c *Content) ReadData() ([]byte, error) {
+	if c.Data != nil {
+		return c.Data, nil
+	}
+	return os.ReadFile(c.Source)
+}
+
 // Name to part of the os.FileInfo interface
 func (c *Content) Name() string {
 	return c.Source
@@ -237,7 +319,8 @@ func (c *Content) String() string {
 //     slash if the entry is a directory
 //
 // If no packager is specified, only the files that are relevant for any
-// packager are considered.
+// packager are considered. Directories are owned by the filesystem according
+// to the default profile of the packager.
 func PrepareForPackager(
 	rawContents Contents,
 	umask fs.FileMode,
@@ -245,7 +328,27 @@ func PrepareForPackager(
 	disableGlobbing bool,
 	mtime time.Time,
 ) (Contents, error) {
+	return PrepareForPackagerWithFilesystem(rawContents, umask, packager, disableGlobbing, mtime, Filesystem{})
+}
+
+// PrepareForPackagerWithFilesystem is like PrepareForPackager, but uses the
+// given filesystem to decide which directories trees and archives do not
+// claim ownership of.
+func PrepareForPackagerWithFilesystem(
+	rawContents Contents,
+	umask fs.FileMode,
+	packager string,
+	disableGlobbing bool,
+	mtime time.Time,
+	filesystem Filesystem,
+) (Contents, error) {
+	owned, err := filesystem.owned(packager)
+	if err != nil {
+		return nil, err
+	}
+
 	contentMap := make(map[string]*Content)
+	inodes := sourceInodes{}
 
 	for _, content := range rawContents {
 		if !isRelevantForPackager(packager, content) {
@@ -273,7 +376,7 @@ func PrepareForPackager(
 			// if there's an implicit directory, the contents probably already
 			// have been expanded so we can just ignore it, it will be created
 			// by another content element again anyway
-		case TypeRPMGhost, TypeSymlink, TypeRPMDoc, TypeRPMLicence, TypeRPMLicense, TypeRPMReadme, TypeDebChangelog:
+		case TypeRPMGhost, TypeSymlink, TypeHardlink, TypeRPMDoc, TypeRPMLicence, TypeRPMLicense, TypeRPMReadme, TypeDebChangelog, TypeCopyright:
 			presentContent, destinationOccupied := contentMap[NormalizeAbsoluteFilePath(content.Destination)]
 			if destinationOccupied {
 				return nil, contentCollisionError(content, presentContent)
@@ -286,31 +389,96 @@ func PrepareForPackager(
 
 			cc := content.WithFileInfoDefaults(umask, mtime)
 			cc.Source = ToNixPath(cc.Source)
+			if cc.Type == TypeHardlink {
+				cc.Source = NormalizeAbsoluteFilePath(cc.Source)
+			}
+			cc.Destination = NormalizeAbsoluteFilePath(cc.Destination)
+			contentMap[cc.Destination] = cc
+		case TypeCharDevice, TypeBlockDevice, TypeFIFO:
+			if content.Source != "" {
+				return nil, fmt.Errorf("content for %s: src cannot be set for %s", content.Destination, content.Type)
+			}
+
+			presentContent, destinationOccupied := contentMap[NormalizeAbsoluteFilePath(content.Destination)]
+			if destinationOccupied {
+				return nil, contentCollisionError(content, presentContent)
+			}
+
+			if err := addParents(contentMap, content.Destination, mtime); err != nil {
+				return nil, err
+			}
+
+			cc := content.WithFileInfoDefaults(umask, mtime)
+			if cc.FileInfo.Mode == 0 {
+				cc.FileInfo.Mode = 0o644 &^ umask
+			}
 			cc.Destination = NormalizeAbsoluteFilePath(cc.Destination)
 			contentMap[cc.Destination] = cc
 		case TypeTree:
-			err := addTree(contentMap, content, umask, mtime)
+			err := addTree(contentMap, content, umask, mtime, inodes, owned)
 			if err != nil {
 				return nil, fmt.Errorf("add tree: %w", err)
 			}
+		case TypeArchive:
+			if err := addArchive(contentMap, content, mtime, owned); err != nil {
+				return nil, fmt.Errorf("add archive: %w", err)
+			}
 		case TypeConfig, TypeConfigNoReplace, TypeConfigMissingOK, TypeFile, "":
+			if content.Inline != "" {
+				if content.Source != "" {
+					return nil, fmt.Errorf("content for %s: src and content cannot be set both", content.Destination)
+				}
+				inline := *content
+				inline.Data = []byte(content.Inline)
+				content = &inline
+			}
+
+			// inline and generated content, e.g. from already prepared
+			// contents, is not globbed as there is nothing to read from the source
+			globbed := map[string]string{content.Source: content.Destination}
+			if content.Data == nil {
+				var err error
+				globbed, err = glob.Glob(
+					filepath.ToSlash(content.Source),
+					filepath.ToSlash(content.Destination),
+					disableGlobbing,
+					content.Exclude...,
+				)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			if err := addGlobbedFiles(contentMap, globbed, content, umask, mtime, inodes); err != nil {
+				return nil, fmt.Errorf("add globbed files from %q: %w", content.Source, err)
+			}
+		case TypeManPage:
 			globbed, err := glob.Glob(
 				filepath.ToSlash(content.Source),
-				filepath.ToSlash(content.Destination),
+				"/",
 				disableGlobbing,
+				content.Exclude...,
 			)
 			if err != nil {
 				return nil, err
 			}
 
-			if err := addGlobbedFiles(contentMap, globbed, content, umask, mtime); err != nil {
-				return nil, fmt.Errorf("add globbed files from %q: %w", content.Source, err)
+			if err := addManPages(contentMap, globbed, content, umask, mtime); err != nil {
+				return nil, fmt.Errorf("add man pages from %q: %w", content.Source, err)
 			}
 		default:
 			return nil, fmt.Errorf("invalid content type: %s", content.Type)
 		}
 	}
 
+	if err := stripContents(contentMap); err != nil {
+		return nil, err
+	}
+
+	if err := resolveHardlinks(contentMap); err != nil {
+		return nil, err
+	}
+
 	res := make(Contents, 0, len(contentMap))
 
 	for _, content := range contentMap {
@@ -331,7 +499,7 @@ func isRelevantForPackager(packager string, content *Content) bool {
 		return false
 	}
 
-	if packager != "rpm" &&
+	if packager != "rpm" && packager != "srpm" &&
 		(content.Type == TypeRPMDoc || content.Type == TypeRPMLicence ||
 			content.Type == TypeRPMLicense || content.Type == TypeRPMReadme ||
 			content.Type == TypeRPMGhost) {
@@ -405,9 +573,17 @@ func addGlobbedFiles(
 	origFile *Content,
 	umask fs.FileMode,
 	mtime time.Time,
+	inodes sourceInodes,
 ) error {
-	for src, dst := range globbed {
-		dst = NormalizeAbsoluteFilePath(dst)
+	// sorted, so that hard links always point to the same file
+	sources := make([]string, 0, len(globbed))
+	for src := range globbed {
+		sources = append(sources, src)
+	}
+	sort.Strings(sources)
+
+	for _, src := range sources {
+		dst := NormalizeAbsoluteFilePath(globbed[src])
 		presentContent, destinationOccupied := all[dst]
 		if destinationOccupied {
 			c := *origFile
@@ -427,16 +603,30 @@ func addGlobbedFiles(
 			newFileInfo = &newFileInfoVal
 		}
 
+		// inline content has no source
+		source := src
+		if source != "" {
+			source = ToNixPath(src)
+		}
+
 		newFile := (&Content{
 			Destination: NormalizeAbsoluteFilePath(dst),
-			Source:      ToNixPath(src),
+			Source:      source,
 			Type:        origFile.Type,
 			FileInfo:    newFileInfo,
 			Packager:    origFile.Packager,
+			Data:        origFile.Data,
 		}).WithFileInfoDefaults(umask, mtime)
-		if dst, err := os.Readlink(src); err == nil {
+		if dst, err := os.Readlink(src); err == nil && origFile.Data == nil {
 			newFile.Source = dst
 			newFile.Type = TypeSymlink
+		} else if newFile.Type == TypeFile && origFile.Data == nil {
+			if info, err := os.Lstat(src); err == nil {
+				if target, ok := inodes.hardlinkTarget(src, info, newFile.Destination); ok {
+					newFile.Source = target
+					newFile.Type = TypeHardlink
+				}
+			}
 		}
 
 		all[dst] = newFile
@@ -450,6 +640,8 @@ func addTree(
 	tree *Content,
 	umask os.FileMode,
 	mtime time.Time,
+	inodes sourceInodes,
+	owned ownedPaths,
 ) error {
 	if tree.Destination != "/" && tree.Destination != "" {
 		presentContent, destinationOccupied := all[NormalizeAbsoluteDirPath(tree.Destination)]
@@ -473,14 +665,32 @@ func addTree(
 			return err
 		}
 
+		if relPath != "." {
+			excluded, err := glob.Excluded(filepath.ToSlash(relPath), tree.Exclude)
+			if err != nil {
+				return err
+			}
+			if excluded && d.IsDir() {
+				return filepath.SkipDir
+			}
+			if excluded {
+				return nil
+			}
+		}
+
 		destination := filepath.Join(tree.Destination, relPath)
 
 		c := &Content{
 			FileInfo: &ContentFileInfo{},
 		}
-		if tree.FileInfo != nil && !ownedByFilesystem(tree.Destination) {
+		if tree.FileInfo != nil && !owned.has(tree.Destination) {
 			c.FileInfo.Owner = tree.FileInfo.Owner
 			c.FileInfo.Group = tree.FileInfo.Group
+			c.FileInfo.UID = tree.FileInfo.UID
+			c.FileInfo.GID = tree.FileInfo.GID
+		}
+		if tree.FileInfo != nil {
+			c.FileInfo.Strip = tree.FileInfo.Strip
 		}
 
 		switch {
@@ -494,7 +704,7 @@ func addTree(
 			c.Destination = NormalizeAbsoluteDirPath(destination)
 			c.FileInfo.Mode = info.Mode() &^ umask
 			c.FileInfo.MTime = info.ModTime()
-			if ownedByFilesystem(c.Destination) {
+			if owned.has(c.Destination) {
 				c.Type = TypeImplicitDir
 			}
 		case d.Type()&os.ModeSymlink != 0:
@@ -511,6 +721,15 @@ func addTree(
 			c.Source = path
 			c.Destination = NormalizeAbsoluteFilePath(destination)
 			c.FileInfo.Mode = d.Type() &^ umask
+
+			info, err := d.Info()
+			if err != nil {
+				return fmt.Errorf("get file information: %w", err)
+			}
+			if target, ok := inodes.hardlinkTarget(path, info, c.Destination); ok {
+				c.Type = TypeHardlink
+				c.Source = target
+			}
 		}
 
 		if tree.FileInfo != nil && tree.FileInfo.Mode != 0 && c.Type != TypeSymlink {
@@ -1,9 +1,18 @@
 package files_test
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"debug/elf"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strconv"
@@ -903,6 +912,279 @@ func TestTree(t *testing.T) {
 	}, withoutFileInfo(results))
 }
 
+func TestTreeFilesystem(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		packager   string
+		filesystem files.Filesystem
+		typ        string
+	}{
+		{"arch", "archlinux", files.Filesystem{}, files.TypeImplicitDir},
+		{"fedora", "rpm", files.Filesystem{}, files.TypeDir},
+		{"debian", "deb", files.Filesystem{}, files.TypeDir},
+		{"profile", "rpm", files.Filesystem{Profile: files.FilesystemArch}, files.TypeImplicitDir},
+		{"paths", "rpm", files.Filesystem{Paths: []string{"/srv/http/"}}, files.TypeImplicitDir},
+		{"none", "archlinux", files.Filesystem{Profile: files.FilesystemNone}, files.TypeDir},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			results, err := files.PrepareForPackagerWithFilesystem(
+				files.Contents{
+					{
+						Source:      filepath.Join("testdata", "tree"),
+						Destination: "/srv/http",
+						Type:        files.TypeTree,
+						FileInfo: &files.ContentFileInfo{
+							Owner: "http",
+						},
+					},
+				},
+				0,
+				tc.packager,
+				false,
+				mtime,
+				tc.filesystem,
+			)
+			require.NoError(t, err)
+
+			dir := contentsByDestination(results)["/srv/http/"]
+			require.NotNil(t, dir)
+			require.Equal(t, tc.typ, dir.Type)
+			if tc.typ == files.TypeImplicitDir {
+				require.Equal(t, "root", dir.FileInfo.Owner)
+			} else {
+				require.Equal(t, "http", dir.FileInfo.Owner)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := files.PrepareForPackagerWithFilesystem(
+			nil,
+			0,
+			"rpm",
+			false,
+			mtime,
+			files.Filesystem{Profile: "gentoo"},
+		)
+		require.EqualError(t, err, "invalid filesystem profile: gentoo")
+	})
+}
+
+func TestTreeExclude(t *testing.T) {
+	results, err := files.PrepareForPackager(
+		files.Contents{
+			{
+				Source:      filepath.Join("testdata", "tree"),
+				Destination: "/base",
+				Type:        files.TypeTree,
+				Exclude:     []string{"b", "symlinks/link*"},
+			},
+		},
+		0,
+		"",
+		false,
+		mtime,
+	)
+	require.NoError(t, err)
+
+	require.Equal(t, files.Contents{
+		{
+			Source:      "",
+			Destination: "/base/",
+			Type:        files.TypeDir,
+		},
+		{
+			Source:      "",
+			Destination: "/base/files/",
+			Type:        files.TypeDir,
+		},
+		{
+			Source:      filepath.Join("testdata", "tree", "files", "a"),
+			Destination: "/base/files/a",
+			Type:        files.TypeFile,
+		},
+		{
+			Source:      "",
+			Destination: "/base/symlinks/",
+			Type:        files.TypeDir,
+		},
+	}, withoutFileInfo(results))
+}
+
+func TestGlobExclude(t *testing.T) {
+	results, err := files.PrepareForPackager(
+		files.Contents{
+			{
+				Source:      filepath.Join("testdata", "tree", "files"),
+				Destination: "/base",
+				Exclude:     []string{"**/c"},
+			},
+		},
+		0,
+		"",
+		false,
+		mtime,
+	)
+	require.NoError(t, err)
+
+	require.Equal(t, files.Contents{
+		{
+			Source:      "testdata/tree/files/a",
+			Destination: "/base/a",
+			Type:        files.TypeFile,
+		},
+	}, withoutFileInfo(withoutImplicitDirs(results)))
+
+	_, err = files.PrepareForPackager(
+		files.Contents{
+			{
+				Source:      filepath.Join("testdata", "tree", "files", "b", "*"),
+				Destination: "/base",
+				Exclude:     []string{"c"},
+			},
+		},
+		0,
+		"",
+		false,
+		mtime,
+	)
+	require.Error(t, err)
+}
+
+func TestHardlinks(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "bin"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bin", "busybox"), []byte("busybox"), 0o755))
+	require.NoError(t, os.Link(filepath.Join(dir, "bin", "busybox"), filepath.Join(dir, "bin", "ash")))
+	require.NoError(t, os.Link(filepath.Join(dir, "bin", "busybox"), filepath.Join(dir, "bin", "sh")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bin", "other"), []byte("busybox"), 0o755))
+
+	for name, content := range map[string]*files.Content{
+		"tree": {
+			Source:      dir,
+			Destination: "/usr",
+			Type:        files.TypeTree,
+		},
+		"glob": {
+			Source:      filepath.Join(dir, "bin", "*"),
+			Destination: "/usr/bin/",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			results, err := files.PrepareForPackager(files.Contents{content}, 0o002, "", false, mtime)
+			require.NoError(t, err)
+
+			contents := contentsByDestination(results)
+			require.Equal(t, files.TypeFile, contents["/usr/bin/ash"].Type)
+			require.Equal(t, files.TypeFile, contents["/usr/bin/other"].Type)
+			for _, link := range []string{"/usr/bin/busybox", "/usr/bin/sh"} {
+				require.Equal(t, files.TypeHardlink, contents[link].Type, link)
+				require.Equal(t, "/usr/bin/ash", contents[link].Source, link)
+				require.Equal(t, contents["/usr/bin/ash"].FileInfo.Mode, contents[link].FileInfo.Mode, link)
+				require.Equal(t, int64(7), contents[link].Size(), link)
+			}
+		})
+	}
+}
+
+func TestHardlinkSameSourceTwice(t *testing.T) {
+	results, err := files.PrepareForPackager(files.Contents{
+		{
+			Source:      filepath.Join("testdata", "tree", "files", "a"),
+			Destination: "/usr/share/a",
+		},
+		{
+			Source:      filepath.Join("testdata", "tree", "files", "a"),
+			Destination: "/usr/share/b",
+		},
+	}, 0, "", false, mtime)
+	require.NoError(t, err)
+
+	contents := contentsByDestination(results)
+	require.Equal(t, files.TypeFile, contents["/usr/share/a"].Type)
+	require.Equal(t, files.TypeFile, contents["/usr/share/b"].Type)
+}
+
+func TestExplicitHardlink(t *testing.T) {
+	results, err := files.PrepareForPackager(files.Contents{
+		{
+			Source:      filepath.Join("testdata", "tree", "files", "a"),
+			Destination: "/usr/bin/a",
+			FileInfo:    &files.ContentFileInfo{Mode: 0o755, Owner: "foo"},
+		},
+		{
+			Source:      "usr/bin/a",
+			Destination: "/usr/bin/b",
+			Type:        files.TypeHardlink,
+		},
+	}, 0, "", false, mtime)
+	require.NoError(t, err)
+
+	contents := contentsByDestination(results)
+	link := contents["/usr/bin/b"]
+	require.Equal(t, files.TypeHardlink, link.Type)
+	require.Equal(t, "/usr/bin/a", link.Source)
+	require.Equal(t, fs.FileMode(0o755), link.FileInfo.Mode)
+	require.Equal(t, "foo", link.FileInfo.Owner)
+
+	_, err = files.PrepareForPackager(files.Contents{
+		{
+			Source:      "/usr/bin/a",
+			Destination: "/usr/bin/b",
+			Type:        files.TypeHardlink,
+		},
+	}, 0, "", false, mtime)
+	require.EqualError(t, err, "hardlink /usr/bin/b: target /usr/bin/a is not a regular file in the package")
+}
+
+func TestSpecialFiles(t *testing.T) {
+	var config testStruct
+	dec := yaml.NewDecoder(strings.NewReader(`---
+contents:
+- dst: /dev/null
+  type: chardev
+  file_info:
+    mode: 0666
+    devmajor: 1
+    devminor: 3
+- dst: /dev/loop0
+  type: blockdev
+  file_info:
+    devmajor: 7
+- dst: /run/foo/fifo
+  type: fifo
+`))
+	dec.KnownFields(true)
+	require.NoError(t, dec.Decode(&config))
+
+	results, err := files.PrepareForPackager(config.Contents, 0o022, "", false, mtime)
+	require.NoError(t, err)
+
+	contents := contentsByDestination(results)
+	null := contents["/dev/null"]
+	require.Equal(t, files.TypeCharDevice, null.Type)
+	require.Equal(t, fs.FileMode(0o666), null.FileInfo.Mode)
+	require.Equal(t, uint32(1), null.FileInfo.DevMajor)
+	require.Equal(t, uint32(3), null.FileInfo.DevMinor)
+
+	loop := contents["/dev/loop0"]
+	require.Equal(t, files.TypeBlockDevice, loop.Type)
+	require.Equal(t, fs.FileMode(0o644), loop.FileInfo.Mode)
+	require.Equal(t, uint32(7), loop.FileInfo.DevMajor)
+
+	require.Equal(t, files.TypeFIFO, contents["/run/foo/fifo"].Type)
+	require.Equal(t, files.TypeImplicitDir, contents["/run/foo/"].Type)
+
+	_, err = files.PrepareForPackager(files.Contents{
+		{
+			Source:      "/dev/null",
+			Destination: "/dev/null",
+			Type:        files.TypeCharDevice,
+		},
+	}, 0, "", false, mtime)
+	require.EqualError(t, err, "content for /dev/null: src cannot be set for chardev")
+}
+
 func TestTreeMode(t *testing.T) {
 	results, err := files.PrepareForPackager(
 		files.Contents{
@@ -1039,3 +1321,685 @@ contents:
 		require.Equal(t, expect[file.Destination], file.Type, "invalid type for %s", file.Destination)
 	}
 }
+
+func TestManPages(t *testing.T) {
+	results, err := files.PrepareForPackager(
+		files.Contents{
+			{
+				Source: filepath.Join("testdata", "man", "*"),
+				Type:   files.TypeManPage,
+			},
+		},
+		0,
+		"",
+		false,
+		mtime,
+	)
+	require.NoError(t, err)
+
+	require.Equal(t, files.Contents{
+		{Destination: "/usr/", Type: files.TypeImplicitDir},
+		{Destination: "/usr/share/", Type: files.TypeImplicitDir},
+		{Destination: "/usr/share/man/", Type: files.TypeImplicitDir},
+		{Destination: "/usr/share/man/man1/", Type: files.TypeImplicitDir},
+		{
+			Source:      "testdata/man/foo.1",
+			Destination: "/usr/share/man/man1/foo.1.gz",
+			Type:        files.TypeFile,
+		},
+		{Destination: "/usr/share/man/man5/", Type: files.TypeImplicitDir},
+		{
+			Source:      "testdata/man/foo.conf.5.md",
+			Destination: "/usr/share/man/man5/foo.conf.5.gz",
+			Type:        files.TypeFile,
+		},
+	}, withoutFileInfo(withoutData(results)))
+
+	roff, err := os.ReadFile(filepath.Join("testdata", "man", "foo.1"))
+	require.NoError(t, err)
+	require.Equal(t, roff, gunzipManPage(t, results[4]))
+	require.Equal(t, fs.FileMode(0o644), results[4].Mode())
+	require.Equal(t, int64(len(results[4].Data)), results[4].Size())
+
+	rendered := string(gunzipManPage(t, results[6]))
+	require.Contains(t, rendered, ".TH FOO.CONF 5\n")
+	require.Contains(t, rendered, ".SH NAME")
+}
+
+func TestManPagesDestination(t *testing.T) {
+	results, err := files.PrepareForPackager(
+		files.Contents{
+			{
+				Source:      filepath.Join("testdata", "man", "foo.1"),
+				Destination: "/opt/foo/man",
+				Type:        files.TypeManPage,
+				FileInfo:    &files.ContentFileInfo{Mode: 0o444},
+			},
+		},
+		0,
+		"",
+		false,
+		mtime,
+	)
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+	require.Equal(t, "/opt/foo/man/man1/foo.1.gz", results[4].Destination)
+	require.Equal(t, fs.FileMode(0o444), results[4].Mode())
+
+	// preparing the prepared contents again must keep the compressed data
+	again, err := files.PrepareForPackager(results, 0, "", false, mtime)
+	require.NoError(t, err)
+	require.Equal(t, results[4].Data, again[4].Data)
+}
+
+func TestManPagesInvalidSection(t *testing.T) {
+	_, err := files.PrepareForPackager(
+		files.Contents{
+			{
+				Source: filepath.Join("testdata", "tree", "files", "a"),
+				Type:   files.TypeManPage,
+			},
+		},
+		0,
+		"",
+		false,
+		mtime,
+	)
+	require.ErrorContains(t, err, "cannot determine man page section of testdata/tree/files/a")
+}
+
+func gunzipManPage(tb testing.TB, content *files.Content) []byte {
+	tb.Helper()
+
+	gz, err := gzip.NewReader(bytes.NewReader(content.Data))
+	require.NoError(tb, err)
+	require.True(tb, gz.ModTime.IsZero())
+	require.Empty(tb, gz.Name)
+	data, err := io.ReadAll(gz)
+	require.NoError(tb, err)
+	return data
+}
+
+func withoutData(contents files.Contents) files.Contents {
+	filtered := make(files.Contents, 0, len(contents))
+
+	for _, c := range contents {
+		cc := *c
+		cc.Data = nil
+		filtered = append(filtered, &cc)
+	}
+
+	return filtered
+}
+
+func TestInlineContent(t *testing.T) {
+	var config testStruct
+	dec := yaml.NewDecoder(strings.NewReader(`---
+contents:
+- dst: /etc/default/foo
+  type: config|noreplace
+  content: |
+    FOO=bar
+- dst: /usr/share/foo/default.yaml
+  file_info:
+    mode: 0600
+  content: "foo: bar"
+`))
+	dec.KnownFields(true)
+	require.NoError(t, dec.Decode(&config))
+
+	results, err := files.PrepareForPackager(config.Contents, 0o002, "", false, mtime)
+	require.NoError(t, err)
+
+	contents := map[string]*files.Content{}
+	for _, c := range results {
+		contents[c.Destination] = c
+	}
+
+	defaults := contents["/etc/default/foo"]
+	require.NotNil(t, defaults)
+	require.Equal(t, files.TypeConfigNoReplace, defaults.Type)
+	require.Empty(t, defaults.Source)
+	require.Equal(t, []byte("FOO=bar\n"), defaults.Data)
+	require.Equal(t, int64(8), defaults.Size())
+	require.Equal(t, fs.FileMode(0o644), defaults.Mode())
+	require.Equal(t, mtime, defaults.ModTime())
+	data, err := defaults.ReadData()
+	require.NoError(t, err)
+	require.Equal(t, []byte("FOO=bar\n"), data)
+
+	yml := contents["/usr/share/foo/default.yaml"]
+	require.NotNil(t, yml)
+	require.Equal(t, files.TypeFile, yml.Type)
+	require.Equal(t, fs.FileMode(0o600), yml.Mode())
+	r, err := yml.Open()
+	require.NoError(t, err)
+	data, err = io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Equal(t, "foo: bar", string(data))
+
+	require.Contains(t, contents, "/usr/share/foo/")
+	require.Equal(t, files.TypeImplicitDir, contents["/usr/share/foo/"].Type)
+}
+
+func TestStrip(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("test binary is not an ELF file")
+	}
+
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is needed to build a binary with symbols")
+	}
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.go")
+	require.NoError(t, os.WriteFile(main, []byte("package main\n\nfunc main() { println(\"foo\") }\n"), 0o600))
+	src := filepath.Join(dir, "foo")
+	build := exec.Command(gobin, "build", "-o", src, main)
+	build.Env = append(os.Environ(), "CGO_ENABLED=0")
+	out, err := build.CombinedOutput()
+	require.NoError(t, err, string(out))
+	orig, err := os.ReadFile(src)
+	require.NoError(t, err)
+
+	results, err := files.PrepareForPackager(files.Contents{
+		{
+			Source:      src,
+			Destination: "/usr/bin/foo",
+			FileInfo:    &files.ContentFileInfo{Strip: true},
+		},
+		{
+			Destination: "/etc/foo.conf",
+			Type:        files.TypeConfig,
+			Inline:      "foo: bar",
+			FileInfo:    &files.ContentFileInfo{Strip: true},
+		},
+	}, 0, "", false, mtime)
+	require.NoError(t, err)
+
+	contents := map[string]*files.Content{}
+	for _, c := range results {
+		contents[c.Destination] = c
+	}
+
+	bin := contents["/usr/bin/foo"]
+	require.NotNil(t, bin)
+	data, err := bin.ReadData()
+	require.NoError(t, err)
+	require.Less(t, len(data), len(orig))
+	require.Equal(t, int64(len(data)), bin.Size())
+
+	f, err := elf.NewFile(bytes.NewReader(data))
+	require.NoError(t, err)
+	for _, s := range f.Sections {
+		require.NotEqual(t, elf.SHT_SYMTAB, s.Type, s.Name)
+		require.False(t, strings.HasPrefix(s.Name, ".debug_"), s.Name)
+	}
+	require.NotNil(t, f.Section(".text"))
+	require.NoError(t, f.Close())
+
+	// the stripped binary must still run
+	stripped := filepath.Join(t.TempDir(), "stripped")
+	require.NoError(t, os.WriteFile(stripped, data, 0o755))
+	out, err = exec.Command(stripped).CombinedOutput()
+	require.NoError(t, err)
+	require.Equal(t, "foo\n", string(out))
+
+	conf := contents["/etc/foo.conf"]
+	require.NotNil(t, conf)
+	require.Equal(t, []byte("foo: bar"), conf.Data)
+	require.Equal(t, int64(8), conf.Size())
+}
+
+func TestInlineContentWithSource(t *testing.T) {
+	_, err := files.PrepareForPackager(files.Contents{
+		{
+			Source:      filepath.Join("testdata", "tree", "files", "a"),
+			Destination: "/etc/foo",
+			Inline:      "foo",
+		},
+	}, 0, "", false, mtime)
+	require.EqualError(t, err, "content for /etc/foo: src and content cannot be set both")
+}
+
+func TestInlineContentCollision(t *testing.T) {
+	_, err := files.PrepareForPackager(files.Contents{
+		{
+			Source:      filepath.Join("testdata", "tree", "files", "a"),
+			Destination: "/etc/foo",
+		},
+		{
+			Destination: "/etc/foo",
+			Inline:      "foo",
+		},
+	}, 0, "", false, mtime)
+	require.ErrorIs(t, err, files.ErrContentCollision)
+}
+
+type archiveMember struct {
+	hdr  tar.Header
+	body string
+}
+
+func writeTarGz(tb testing.TB, w io.Writer, members ...archiveMember) {
+	tb.Helper()
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, m := range members {
+		hdr := m.hdr
+		hdr.Size = int64(len(m.body))
+		if hdr.ModTime.IsZero() {
+			hdr.ModTime = mtime
+		}
+		require.NoError(tb, tw.WriteHeader(&hdr))
+		_, err := tw.Write([]byte(m.body))
+		require.NoError(tb, err)
+	}
+	require.NoError(tb, tw.Close())
+	require.NoError(tb, gz.Close())
+}
+
+func contentsByDestination(contents files.Contents) map[string]*files.Content {
+	result := map[string]*files.Content{}
+	for _, c := range contents {
+		result[c.Destination] = c
+	}
+	return result
+}
+
+func TestArchiveTarGz(t *testing.T) {
+	archiveTime := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	src := filepath.Join(t.TempDir(), "foo.tar.gz")
+	f, err := os.Create(src)
+	require.NoError(t, err)
+	writeTarGz(t, f,
+		archiveMember{hdr: tar.Header{Name: "./", Typeflag: tar.TypeDir, Mode: 0o700}},
+		archiveMember{hdr: tar.Header{Name: "./bin/", Typeflag: tar.TypeDir, Mode: 0o750, Uname: "foo", Gname: "bar"}},
+		archiveMember{hdr: tar.Header{Name: "./bin/foo", Typeflag: tar.TypeReg, Mode: 0o4755, Uname: "foo", Gname: "bar", ModTime: archiveTime}, body: "#!/bin/sh\n"},
+		archiveMember{hdr: tar.Header{Name: "./bin/foo-link", Typeflag: tar.TypeSymlink, Linkname: "foo"}},
+		archiveMember{hdr: tar.Header{Name: "./bin/foo-hard", Typeflag: tar.TypeLink, Linkname: "./bin/foo", Mode: 0o755}},
+		archiveMember{hdr: tar.Header{Name: "share/doc/README", Typeflag: tar.TypeReg, Mode: 0o644}, body: "hello"},
+		archiveMember{hdr: tar.Header{Name: "../../escape", Typeflag: tar.TypeReg, Mode: 0o600}, body: "x"},
+	)
+	require.NoError(t, f.Close())
+
+	results, err := files.PrepareForPackager(files.Contents{
+		{
+			Source:      src,
+			Destination: "/opt/foo",
+			Type:        files.TypeArchive,
+		},
+	}, 0o002, "", false, mtime)
+	require.NoError(t, err)
+
+	contents := contentsByDestination(results)
+	require.Equal(t, []string{
+		"/opt/",
+		"/opt/foo/",
+		"/opt/foo/bin/",
+		"/opt/foo/bin/foo",
+		"/opt/foo/bin/foo-hard",
+		"/opt/foo/bin/foo-link",
+		"/opt/foo/escape",
+		"/opt/foo/share/",
+		"/opt/foo/share/doc/",
+		"/opt/foo/share/doc/README",
+	}, func() []string {
+		var names []string
+		for _, c := range results {
+			names = append(names, c.Destination)
+		}
+		return names
+	}())
+
+	require.Equal(t, files.TypeImplicitDir, contents["/opt/foo/"].Type)
+	require.Equal(t, files.TypeImplicitDir, contents["/opt/foo/share/"].Type)
+
+	bin := contents["/opt/foo/bin/"]
+	require.Equal(t, files.TypeDir, bin.Type)
+	require.Equal(t, fs.FileMode(0o750), bin.FileInfo.Mode)
+	require.Equal(t, "foo", bin.FileInfo.Owner)
+	require.Equal(t, "bar", bin.FileInfo.Group)
+
+	foo := contents["/opt/foo/bin/foo"]
+	require.Equal(t, files.TypeFile, foo.Type)
+	require.Equal(t, []byte("#!/bin/sh\n"), foo.Data)
+	require.Equal(t, fs.FileMode(0o4755), foo.FileInfo.Mode)
+	require.Equal(t, "foo", foo.FileInfo.Owner)
+	require.Equal(t, "bar", foo.FileInfo.Group)
+	require.True(t, archiveTime.Equal(foo.FileInfo.MTime))
+	require.Equal(t, int64(10), foo.Size())
+
+	hard := contents["/opt/foo/bin/foo-hard"]
+	require.Equal(t, files.TypeHardlink, hard.Type)
+	require.Equal(t, "/opt/foo/bin/foo", hard.Source)
+	require.Equal(t, fs.FileMode(0o4755), hard.FileInfo.Mode)
+	require.Equal(t, "foo", hard.FileInfo.Owner)
+
+	link := contents["/opt/foo/bin/foo-link"]
+	require.Equal(t, files.TypeSymlink, link.Type)
+	require.Equal(t, "foo", link.Source)
+
+	readme := contents["/opt/foo/share/doc/README"]
+	require.Equal(t, "root", readme.FileInfo.Owner)
+	require.Equal(t, fs.FileMode(0o644), readme.FileInfo.Mode)
+}
+
+func TestArchiveFileInfoOverride(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "foo.tgz")
+	f, err := os.Create(src)
+	require.NoError(t, err)
+	writeTarGz(t, f,
+		archiveMember{hdr: tar.Header{Name: "foo", Typeflag: tar.TypeReg, Mode: 0o755, Uname: "foo"}, body: "foo"},
+		archiveMember{hdr: tar.Header{Name: "bar", Typeflag: tar.TypeSymlink, Linkname: "foo", Uname: "foo"}},
+	)
+	require.NoError(t, f.Close())
+
+	results, err := files.PrepareForPackager(files.Contents{
+		{
+			Source:      src,
+			Destination: "/usr/lib/foo",
+			Type:        files.TypeArchive,
+			FileInfo: &files.ContentFileInfo{
+				Owner: "baz",
+				Mode:  0o700,
+			},
+		},
+	}, 0, "", false, mtime)
+	require.NoError(t, err)
+
+	contents := contentsByDestination(results)
+	require.Equal(t, "baz", contents["/usr/lib/foo/foo"].FileInfo.Owner)
+	require.Equal(t, fs.FileMode(0o700), contents["/usr/lib/foo/foo"].FileInfo.Mode)
+	require.Equal(t, "baz", contents["/usr/lib/foo/bar"].FileInfo.Owner)
+	require.Equal(t, files.TypeSymlink, contents["/usr/lib/foo/bar"].Type)
+}
+
+func TestNumericOwner(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "foo.tgz")
+	f, err := os.Create(src)
+	require.NoError(t, err)
+	writeTarGz(t, f,
+		archiveMember{hdr: tar.Header{Name: "foo", Typeflag: tar.TypeReg, Mode: 0o644, Uid: 1000, Gid: 1001}, body: "foo"},
+		archiveMember{hdr: tar.Header{Name: "bar", Typeflag: tar.TypeReg, Mode: 0o644, Uid: 1000, Gid: 1001, Uname: "foo", Gname: "foo"}, body: "bar"},
+	)
+	require.NoError(t, f.Close())
+
+	results, err := files.PrepareForPackager(files.Contents{
+		{
+			Source:      "../testdata/fake",
+			Destination: "/usr/bin/fake",
+			FileInfo:    &files.ContentFileInfo{UID: 950, GID: 950},
+		},
+		{
+			Source:      "../testdata/whatever.conf",
+			Destination: "/etc/whatever.conf",
+			Type:        files.TypeConfig,
+			FileInfo:    &files.ContentFileInfo{Group: "adm", GID: 4},
+		},
+		{
+			Source:      src,
+			Destination: "/opt/foo",
+			Type:        files.TypeArchive,
+		},
+		{
+			Source:      src,
+			Destination: "/opt/bar",
+			Type:        files.TypeArchive,
+			FileInfo:    &files.ContentFileInfo{UID: 950},
+		},
+	}, 0, "", false, mtime)
+	require.NoError(t, err)
+
+	contents := contentsByDestination(results)
+	require.Equal(t, "", contents["/usr/bin/fake"].FileInfo.Owner)
+	require.Equal(t, "", contents["/usr/bin/fake"].FileInfo.Group)
+	require.Equal(t, uint32(950), contents["/usr/bin/fake"].FileInfo.UID)
+	require.Equal(t, uint32(950), contents["/usr/bin/fake"].FileInfo.GID)
+	require.Equal(t, "root", contents["/etc/whatever.conf"].FileInfo.Owner)
+	require.Equal(t, "adm", contents["/etc/whatever.conf"].FileInfo.Group)
+	require.Equal(t, uint32(4), contents["/etc/whatever.conf"].FileInfo.GID)
+
+	require.Equal(t, "", contents["/opt/foo/foo"].FileInfo.Owner)
+	require.Equal(t, uint32(1000), contents["/opt/foo/foo"].FileInfo.UID)
+	require.Equal(t, uint32(1001), contents["/opt/foo/foo"].FileInfo.GID)
+	require.Equal(t, "foo", contents["/opt/foo/bar"].FileInfo.Owner)
+	require.Equal(t, uint32(1000), contents["/opt/foo/bar"].FileInfo.UID)
+
+	// owner names and ids are overridden together
+	require.Equal(t, "", contents["/opt/bar/bar"].FileInfo.Owner)
+	require.Equal(t, uint32(950), contents["/opt/bar/bar"].FileInfo.UID)
+	require.Equal(t, "foo", contents["/opt/bar/bar"].FileInfo.Group)
+	require.Equal(t, uint32(1001), contents["/opt/bar/bar"].FileInfo.GID)
+}
+
+func TestArchiveSpecialFiles(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "dev.tar.gz")
+	f, err := os.Create(src)
+	require.NoError(t, err)
+	writeTarGz(t, f,
+		archiveMember{hdr: tar.Header{Name: "dev/null", Typeflag: tar.TypeChar, Mode: 0o666, Devmajor: 1, Devminor: 3}},
+		archiveMember{hdr: tar.Header{Name: "dev/loop0", Typeflag: tar.TypeBlock, Mode: 0o660, Devmajor: 7}},
+		archiveMember{hdr: tar.Header{Name: "run/foo.fifo", Typeflag: tar.TypeFifo, Mode: 0o600}},
+	)
+	require.NoError(t, f.Close())
+
+	results, err := files.PrepareForPackager(files.Contents{
+		{Source: src, Destination: "/", Type: files.TypeArchive},
+	}, 0, "", false, mtime)
+	require.NoError(t, err)
+
+	contents := contentsByDestination(results)
+	null := contents["/dev/null"]
+	require.Equal(t, files.TypeCharDevice, null.Type)
+	require.Equal(t, fs.FileMode(0o666), null.FileInfo.Mode)
+	require.Equal(t, uint32(1), null.FileInfo.DevMajor)
+	require.Equal(t, uint32(3), null.FileInfo.DevMinor)
+	require.Equal(t, byte(tar.TypeChar), files.SpecialFileTypeflag(null))
+	loop := contents["/dev/loop0"]
+	require.Equal(t, files.TypeBlockDevice, loop.Type)
+	require.Equal(t, uint32(7), loop.FileInfo.DevMajor)
+	require.Equal(t, byte(tar.TypeBlock), files.SpecialFileTypeflag(loop))
+	fifo := contents["/run/foo.fifo"]
+	require.Equal(t, files.TypeFIFO, fifo.Type)
+	require.Equal(t, byte(tar.TypeFifo), files.SpecialFileTypeflag(fifo))
+}
+
+func TestArchiveCollision(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "foo.tgz")
+	f, err := os.Create(src)
+	require.NoError(t, err)
+	writeTarGz(t, f,
+		archiveMember{hdr: tar.Header{Name: "bin/", Typeflag: tar.TypeDir, Mode: 0o755}},
+		archiveMember{hdr: tar.Header{Name: "bin/foo", Typeflag: tar.TypeReg, Mode: 0o755}, body: "foo"},
+	)
+	require.NoError(t, f.Close())
+
+	for _, order := range []string{"file first", "archive first"} {
+		t.Run(order, func(t *testing.T) {
+			contents := files.Contents{
+				{Source: "../testdata/fake", Destination: "/opt/foo/bin/foo"},
+				{Source: src, Destination: "/opt/foo", Type: files.TypeArchive},
+			}
+			if order == "archive first" {
+				contents[0], contents[1] = contents[1], contents[0]
+			}
+			_, err := files.PrepareForPackager(contents, 0, "", false, mtime)
+			require.ErrorIs(t, err, files.ErrContentCollision)
+		})
+	}
+
+	t.Run("directories", func(t *testing.T) {
+		results, err := files.PrepareForPackager(files.Contents{
+			{Destination: "/opt/foo/bin", Type: files.TypeDir, FileInfo: &files.ContentFileInfo{Mode: 0o700}},
+			{Source: src, Destination: "/opt/foo", Type: files.TypeArchive},
+		}, 0, "", false, mtime)
+		require.NoError(t, err)
+		contents := contentsByDestination(results)
+		require.Equal(t, files.TypeDir, contents["/opt/foo/bin/"].Type)
+		require.Equal(t, fs.FileMode(0o700), contents["/opt/foo/bin/"].FileInfo.Mode)
+		require.Equal(t, []byte("foo"), contents["/opt/foo/bin/foo"].Data)
+	})
+}
+
+func TestArchiveExcludedHardlinkTarget(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "foo.tar.gz")
+	f, err := os.Create(src)
+	require.NoError(t, err)
+	writeTarGz(t, f,
+		archiveMember{hdr: tar.Header{Name: "foo", Typeflag: tar.TypeReg, Mode: 0o755}, body: "foo"},
+		archiveMember{hdr: tar.Header{Name: "bar", Typeflag: tar.TypeLink, Linkname: "foo", Mode: 0o755}},
+	)
+	require.NoError(t, f.Close())
+
+	results, err := files.PrepareForPackager(files.Contents{
+		{
+			Source:      src,
+			Destination: "/opt/foo",
+			Type:        files.TypeArchive,
+			Exclude:     []string{"foo"},
+		},
+	}, 0, "", false, mtime)
+	require.NoError(t, err)
+
+	contents := contentsByDestination(results)
+	require.NotContains(t, contents, "/opt/foo/foo")
+	require.Equal(t, files.TypeFile, contents["/opt/foo/bar"].Type)
+	require.Equal(t, []byte("foo"), contents["/opt/foo/bar"].Data)
+}
+
+func TestArchiveZip(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "foo.zip")
+	f, err := os.Create(src)
+	require.NoError(t, err)
+	zw := zip.NewWriter(f)
+
+	dir := &zip.FileHeader{Name: "lib/", Modified: mtime}
+	dir.SetMode(fs.ModeDir | 0o755)
+	_, err = zw.CreateHeader(dir)
+	require.NoError(t, err)
+
+	file := &zip.FileHeader{Name: "lib/foo.so", Modified: mtime, Method: zip.Deflate}
+	file.SetMode(0o755)
+	w, err := zw.CreateHeader(file)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("ELF"))
+	require.NoError(t, err)
+
+	link := &zip.FileHeader{Name: "lib/foo.so.1", Modified: mtime}
+	link.SetMode(fs.ModeSymlink | 0o777)
+	w, err = zw.CreateHeader(link)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("foo.so"))
+	require.NoError(t, err)
+
+	require.NoError(t, zw.Close())
+	require.NoError(t, f.Close())
+
+	results, err := files.PrepareForPackager(files.Contents{
+		{
+			Source:      src,
+			Destination: "/opt/foo",
+			Type:        files.TypeArchive,
+		},
+	}, 0, "", false, mtime)
+	require.NoError(t, err)
+
+	contents := contentsByDestination(results)
+	require.Equal(t, files.TypeDir, contents["/opt/foo/lib/"].Type)
+	require.Equal(t, fs.FileMode(0o755), contents["/opt/foo/lib/"].FileInfo.Mode)
+	require.Equal(t, files.TypeFile, contents["/opt/foo/lib/foo.so"].Type)
+	require.Equal(t, []byte("ELF"), contents["/opt/foo/lib/foo.so"].Data)
+	require.Equal(t, fs.FileMode(0o755), contents["/opt/foo/lib/foo.so"].FileInfo.Mode)
+	require.Equal(t, "root", contents["/opt/foo/lib/foo.so"].FileInfo.Owner)
+	require.Equal(t, files.TypeSymlink, contents["/opt/foo/lib/foo.so.1"].Type)
+	require.Equal(t, "foo.so", contents["/opt/foo/lib/foo.so.1"].Source)
+}
+
+func writeOCIBlob(tb testing.TB, dir string, data []byte) string {
+	tb.Helper()
+
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	require.NoError(tb, os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0o755))
+	require.NoError(tb, os.WriteFile(filepath.Join(dir, "blobs", "sha256", digest), data, 0o644))
+	return "sha256:" + digest
+}
+
+func TestArchiveOCILayout(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0o644))
+
+	var base bytes.Buffer
+	writeTarGz(t, &base,
+		archiveMember{hdr: tar.Header{Name: "etc/", Typeflag: tar.TypeDir, Mode: 0o755}},
+		archiveMember{hdr: tar.Header{Name: "etc/foo.conf", Typeflag: tar.TypeReg, Mode: 0o644}, body: "old"},
+		archiveMember{hdr: tar.Header{Name: "etc/removed", Typeflag: tar.TypeReg, Mode: 0o644}, body: "removed"},
+		archiveMember{hdr: tar.Header{Name: "var/cache/", Typeflag: tar.TypeDir, Mode: 0o755}},
+		archiveMember{hdr: tar.Header{Name: "var/cache/old", Typeflag: tar.TypeReg, Mode: 0o644}, body: "old"},
+	)
+	var top bytes.Buffer
+	writeTarGz(t, &top,
+		archiveMember{hdr: tar.Header{Name: "etc/", Typeflag: tar.TypeDir, Mode: 0o755}},
+		archiveMember{hdr: tar.Header{Name: "etc/foo.conf", Typeflag: tar.TypeReg, Mode: 0o600}, body: "new"},
+		archiveMember{hdr: tar.Header{Name: "etc/.wh.removed", Typeflag: tar.TypeReg}},
+		archiveMember{hdr: tar.Header{Name: "var/cache/.wh..wh..opq", Typeflag: tar.TypeReg}},
+		archiveMember{hdr: tar.Header{Name: "var/cache/new", Typeflag: tar.TypeReg, Mode: 0o644}, body: "new"},
+	)
+
+	const layerType = "application/vnd.oci.image.layer.v1.tar+gzip"
+	manifest := fmt.Sprintf(`{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.oci.image.manifest.v1+json",
+  "layers": [
+    {"mediaType": %q, "digest": %q},
+    {"mediaType": %q, "digest": %q}
+  ]
+}`, layerType, writeOCIBlob(t, dir, base.Bytes()), layerType, writeOCIBlob(t, dir, top.Bytes()))
+	index := fmt.Sprintf(`{
+  "schemaVersion": 2,
+  "manifests": [
+    {"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": %q}
+  ]
+}`, writeOCIBlob(t, dir, []byte(manifest)))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "index.json"), []byte(index), 0o644))
+
+	results, err := files.PrepareForPackager(files.Contents{
+		{
+			Source:      dir,
+			Destination: "/srv/rootfs",
+			Type:        files.TypeArchive,
+		},
+	}, 0, "", false, mtime)
+	require.NoError(t, err)
+
+	contents := contentsByDestination(results)
+	require.Equal(t, []byte("new"), contents["/srv/rootfs/etc/foo.conf"].Data)
+	require.Equal(t, fs.FileMode(0o600), contents["/srv/rootfs/etc/foo.conf"].FileInfo.Mode)
+	require.NotContains(t, contents, "/srv/rootfs/etc/removed")
+	require.NotContains(t, contents, "/srv/rootfs/var/cache/old")
+	require.Contains(t, contents, "/srv/rootfs/var/cache/new")
+	require.Contains(t, contents, "/srv/rootfs/var/cache/")
+}
+
+func TestArchiveUnsupported(t *testing.T) {
+	_, err := files.PrepareForPackager(files.Contents{
+		{
+			Source:      filepath.Join("testdata", "tree", "files", "a"),
+			Destination: "/opt/foo",
+			Type:        files.TypeArchive,
+		},
+	}, 0, "", false, mtime)
+	require.ErrorIs(t, err, files.ErrUnsupportedArchive)
+
+	_, err = files.PrepareForPackager(files.Contents{
+		{
+			Source:      filepath.Join("testdata", "tree"),
+			Destination: "/opt/foo",
+			Type:        files.TypeArchive,
+		},
+	}, 0, "", false, mtime)
+	require.ErrorIs(t, err, files.ErrUnsupportedArchive)
+}
@@ -1,19 +1,90 @@
 package files
 
-func ownedByFilesystem(path string) bool {
-	p := ToNixPath(path)
-	for _, pp := range append(fsPaths, logrotatePaths...) {
-		if p == pp {
-			return true
+import "fmt"
+
+// Filesystem profiles name the directories that are owned by the base
+// filesystem of a distribution. Trees and archives do not claim ownership of
+// these directories.
+const (
+	FilesystemFedora  = "fedora"
+	FilesystemDebian  = "debian"
+	FilesystemAlpine  = "alpine"
+	FilesystemArch    = "arch"
+	FilesystemOpenWrt = "openwrt"
+	// FilesystemNone treats no directory as owned by the filesystem.
+	FilesystemNone = "none"
+)
+
+// Filesystem selects the directories that are owned by the base filesystem
+// of the target distribution.
+type Filesystem struct {
+	// Profile defaults to the profile of the distribution that the packager
+	// targets.
+	Profile string `yaml:"profile,omitempty" json:"profile,omitempty" jsonschema:"title=filesystem profile,enum=fedora,enum=debian,enum=alpine,enum=arch,enum=openwrt,enum=none,default=depends on the packager"`
+	// Paths are owned by the filesystem in addition to the ones of the
+	// profile.
+	Paths []string `yaml:"paths,omitempty" json:"paths,omitempty" jsonschema:"title=additional directories owned by the filesystem,example=/opt/vendor"`
+}
+
+// nolint: gochecknoglobals
+var packagerFilesystems = map[string]string{
+	"apk":       FilesystemAlpine,
+	"archlinux": FilesystemArch,
+	"deb":       FilesystemDebian,
+	"dsc":       FilesystemDebian,
+	"ipk":       FilesystemOpenWrt,
+	"rpm":       FilesystemFedora,
+	"srpm":      FilesystemFedora,
+}
+
+// nolint: gochecknoglobals
+var filesystemProfiles = map[string][][]string{
+	FilesystemFedora:  {fedoraPaths, logrotatePaths},
+	FilesystemDebian:  {debianPaths},
+	FilesystemAlpine:  {alpinePaths},
+	FilesystemArch:    {archPaths},
+	FilesystemOpenWrt: {openwrtPaths},
+	FilesystemNone:    nil,
+}
+
+// ownedPaths holds the directories that are owned by the filesystem.
+type ownedPaths map[string]struct{}
+
+// owned returns the directories that are owned by the filesystem for the
+// given packager. Contents prepared for any packager, and packagers unknown
+// to nFPM, use the fedora profile.
+func (f Filesystem) owned(packager string) (ownedPaths, error) {
+	profile := f.Profile
+	if profile == "" {
+		profile = packagerFilesystems[packager]
+	}
+	if profile == "" {
+		profile = FilesystemFedora
+	}
+
+	lists, ok := filesystemProfiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("invalid filesystem profile: %s", profile)
+	}
+
+	owned := ownedPaths{}
+	for _, paths := range append(lists, f.Paths) {
+		for _, p := range paths {
+			owned[ToNixPath(p)] = struct{}{}
 		}
 	}
-	return false
+	return owned, nil
+}
+
+func (o ownedPaths) has(path string) bool {
+	_, ok := o[ToNixPath(path)]
+	return ok
 }
 
 // yum install yum-utils
 
 // repoquery --installed -l filesystem | while read -r f; do test -d "\"$f\"," && echo $f; done
-var fsPaths = []string{
+var fedoraPaths = []string{
 	"/afs",
 	"/bin",
 	"/boot",
@@ -222,3 +293,293 @@ var logrotatePaths = []string{
 	"/usr/share/licenses/logrotate",
 	"/var/lib/logrotate",
 }
+
+// dpkg -L base-files | while read -r f; do test -d "$f" && echo $f; done,
+// plus the directories that the essential and systemd packages ship.
+var debianPaths = []string{
+	"/bin",
+	"/boot",
+	"/dev",
+	"/etc",
+	"/etc/apt",
+	"/etc/apt/apt.conf.d",
+	"/etc/apt/preferences.d",
+	"/etc/apt/sources.list.d",
+	"/etc/apt/trusted.gpg.d",
+	"/etc/cron.d",
+	"/etc/cron.daily",
+	"/etc/default",
+	"/etc/init.d",
+	"/etc/logrotate.d",
+	"/etc/profile.d",
+	"/etc/skel",
+	"/etc/systemd",
+	"/etc/systemd/system",
+	"/etc/update-motd.d",
+	"/home",
+	"/lib",
+	"/lib64",
+	"/media",
+	"/mnt",
+	"/opt",
+	"/proc",
+	"/root",
+	"/run",
+	"/sbin",
+	"/srv",
+	"/sys",
+	"/tmp",
+	"/usr",
+	"/usr/bin",
+	"/usr/games",
+	"/usr/include",
+	"/usr/lib",
+	"/usr/lib/systemd",
+	"/usr/lib/systemd/system",
+	"/usr/lib/systemd/user",
+	"/usr/lib/sysusers.d",
+	"/usr/lib/tmpfiles.d",
+	"/usr/lib64",
+	"/usr/libexec",
+	"/usr/local",
+	"/usr/local/bin",
+	"/usr/local/etc",
+	"/usr/local/games",
+	"/usr/local/include",
+	"/usr/local/lib",
+	"/usr/local/sbin",
+	"/usr/local/share",
+	"/usr/local/share/man",
+	"/usr/local/src",
+	"/usr/sbin",
+	"/usr/share",
+	"/usr/share/applications",
+	"/usr/share/base-files",
+	"/usr/share/bash-completion",
+	"/usr/share/bash-completion/completions",
+	"/usr/share/common-licenses",
+	"/usr/share/dict",
+	"/usr/share/doc",
+	"/usr/share/icons",
+	"/usr/share/info",
+	"/usr/share/lintian",
+	"/usr/share/lintian/overrides",
+	"/usr/share/locale",
+	"/usr/share/man",
+	"/usr/share/man/man1",
+	"/usr/share/man/man2",
+	"/usr/share/man/man3",
+	"/usr/share/man/man4",
+	"/usr/share/man/man5",
+	"/usr/share/man/man6",
+	"/usr/share/man/man7",
+	"/usr/share/man/man8",
+	"/usr/share/misc",
+	"/usr/share/pixmaps",
+	"/usr/share/zsh",
+	"/usr/share/zsh/vendor-completions",
+	"/usr/src",
+	"/var",
+	"/var/backups",
+	"/var/cache",
+	"/var/lib",
+	"/var/lib/dpkg",
+	"/var/lib/misc",
+	"/var/local",
+	"/var/lock",
+	"/var/log",
+	"/var/mail",
+	"/var/opt",
+	"/var/run",
+	"/var/spool",
+	"/var/tmp",
+}
+
+// apk info -L alpine-baselayout alpine-baselayout-data | while read -r f; do test -d "/$f" && echo /$f; done
+var alpinePaths = []string{
+	"/bin",
+	"/dev",
+	"/etc",
+	"/etc/apk",
+	"/etc/conf.d",
+	"/etc/crontabs",
+	"/etc/init.d",
+	"/etc/logrotate.d",
+	"/etc/modprobe.d",
+	"/etc/modules-load.d",
+	"/etc/network",
+	"/etc/network/if-down.d",
+	"/etc/network/if-post-down.d",
+	"/etc/network/if-pre-up.d",
+	"/etc/network/if-up.d",
+	"/etc/opt",
+	"/etc/periodic",
+	"/etc/periodic/15min",
+	"/etc/periodic/daily",
+	"/etc/periodic/hourly",
+	"/etc/periodic/monthly",
+	"/etc/periodic/weekly",
+	"/etc/profile.d",
+	"/etc/sysctl.d",
+	"/home",
+	"/lib",
+	"/lib/firmware",
+	"/lib/modules-load.d",
+	"/lib/sysctl.d",
+	"/media",
+	"/media/cdrom",
+	"/media/floppy",
+	"/media/usb",
+	"/mnt",
+	"/opt",
+	"/proc",
+	"/root",
+	"/run",
+	"/sbin",
+	"/srv",
+	"/sys",
+	"/tmp",
+	"/usr",
+	"/usr/bin",
+	"/usr/lib",
+	"/usr/lib/modules-load.d",
+	"/usr/local",
+	"/usr/local/bin",
+	"/usr/local/lib",
+	"/usr/local/share",
+	"/usr/sbin",
+	"/usr/share",
+	"/usr/share/man",
+	"/usr/share/misc",
+	"/var",
+	"/var/cache",
+	"/var/cache/misc",
+	"/var/empty",
+	"/var/lib",
+	"/var/lib/misc",
+	"/var/local",
+	"/var/lock",
+	"/var/lock/subsys",
+	"/var/log",
+	"/var/mail",
+	"/var/opt",
+	"/var/run",
+	"/var/spool",
+	"/var/spool/cron",
+	"/var/spool/mail",
+	"/var/tmp",
+}
+
+// pacman -Qlq filesystem | grep '/$'
+var archPaths = []string{
+	"/bin",
+	"/boot",
+	"/dev",
+	"/etc",
+	"/etc/ld.so.conf.d",
+	"/etc/profile.d",
+	"/etc/skel",
+	"/home",
+	"/lib",
+	"/lib64",
+	"/mnt",
+	"/opt",
+	"/proc",
+	"/root",
+	"/run",
+	"/sbin",
+	"/srv",
+	"/srv/ftp",
+	"/srv/http",
+	"/sys",
+	"/tmp",
+	"/usr",
+	"/usr/bin",
+	"/usr/include",
+	"/usr/lib",
+	"/usr/lib/sysctl.d",
+	"/usr/lib/sysusers.d",
+	"/usr/lib/tmpfiles.d",
+	"/usr/lib64",
+	"/usr/local",
+	"/usr/local/bin",
+	"/usr/local/etc",
+	"/usr/local/games",
+	"/usr/local/include",
+	"/usr/local/lib",
+	"/usr/local/man",
+	"/usr/local/sbin",
+	"/usr/local/share",
+	"/usr/local/share/man",
+	"/usr/local/src",
+	"/usr/sbin",
+	"/usr/share",
+	"/usr/share/factory",
+	"/usr/share/factory/etc",
+	"/usr/share/man",
+	"/usr/share/man/man1",
+	"/usr/share/man/man2",
+	"/usr/share/man/man3",
+	"/usr/share/man/man4",
+	"/usr/share/man/man5",
+	"/usr/share/man/man6",
+	"/usr/share/man/man7",
+	"/usr/share/man/man8",
+	"/usr/share/misc",
+	"/usr/share/pixmaps",
+	"/usr/src",
+	"/var",
+	"/var/cache",
+	"/var/empty",
+	"/var/games",
+	"/var/lib",
+	"/var/lib/misc",
+	"/var/local",
+	"/var/lock",
+	"/var/log",
+	"/var/log/old",
+	"/var/mail",
+	"/var/opt",
+	"/var/run",
+	"/var/spool",
+	"/var/spool/mail",
+	"/var/tmp",
+}
+
+// opkg files base-files | while read -r f; do test -d "$f" && echo $f; done
+var openwrtPaths = []string{
+	"/bin",
+	"/dev",
+	"/etc",
+	"/etc/config",
+	"/etc/crontabs",
+	"/etc/hotplug.d",
+	"/etc/init.d",
+	"/etc/modules-boot.d",
+	"/etc/modules.d",
+	"/etc/profile.d",
+	"/etc/rc.d",
+	"/etc/sysctl.d",
+	"/etc/uci-defaults",
+	"/lib",
+	"/lib/functions",
+	"/lib/preinit",
+	"/lib/upgrade",
+	"/mnt",
+	"/overlay",
+	"/proc",
+	"/rom",
+	"/root",
+	"/sbin",
+	"/sys",
+	"/tmp",
+	"/usr",
+	"/usr/bin",
+	"/usr/lib",
+	"/usr/libexec",
+	"/usr/sbin",
+	"/usr/share",
+	"/usr/share/libubox",
+	"/var",
+	"/www",
+}
@@ -0,0 +1,71 @@
+package files
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// sourceInodes remembers the regular source files added to a package to find
+// other sources that are hard links to them. Candidates are grouped by size
+// and modification time so that only few of them need to be compared.
+type sourceInodes map[sourceInodeKey][]sourceInode
+
+type sourceInodeKey struct {
+	size  int64
+	mtime int64
+}
+
+type sourceInode struct {
+	source      string
+	info        fs.FileInfo
+	destination string
+}
+
+// hardlinkTarget returns the destination of a previously added source that is
+// a hard link to the given source, otherwise the source is remembered with
+// its destination. The same source added twice is not considered a hard link.
+func (s sourceInodes) hardlinkTarget(source string, info fs.FileInfo, destination string) (string, bool) {
+	if !info.Mode().IsRegular() {
+		return "", false
+	}
+
+	key := sourceInodeKey{size: info.Size(), mtime: info.ModTime().UnixNano()}
+	for _, candidate := range s[key] {
+		if candidate.source != source && os.SameFile(candidate.info, info) {
+			return candidate.destination, true
+		}
+	}
+
+	s[key] = append(s[key], sourceInode{
+		source:      source,
+		info:        info,
+		destination: destination,
+	})
+	return "", false
+}
+
+// resolveHardlinks makes sure that all hard links point to a regular file in
+// the package and gives them the file information of it, as they share it
+// once installed.
+func resolveHardlinks(all map[string]*Content) error {
+	for _, c := range all {
+		if c.Type != TypeHardlink {
+			continue
+		}
+
+		target, ok := all[c.Source]
+		for i := 0; ok && target.Type == TypeHardlink && i < len(all); i++ {
+			target, ok = all[target.Source]
+		}
+		if !ok || target.Type != TypeFile {
+			return fmt.Errorf("hardlink %s: target %s is not a regular file in the package", c.Destination, c.Source)
+		}
+
+		fileInfo := *target.FileInfo
+		c.Source = target.Destination
+		c.FileInfo = &fileInfo
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package files
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cpuguy83/go-md2man/v2/md2man"
+)
+
+// DefaultManDir is the directory man pages are installed to if no destination
+// is given.
+const DefaultManDir = "/usr/share/man"
+
+// addManPages adds the globbed man pages below the man directory given as
+// destination of the original content. Markdown man pages are rendered to
+// roff, and all of them are compressed.
+func addManPages(
+	all map[string]*Content,
+	globbed map[string]string,
+	origFile *Content,
+	umask fs.FileMode,
+	mtime time.Time,
+) error {
+	manDir := origFile.Destination
+	if manDir == "" {
+		manDir = DefaultManDir
+	}
+
+	for src := range globbed {
+		name, section, err := manPageSection(src)
+		if err != nil {
+			return err
+		}
+
+		dst := NormalizeAbsoluteFilePath(path.Join(manDir, "man"+section[:1], name+".gz"))
+		if presentContent, destinationOccupied := all[dst]; destinationOccupied {
+			c := *origFile
+			c.Destination = dst
+			return contentCollisionError(&c, presentContent)
+		}
+
+		data, err := compressedManPage(src)
+		if err != nil {
+			return err
+		}
+
+		if err := addParents(all, dst, mtime); err != nil {
+			return err
+		}
+
+		// if the file has a FileInfo, we need to copy it but recalculate its size
+		newFileInfo := origFile.FileInfo
+		if newFileInfo != nil {
+			newFileInfoVal := *newFileInfo
+			newFileInfoVal.Size = 0
+			newFileInfo = &newFileInfoVal
+		}
+
+		all[dst] = (&Content{
+			Destination: dst,
+			Source:      ToNixPath(src),
+			Type:        TypeFile,
+			FileInfo:    newFileInfo,
+			Packager:    origFile.Packager,
+			Data:        data,
+		}).WithFileInfoDefaults(umask, mtime)
+	}
+
+	return nil
+}
+
+// manPageSection returns the name of the man page without a markdown
+// extension, e.g. nfpm.1 for nfpm.1.md, and its section, e.g. 1 or 3ssl.
+func manPageSection(src string) (name, section string, err error) {
+	name = strings.TrimSuffix(filepath.Base(src), ".md")
+	section = strings.TrimPrefix(filepath.Ext(name), ".")
+	if section == "" || section[0] < '1' || section[0] > '9' {
+		return "", "", fmt.Errorf("cannot determine man page section of %s: the file name must end with it, e.g. name.1 or name.1.md", src)
+	}
+	return name, section, nil
+}
+
+// compressedManPage reads the man page, converts it from markdown to roff if
+// needed and compresses it. The gzip header does not contain a name or
+// modification time so that the result is reproducible.
+func compressedManPage(src string) ([]byte, error) {
+	page, err := os.ReadFile(src)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(src, ".md") {
+		page = md2man.Render(page)
+	}
+
+	var buf bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := gz.Write(page); err != nil {
+		return nil, fmt.Errorf("compress man page %s: %w", src, err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("compress man page %s: %w", src, err)
+	}
+	return buf.Bytes(), nil
+}
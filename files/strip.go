@@ -0,0 +1,252 @@
+package files
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// stripContents replaces the data of the regular files that should be
+// stripped with their stripped version. Files that are not ELF files are
+// left unchanged.
+func stripContents(all map[string]*Content) error {
+	for _, c := range all {
+		if c.FileInfo == nil || !c.FileInfo.Strip {
+			continue
+		}
+		switch c.Type {
+		case TypeFile, TypeConfig, TypeConfigNoReplace, TypeConfigMissingOK:
+		default:
+			continue
+		}
+
+		data, err := c.ReadData()
+		if err != nil {
+			return fmt.Errorf("strip %s: %w", c.Destination, err)
+		}
+		stripped, err := stripELF(data)
+		if err != nil {
+			return fmt.Errorf("strip %s: %w", c.Destination, err)
+		}
+
+		c.Data = stripped
+		c.FileInfo.Size = int64(len(stripped))
+	}
+
+	return nil
+}
+
+// stripELF removes the symbol tables and debug sections from the given ELF
+// file, much like strip does. The sections needed at runtime stay where they
+// are, while the remaining sections after them are moved to fill the gaps.
+// Data that is not an ELF file is returned unchanged.
+func stripELF(data []byte) ([]byte, error) {
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		// not an ELF file
+		return data, nil //nolint:nilerr
+	}
+	defer f.Close() //nolint:errcheck
+
+	if f.Type != elf.ET_EXEC && f.Type != elf.ET_DYN {
+		// object files need their symbols to be linked
+		return data, nil
+	}
+
+	layout, ok := elfLayouts[f.Class]
+	if !ok {
+		return nil, fmt.Errorf("unsupported ELF class %s", f.Class)
+	}
+	bo := f.ByteOrder
+	shoff := layout.uint(bo, data[layout.shoff:])
+	shnum := int(bo.Uint16(data[layout.shnum:]))
+	shstrndx := int(bo.Uint16(data[layout.shstrndx:]))
+	if shnum == 0 || shnum != len(f.Sections) || shstrndx >= shnum {
+		// more sections than fit into the header or no sections at all
+		return data, nil
+	}
+
+	removed := make([]bool, shnum)
+	for i, s := range f.Sections {
+		removed[i] = strippable(s)
+	}
+	// string tables of removed symbol tables, and relocations of removed
+	// sections
+	for i, s := range f.Sections {
+		if !removed[i] {
+			continue
+		}
+		if s.Type == elf.SHT_SYMTAB && s.Link != 0 && int(s.Link) != shstrndx &&
+			f.Sections[s.Link].Flags&elf.SHF_ALLOC == 0 {
+			removed[s.Link] = true
+		}
+	}
+	for i, s := range f.Sections {
+		if (s.Type == elf.SHT_REL || s.Type == elf.SHT_RELA) && s.Flags&elf.SHF_ALLOC == 0 &&
+			(int(s.Info) < shnum && removed[s.Info] || int(s.Link) < shnum && removed[s.Link]) {
+			removed[i] = true
+		}
+	}
+	// make sure that no kept section refers to a removed string table
+	for i, s := range f.Sections {
+		if !removed[i] && s.Link != 0 && int(s.Link) < shnum && removed[s.Link] &&
+			f.Sections[s.Link].Type == elf.SHT_STRTAB {
+			removed[s.Link] = false
+		}
+	}
+
+	indices := make([]int, shnum)
+	kept := 0
+	for i := range f.Sections {
+		if removed[i] {
+			indices[i] = 0
+			continue
+		}
+		indices[i] = kept
+		kept++
+	}
+	if kept == shnum {
+		return data, nil
+	}
+
+	// everything up to the end of the segments and allocated sections stays
+	// in place
+	end := uint64(layout.ehsize)
+	for _, p := range f.Progs {
+		end = max(end, p.Off+p.Filesz)
+	}
+	for _, s := range f.Sections {
+		if s.Flags&elf.SHF_ALLOC != 0 && s.Type != elf.SHT_NOBITS {
+			end = max(end, s.Offset+s.FileSize)
+		}
+	}
+	if end > uint64(len(data)) {
+		return nil, fmt.Errorf("truncated ELF file")
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:end])
+
+	headers := make([]byte, 0, kept*layout.shentsize)
+	for i, s := range f.Sections {
+		if removed[i] {
+			continue
+		}
+
+		start := shoff + uint64(i*layout.shentsize)
+		if start+uint64(layout.shentsize) > uint64(len(data)) {
+			return nil, fmt.Errorf("truncated ELF section headers")
+		}
+		header := append([]byte{}, data[start:start+uint64(layout.shentsize)]...)
+
+		offset := s.Offset
+		if i != 0 && s.Type != elf.SHT_NOBITS && s.Flags&elf.SHF_ALLOC == 0 && s.Offset+s.FileSize > end {
+			if s.Offset+s.FileSize > uint64(len(data)) {
+				return nil, fmt.Errorf("truncated ELF section %s", s.Name)
+			}
+			pad(out, s.Addralign)
+			offset = uint64(out.Len())
+			out.Write(data[s.Offset : s.Offset+s.FileSize])
+		}
+		layout.putUint(bo, header[layout.shOffset:], offset)
+
+		if s.Link != 0 && int(s.Link) < shnum {
+			bo.PutUint32(header[layout.shLink:], uint32(indices[s.Link]))
+		}
+		if (s.Type == elf.SHT_REL || s.Type == elf.SHT_RELA || s.Flags&elf.SHF_INFO_LINK != 0) &&
+			s.Info != 0 && int(s.Info) < shnum {
+			bo.PutUint32(header[layout.shInfo:], uint32(indices[s.Info]))
+		}
+		headers = append(headers, header...)
+	}
+
+	pad(out, uint64(layout.align))
+	newShoff := uint64(out.Len())
+	out.Write(headers)
+
+	result := out.Bytes()
+	layout.putUint(bo, result[layout.shoff:], newShoff)
+	bo.PutUint16(result[layout.shnum:], uint16(kept))
+	bo.PutUint16(result[layout.shstrndx:], uint16(indices[shstrndx]))
+	return result, nil
+}
+
+// strippable reports whether the section is a symbol table or holds debug
+// information, which are not needed at runtime.
+func strippable(s *elf.Section) bool {
+	if s.Flags&elf.SHF_ALLOC != 0 {
+		return false
+	}
+	return s.Type == elf.SHT_SYMTAB ||
+		strings.HasPrefix(s.Name, ".debug_") ||
+		strings.HasPrefix(s.Name, ".zdebug_")
+}
+
+func pad(out *bytes.Buffer, align uint64) {
+	if align <= 1 {
+		return
+	}
+	for uint64(out.Len())%align != 0 {
+		out.WriteByte(0)
+	}
+}
+
+// elfLayout holds the offsets of the fields of the ELF and section headers
+// that are needed to rewrite the section header table.
+type elfLayout struct {
+	ehsize    int
+	shentsize int
+	align     int
+	shoff     int
+	shnum     int
+	shstrndx  int
+	shOffset  int
+	shLink    int
+	shInfo    int
+	wordSize  int
+}
+
+func (l elfLayout) uint(bo binary.ByteOrder, b []byte) uint64 {
+	if l.wordSize == 4 {
+		return uint64(bo.Uint32(b))
+	}
+	return bo.Uint64(b)
+}
+
+func (l elfLayout) putUint(bo binary.ByteOrder, b []byte, v uint64) {
+	if l.wordSize == 4 {
+		bo.PutUint32(b, uint32(v))
+		return
+	}
+	bo.PutUint64(b, v)
+}
+
+// nolint: gochecknoglobals
+var elfLayouts = map[elf.Class]elfLayout{
+	elf.ELFCLASS32: {
+		ehsize:    52,
+		shentsize: 40,
+		align:     4,
+		shoff:     0x20,
+		shnum:     0x30,
+		shstrndx:  0x32,
+		shOffset:  16,
+		shLink:    24,
+		shInfo:    28,
+		wordSize:  4,
+	},
+	elf.ELFCLASS64: {
+		ehsize:    64,
+		shentsize: 64,
+		align:     8,
+		shoff:     0x28,
+		shnum:     0x3c,
+		shstrndx:  0x3e,
+		shOffset:  24,
+		shLink:    40,
+		shInfo:    44,
+		wordSize:  8,
+	},
+}
@@ -7,20 +7,30 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/deprecation"
+	"github.com/goreleaser/nfpm/v2/internal/manifest"
+	"github.com/goreleaser/nfpm/v2/internal/provenance"
 	"github.com/spf13/cobra"
 )
 
 type packageCmd struct {
-	cmd      *cobra.Command
-	config   string
-	target   string
-	packager string
+	cmd        *cobra.Command
+	config     string
+	target     string
+	packagers  []string
+	manifest   string
+	checksums  bool
+	provenance bool
+	strict     bool
+	sets       []string
+	shortcuts  map[string]*string
 }
 
-func newPackageCmd() *packageCmd {
-	root := &packageCmd{}
+func newPackageCmd(version string) *packageCmd {
+	root := &packageCmd{shortcuts: map[string]*string{}}
 	cmd := &cobra.Command{
 		Use:               "package",
 		Aliases:           []string{"pkg", "p"},
@@ -29,8 +39,22 @@ func newPackageCmd() *packageCmd {
 		SilenceErrors:     true,
 		Args:              cobra.NoArgs,
 		ValidArgsFunction: cobra.NoFileCompletions,
-		RunE: func(*cobra.Command, []string) error {
-			return doPackage(root.config, root.target, root.packager)
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			// the shortcuts are applied first, so --set takes precedence
+			var sets []string
+			for _, name := range []string{"name", "version", "release", "arch"} {
+				if cmd.Flags().Changed(name) {
+					sets = append(sets, name+"="+*root.shortcuts[name])
+				}
+			}
+			return doPackage(root.config, root.target, root.packagers, packageOptions{
+				manifest:   root.manifest,
+				checksums:  root.checksums,
+				provenance: root.provenance,
+				strict:     root.strict,
+				sets:       append(sets, root.sets...),
+				version:    version,
+			})
 		},
 	}
 
@@ -41,33 +65,64 @@ func newPackageCmd() *packageCmd {
 
 	pkgs := nfpm.Enumerate()
 
-	cmd.Flags().StringVarP(&root.packager, "packager", "p", "",
-		fmt.Sprintf("which packager implementation to use [%s]", strings.Join(pkgs, "|")))
+	cmd.Flags().StringSliceVarP(&root.packagers, "packager", "p", nil,
+		fmt.Sprintf("which packager implementations to use, separated by commas [%s]", strings.Join(pkgs, "|")))
 	_ = cmd.RegisterFlagCompletionFunc("packager", cobra.FixedCompletions(pkgs,
 		cobra.ShellCompDirectiveNoFileComp,
 	))
 
+	cmd.Flags().StringVar(&root.manifest, "manifest", "", "where to save a JSON manifest describing the generated package")
+	_ = cmd.MarkFlagFilename("manifest", "json")
+	cmd.Flags().BoolVar(&root.checksums, "checksums", false, "write sha256sum and sha512sum compatible files next to the generated package")
+	cmd.Flags().BoolVar(&root.provenance, "provenance", false, "write a signed in-toto SLSA provenance next to the generated package")
+	cmd.Flags().BoolVar(&root.strict, "strict", false, "fail if there are deprecation or unsupported field warnings")
+	cmd.Flags().StringArrayVar(&root.sets, "set", nil, "set a config field, e.g. deb.compression=zstd or overrides.rpm.depends[0]=foo (can be repeated)")
+	for _, name := range []string{"name", "version", "release", "arch"} {
+		root.shortcuts[name] = cmd.Flags().String(name, "", fmt.Sprintf("set the package %s, same as --set %s=<value>", name, name))
+	}
+
 	root.cmd = cmd
 	return root
 }
 
-var errInsufficientParams = errors.New("a packager must be specified if target is a directory or blank")
+var (
+	errInsufficientParams = errors.New("a packager must be specified if target is a directory or blank")
+	errStrict             = errors.New("warnings are not allowed with --strict")
+	errMultipleTargets    = errors.New("target must be a directory or blank when creating several packages")
+)
+
+type packageOptions struct {
+	manifest   string
+	checksums  bool
+	provenance bool
+	strict     bool
+	sets       []string
+	version    string
+}
 
 // nolint:funlen
-func doPackage(configPath, target, packager string) error {
+func doPackage(configPath, target string, packagers []string, opts packageOptions) error {
+	warnings := &deprecation.Collector{}
+	warnings.Next = deprecation.SetSink(warnings)
+	defer deprecation.SetSink(warnings.Next)
+
 	targetIsADirectory := false
 	stat, err := os.Stat(target)
 	if err == nil && stat.IsDir() {
 		targetIsADirectory = true
 	}
 
-	if packager == "" {
+	if len(packagers) == 0 {
 		ext := filepath.Ext(target)
 		if targetIsADirectory || ext == "" {
 			return errInsufficientParams
 		}
 
-		packager = ext[1:]
+		packager := ext[1:]
+		if strings.HasSuffix(target, ".src.rpm") {
+			packager = "srpm"
+		}
+		packagers = []string{packager}
 		fmt.Println("guessing packager from target file extension...")
 	}
 
@@ -76,42 +131,217 @@ func doPackage(configPath, target, packager string) error {
 		return err
 	}
 
-	info, err := config.Get(packager)
+	for _, set := range opts.sets {
+		key, value, ok := strings.Cut(set, "=")
+		if !ok {
+			return fmt.Errorf("invalid --set %q, expected key=value", set)
+		}
+		if err := config.Set(key, value); err != nil {
+			return err
+		}
+		if key == "arch" {
+			// an explicitly set arch builds only that one
+			config.Arches = nil
+		}
+	}
+
+	arches := config.Arches
+	if len(arches) == 0 {
+		arches = []string{""}
+	}
+	if len(packagers)*len(arches) > 1 && target != "" && !targetIsADirectory {
+		return errMultipleTargets
+	}
+
+	build := packageBuild{
+		config:             &config,
+		configPath:         configPath,
+		target:             target,
+		targetIsADirectory: targetIsADirectory,
+		warnings:           warnings,
+		opts:               opts,
+	}
+	var artifacts []manifest.Artifact
+	for _, packager := range packagers {
+		for _, arch := range arches {
+			artifact, err := build.create(packager, arch)
+			if err != nil {
+				return err
+			}
+			artifacts = append(artifacts, artifact...)
+		}
+	}
+
+	if opts.manifest != "" {
+		m := manifest.Manifest{Artifacts: artifacts}
+		if err := m.Write(opts.manifest); err != nil {
+			return err
+		}
+		fmt.Printf("created manifest: %s\n", opts.manifest)
+	}
+	return nil
+}
+
+// packageBuild creates the packages of a config for each packager and arch.
+type packageBuild struct {
+	config             *nfpm.Config
+	configPath         string
+	target             string
+	targetIsADirectory bool
+	warnings           *deprecation.Collector
+	opts               packageOptions
+}
+
+// create creates the package for the packager and arch, and returns its
+// manifest artifact if one is needed.
+// nolint:funlen
+func (b packageBuild) create(packager, arch string) ([]manifest.Artifact, error) {
+	startedOn := time.Now()
+	info, err := b.config.GetForArch(packager, arch)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	info = nfpm.WithDefaults(info)
 
-	fmt.Printf("using %s packager...\n", packager)
+	if arch == "" {
+		fmt.Printf("using %s packager...\n", packager)
+	} else {
+		fmt.Printf("using %s packager for %s...\n", packager, arch)
+	}
 	pkg, err := nfpm.Get(packager)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	target := b.target
 	if target == "" {
 		// if no target was specified create a package in
 		// current directory with a conventional file name
 		target = pkg.ConventionalFileName(info)
-	} else if targetIsADirectory {
+	} else if b.targetIsADirectory {
 		// if a directory was specified as target, create
 		// a package with conventional file name there
 		target = path.Join(target, pkg.ConventionalFileName(info))
 	}
 
+	if b.opts.provenance {
+		// fail before a package without provenance is written
+		if err := provenance.CheckSigner(packager, info); err != nil {
+			return nil, fmt.Errorf("failed to sign provenance: %w", err)
+		}
+	}
+
 	f, err := os.Create(target)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer f.Close()
 
 	info.Target = target
+	// packagers may replace the contents with the prepared ones
+	sources := *info
 
 	if err := pkg.Package(info, f); err != nil {
 		os.Remove(target)
-		return err
+		return nil, err
 	}
 
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	if n := len(b.warnings.Warnings()); b.opts.strict && n > 0 {
+		os.Remove(target)
+		return nil, fmt.Errorf("%w: %d warning(s)", errStrict, n)
+	}
 	fmt.Printf("created package: %s\n", target)
-	return f.Close()
+
+	if err := writeDetachedSignature(pkg, info, target); err != nil {
+		return nil, err
+	}
+
+	if b.opts.provenance {
+		build := provenance.Build{
+			Parameters: provenance.Parameters{
+				Config:   b.configPath,
+				Packager: packager,
+				Arch:     arch,
+				Target:   target,
+				Sets:     b.opts.sets,
+			},
+			Version:    b.opts.version,
+			StartedOn:  startedOn,
+			FinishedOn: time.Now(),
+		}
+		if err := writeProvenance(build, &sources, info); err != nil {
+			os.Remove(target)
+			os.Remove(target + ".sig")
+			return nil, err
+		}
+	}
+
+	return writeArtifactInfo(pkg, packager, target, info, b.opts)
+}
+
+func writeProvenance(build provenance.Build, sources, info *nfpm.Info) error {
+	statement, err := provenance.New(build, sources)
+	if err != nil {
+		return fmt.Errorf("failed to create provenance: %w", err)
+	}
+	envelope, err := provenance.Sign(statement, build.Packager, info)
+	if err != nil {
+		return fmt.Errorf("failed to sign provenance: %w", err)
+	}
+	path := build.Target + provenance.Extension
+	if err := envelope.Write(path); err != nil {
+		return err
+	}
+	fmt.Printf("created provenance: %s\n", path)
+	return nil
+}
+
+// writeArtifactInfo writes the checksums of the package and the files written
+// next to it if requested, and returns their artifacts if a manifest is
+// requested.
+func writeArtifactInfo(pkg nfpm.Packager, packager, target string, info *nfpm.Info, opts packageOptions) ([]manifest.Artifact, error) {
+	if opts.manifest == "" && !opts.checksums {
+		return nil, nil
+	}
+
+	paths := []string{target}
+	if writer, ok := pkg.(nfpm.AdditionalFilesWriter); ok {
+		additional, err := writer.AdditionalFiles(info)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, additional...)
+	}
+
+	artifacts := make([]manifest.Artifact, 0, len(paths))
+	for i, path := range paths {
+		artifact, err := manifest.NewArtifact(packager, path, info)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			// only the package itself is signed
+			artifact.Signature = nil
+		}
+
+		if opts.checksums {
+			checksums, err := artifact.WriteChecksums()
+			if err != nil {
+				return nil, err
+			}
+			for _, checksum := range checksums {
+				fmt.Printf("created checksum: %s\n", checksum)
+			}
+		}
+		artifacts = append(artifacts, artifact)
+	}
+
+	if opts.manifest == "" {
+		return nil, nil
+	}
+	return artifacts, nil
 }
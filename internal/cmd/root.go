@@ -48,7 +48,8 @@ func newRootCmd(version goversion.Info, exit func(int)) *rootCmd {
 
 	cmd.AddCommand(
 		newInitCmd().cmd,
-		newPackageCmd().cmd,
+		newPackageCmd(version.GitVersion).cmd,
+		newSignCmd().cmd,
 		newDocsCmd().cmd,
 		newManCmd().cmd,
 		newSchemaCmd().cmd,
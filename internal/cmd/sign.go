@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/spf13/cobra"
+)
+
+type signCmd struct {
+	cmd      *cobra.Command
+	config   string
+	target   string
+	packager string
+}
+
+func newSignCmd() *signCmd {
+	root := &signCmd{}
+	cmd := &cobra.Command{
+		Use:           "sign <package>",
+		Short:         "Signs or re-signs an existing package based on the given config file and flags",
+		Long:          "Adds or replaces the signature of an existing package using the signature settings of the given config file. Packages that keep their signature next to them, like archlinux and ipk packages, get a detached <package>.sig signature.",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return doSign(root.config, args[0], root.target, root.packager)
+		},
+	}
+
+	cmd.Flags().StringVarP(&root.config, "config", "f", "nfpm.yaml", "config file to be used")
+	_ = cmd.MarkFlagFilename("config", "yaml", "yml")
+	cmd.Flags().StringVarP(&root.target, "target", "t", "", "where to save the signed package or the detached signature (defaults to replacing the package or <package>.sig)")
+	_ = cmd.MarkFlagFilename("target")
+
+	pkgs := nfpm.Enumerate()
+
+	cmd.Flags().StringVarP(&root.packager, "packager", "p", "",
+		fmt.Sprintf("which packager implementation to use [%s]", strings.Join(pkgs, "|")))
+	_ = cmd.RegisterFlagCompletionFunc("packager", cobra.FixedCompletions(pkgs,
+		cobra.ShellCompDirectiveNoFileComp,
+	))
+
+	root.cmd = cmd
+	return root
+}
+
+func doSign(configPath, pkgPath, target, packager string) error {
+	if packager == "" {
+		packager = packagerFromFileName(pkgPath)
+		if packager == "" {
+			return fmt.Errorf("cannot guess the packager of %s, please specify one", pkgPath)
+		}
+		fmt.Println("guessing packager from package file extension...")
+	}
+
+	config, err := nfpm.ParseFile(configPath)
+	if err != nil {
+		return err
+	}
+
+	info, err := config.Get(packager)
+	if err != nil {
+		return err
+	}
+
+	info = nfpm.WithDefaults(info)
+
+	fmt.Printf("using %s packager...\n", packager)
+	pkg, err := nfpm.Get(packager)
+	if err != nil {
+		return err
+	}
+
+	stat, err := os.Stat(pkgPath)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(pkgPath)
+	if err != nil {
+		return err
+	}
+
+	var signed bytes.Buffer
+	switch signer := pkg.(type) {
+	case nfpm.Signer:
+		if target == "" {
+			target = pkgPath
+		}
+		if err := signer.Sign(info, bytes.NewReader(data), &signed); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, signed.Bytes(), stat.Mode().Perm()); err != nil {
+			return err
+		}
+		fmt.Printf("signed package: %s\n", target)
+	case nfpm.DetachedSigner:
+		if target == "" {
+			target = pkgPath + ".sig"
+		}
+		if err := signer.SignDetached(info, bytes.NewReader(data), &signed); err != nil {
+			return err
+		}
+		if err := os.WriteFile(target, signed.Bytes(), 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("created signature: %s\n", target)
+	default:
+		return fmt.Errorf("%s packages cannot be signed", packager)
+	}
+	return nil
+}
+
+// packagerFromFileName returns the packager whose conventional extension is
+// the longest suffix of the given file name.
+func packagerFromFileName(name string) string {
+	packager, ext := "", ""
+	for _, format := range nfpm.Enumerate() {
+		pkg, err := nfpm.Get(format)
+		if err != nil {
+			continue
+		}
+		withExt, ok := pkg.(nfpm.PackagerWithExtension)
+		if !ok {
+			continue
+		}
+		if e := withExt.ConventionalExtension(); strings.HasSuffix(name, e) && len(e) > len(ext) {
+			packager, ext = format, e
+		}
+	}
+	return packager
+}
+
+// writeDetachedSignature writes the detached signature of packagers that keep
+// signatures next to the package, if a signing key is configured.
+func writeDetachedSignature(pkg nfpm.Packager, info *nfpm.Info, target string) error {
+	signer, ok := pkg.(nfpm.DetachedSigner)
+	if !ok {
+		return nil
+	}
+
+	f, err := os.Open(target)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var sig bytes.Buffer
+	if err := signer.SignDetached(info, f, &sig); err != nil {
+		if errors.Is(err, nfpm.ErrNoSigningKey) {
+			return nil
+		}
+		return err
+	}
+	if err := os.WriteFile(target+".sig", sig.Bytes(), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("created signature: %s.sig\n", target)
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -55,7 +56,9 @@ func (e ErrGlobNoMatch) Error() string {
 // Glob returns a map with source file path as keys and destination as values.
 // First the longest common prefix (lcp) of all globbed files is found. The destination
 // for each globbed file is then dst joined with src with the lcp trimmed off.
-func Glob(pattern, dst string, ignoreMatchers bool) (map[string]string, error) {
+// Files whose path relative to the lcp matches one of the exclude patterns
+// are left out, see Excluded.
+func Glob(pattern, dst string, ignoreMatchers bool, exclude ...string) (map[string]string, error) {
 	options := []fileglob.OptFunc{fileglob.MatchDirectoryIncludesContents}
 	if ignoreMatchers {
 		options = append(options, fileglob.QuoteMeta)
@@ -95,20 +98,84 @@ func Glob(pattern, dst string, ignoreMatchers bool) (map[string]string, error) {
 			continue
 		}
 
-		if strings.HasSuffix(dst, "/") {
-			files[src] = filepath.Join(dst, filepath.Base(src))
-			continue
-		}
-
 		relpath, err := filepath.Rel(prefix, src)
 		if err != nil {
 			// since prefix is a prefix of src a relative path should always be found
 			return nil, err
 		}
 
+		excluded, err := Excluded(filepath.ToSlash(relpath), exclude)
+		if err != nil {
+			return nil, err
+		}
+		if excluded {
+			continue
+		}
+
+		if strings.HasSuffix(dst, "/") {
+			files[src] = filepath.Join(dst, filepath.Base(src))
+			continue
+		}
+
 		globdst := filepath.ToSlash(filepath.Join(dst, relpath))
 		files[src] = globdst
 	}
 
+	if len(files) == 0 && len(exclude) > 0 {
+		return nil, ErrGlobNoMatch{pattern}
+	}
+
 	return files, nil
 }
+
+// Excluded reports whether the slash separated relative path matches one of
+// the exclude patterns. Within a path segment, patterns support the syntax of
+// path.Match, and a `**` segment matches any number of segments. Patterns
+// without a slash match the name of a file or directory at any depth, and
+// excluding a directory also excludes everything below it.
+func Excluded(relpath string, patterns []string) (bool, error) {
+	segments := strings.Split(path.Clean(relpath), "/")
+	for _, pattern := range patterns {
+		normalized := strings.Trim(path.Clean(filepath.ToSlash(pattern)), "/")
+		if !strings.Contains(normalized, "/") {
+			normalized = "**/" + normalized
+		}
+		patternSegments := strings.Split(normalized, "/")
+
+		for i := 1; i <= len(segments); i++ {
+			matched, err := matchSegments(patternSegments, segments[:i])
+			if err != nil {
+				return false, fmt.Errorf("invalid exclude pattern %q: %w", pattern, err)
+			}
+			if matched {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func matchSegments(patterns, segments []string) (bool, error) {
+	if len(patterns) == 0 {
+		return len(segments) == 0, nil
+	}
+
+	if patterns[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			matched, err := matchSegments(patterns[1:], segments[i:])
+			if err != nil || matched {
+				return matched, err
+			}
+		}
+		return false, nil
+	}
+
+	if len(segments) == 0 {
+		return false, nil
+	}
+	matched, err := path.Match(patterns[0], segments[0])
+	if err != nil || !matched {
+		return false, err
+	}
+	return matchSegments(patterns[1:], segments[1:])
+}
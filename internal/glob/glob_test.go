@@ -97,6 +97,19 @@ func TestGlob(t *testing.T) {
 		require.Equal(t, "/foo/bar/test_brace.txt", files["testdata/{dir_d}/test_brace.txt"])
 	})
 
+	t.Run("exclude", func(t *testing.T) {
+		files, err := Glob("testdata/**/*.txt", "/foo/bar", false, "dir_c", "{dir_d}/*.txt")
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		require.Equal(t, "/foo/bar/dir_a/dir_b/test_b.txt", files["testdata/dir_a/dir_b/test_b.txt"])
+	})
+
+	t.Run("everything excluded", func(t *testing.T) {
+		files, err := Glob("testdata/dir_a/*", "/foo/bar", false, "*.txt")
+		require.Nil(t, files)
+		require.EqualError(t, err, "glob failed: testdata/dir_a/*: no matching files")
+	})
+
 	t.Run("no glob", func(t *testing.T) {
 		files, err := Glob("testdata/dir_a/dir_b/test_b.txt", "/foo/bar/dest.dat", false)
 		require.NoError(t, err)
@@ -104,3 +117,31 @@ func TestGlob(t *testing.T) {
 		require.Equal(t, "/foo/bar/dest.dat", files["testdata/dir_a/dir_b/test_b.txt"])
 	})
 }
+
+func TestExcluded(t *testing.T) {
+	for _, tc := range []struct {
+		path     string
+		patterns []string
+		excluded bool
+	}{
+		{"foo.pyc", []string{"**/*.pyc"}, true},
+		{"a/b/foo.pyc", []string{"**/*.pyc"}, true},
+		{"a/b/foo.py", []string{"**/*.pyc"}, false},
+		{"a/__pycache__/foo.cpython-311.pyc", []string{"**/__pycache__"}, true},
+		{".git/config", []string{".git"}, true},
+		{"src/.git/HEAD", []string{".git"}, true},
+		{"src/.gitignore", []string{".git"}, false},
+		{"test/fixtures/a.json", []string{"test/fixtures"}, true},
+		{"src/test/fixtures/a.json", []string{"test/fixtures"}, false},
+		{"a/b/c", []string{"a/**/c"}, true},
+		{"a/c", []string{"a/**/c"}, true},
+		{"a/b/c", nil, false},
+	} {
+		excluded, err := Excluded(tc.path, tc.patterns)
+		require.NoError(t, err)
+		require.Equal(t, tc.excluded, excluded, "%s %v", tc.path, tc.patterns)
+	}
+
+	_, err := Excluded("foo", []string{"[foo"})
+	require.EqualError(t, err, `invalid exclude pattern "[foo": syntax error in pattern`)
+}
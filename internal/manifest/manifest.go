@@ -0,0 +1,217 @@
+// Package manifest describes the artifacts created by nfpm in a machine
+// readable way.
+package manifest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/internal/sign"
+)
+
+// Manifest lists all artifacts created in a single nfpm run.
+type Manifest struct {
+	Artifacts []Artifact `json:"artifacts"`
+}
+
+// Artifact describes a single package created by nfpm.
+type Artifact struct {
+	Packager  string     `json:"packager"`
+	Path      string     `json:"path"`
+	Size      int64      `json:"size"`
+	SHA256    string     `json:"sha256"`
+	SHA512    string     `json:"sha512"`
+	Info      Info       `json:"info"`
+	Signature *Signature `json:"signature,omitempty"`
+}
+
+// Info holds the package metadata used to create an artifact.
+type Info struct {
+	Name            string `json:"name"`
+	Arch            string `json:"arch"`
+	Platform        string `json:"platform,omitempty"`
+	Epoch           string `json:"epoch,omitempty"`
+	Version         string `json:"version"`
+	Release         string `json:"release,omitempty"`
+	Prerelease      string `json:"prerelease,omitempty"`
+	VersionMetadata string `json:"version_metadata,omitempty"`
+	Section         string `json:"section,omitempty"`
+	Priority        string `json:"priority,omitempty"`
+	Maintainer      string `json:"maintainer,omitempty"`
+	Vendor          string `json:"vendor,omitempty"`
+	Homepage        string `json:"homepage,omitempty"`
+	License         string `json:"license,omitempty"`
+}
+
+// Signature describes the key an artifact was signed with.
+type Signature struct {
+	Type    string `json:"type"`
+	KeyID   string `json:"key_id,omitempty"`
+	KeyName string `json:"key_name,omitempty"`
+}
+
+// NewArtifact hashes the package at the given path and describes it.
+func NewArtifact(packager, path string, info *nfpm.Info) (Artifact, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Artifact{}, err
+	}
+	defer f.Close()
+
+	sha256Hash := sha256.New()
+	sha512Hash := sha512.New()
+	size, err := io.Copy(io.MultiWriter(sha256Hash, sha512Hash), f)
+	if err != nil {
+		return Artifact{}, fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+
+	signature, err := signatureOf(packager, info)
+	if err != nil {
+		return Artifact{}, err
+	}
+
+	return Artifact{
+		Packager: packager,
+		Path:     path,
+		Size:     size,
+		SHA256:   hex.EncodeToString(sha256Hash.Sum(nil)),
+		SHA512:   hex.EncodeToString(sha512Hash.Sum(nil)),
+		Info: Info{
+			Name:            info.Name,
+			Arch:            info.Arch,
+			Platform:        info.Platform,
+			Epoch:           info.Epoch,
+			Version:         info.Version,
+			Release:         info.Release,
+			Prerelease:      info.Prerelease,
+			VersionMetadata: info.VersionMetadata,
+			Section:         info.Section,
+			Priority:        info.Priority,
+			Maintainer:      info.Maintainer,
+			Vendor:          info.Vendor,
+			Homepage:        info.Homepage,
+			License:         info.License,
+		},
+		Signature: signature,
+	}, nil
+}
+
+// WriteChecksums writes sha256sum and sha512sum compatible files next to
+// the artifact, named after it with a .sha256 and .sha512 suffix.
+func (a Artifact) WriteChecksums() ([]string, error) {
+	sums := []struct {
+		ext, sum string
+	}{
+		{".sha256", a.SHA256},
+		{".sha512", a.SHA512},
+	}
+	paths := make([]string, 0, len(sums))
+	for _, s := range sums {
+		path := a.Path + s.ext
+		content := fmt.Sprintf("%s  %s\n", s.sum, filepath.Base(a.Path))
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write checksum file: %w", err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// Write writes the manifest as indented JSON to the given path.
+func (m Manifest) Write(path string) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+func signatureOf(packager string, info *nfpm.Info) (*Signature, error) {
+	// the packages are signed with the main key, or the first additional one
+	// if there is none
+	var sig nfpm.PackageSignature
+	switch packager {
+	case "deb", "dsc":
+		if !info.Deb.Signature.IsSet() {
+			return nil, nil
+		}
+		sig = info.Deb.Signature.PackageSignature
+		if !sig.IsSet() {
+			sig = info.Deb.Signature.Keys[0].PackageSignature
+		}
+	case "rpm", "srpm":
+		if !info.RPM.Signature.IsSet() {
+			return nil, nil
+		}
+		sig = info.RPM.Signature.PackageSignature
+		if !sig.IsSet() {
+			sig = info.RPM.Signature.Keys[0]
+		}
+	case "archlinux":
+		sig = info.ArchLinux.Signature
+	case "ipk":
+		sig = info.IPK.Signature
+	case "apk":
+		if !info.APK.Signature.IsSet() {
+			return nil, nil
+		}
+		keyName := info.APK.Signature.KeyName
+		if !info.APK.Signature.PackageSignature.IsSet() {
+			keyName = info.APK.Signature.Keys[0].KeyName
+		}
+		return &Signature{
+			Type:    "rsa",
+			KeyName: apkKeyName(info, keyName),
+		}, nil
+	default:
+		return nil, nil
+	}
+	if !sig.IsSet() {
+		return nil, nil
+	}
+
+	// signing commands and PKCS#11 keys may not have a key file to read the
+	// key id from
+	if sig.KeyFile == "" && sig.KeyID == nil {
+		return &Signature{Type: "pgp"}, nil
+	}
+	keyID, err := sign.PGPKeyID(sig.KeyFile, sig.KeyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key id: %w", err)
+	}
+	return &Signature{
+		Type:  "pgp",
+		KeyID: keyID,
+	}, nil
+}
+
+// apkKeyName mirrors the key name the apk packager uses for the signature.
+func apkKeyName(info *nfpm.Info, keyname string) string {
+	if keyname == "" {
+		addr, err := mail.ParseAddress(info.Maintainer)
+		if err != nil {
+			return ""
+		}
+		keyname = addr.Address
+	}
+	if !strings.HasSuffix(keyname, ".rsa.pub") {
+		keyname += ".rsa.pub"
+	}
+	return keyname
+}
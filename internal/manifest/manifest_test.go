@@ -0,0 +1,97 @@
+package manifest
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArtifact(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "foo_1.0.0_amd64.deb")
+	require.NoError(t, os.WriteFile(path, []byte("hello"), 0o600))
+
+	info := &nfpm.Info{
+		Name:    "foo",
+		Arch:    "amd64",
+		Version: "1.0.0",
+	}
+	info.Deb.Signature.KeyFile = "../sign/testdata/privkey.asc"
+
+	artifact, err := NewArtifact("deb", path, info)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), artifact.Size)
+	require.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", artifact.SHA256)
+	require.Equal(t, "9b71d224bd62f3785d96d46ad3ea3d73319bfbc2890caadae2dff72519673ca72323c3d99ba5c11d7c7acc6e14b8c5da0c4663475c2e5c3adef46f73bcdec043", artifact.SHA512)
+	require.Equal(t, "foo", artifact.Info.Name)
+	require.Equal(t, &Signature{Type: "pgp", KeyID: "9890904dfb2ec88a"}, artifact.Signature)
+
+	paths, err := artifact.WriteChecksums()
+	require.NoError(t, err)
+	require.Equal(t, []string{path + ".sha256", path + ".sha512"}, paths)
+	bts, err := os.ReadFile(path + ".sha256")
+	require.NoError(t, err)
+	require.Equal(t, artifact.SHA256+"  foo_1.0.0_amd64.deb\n", string(bts))
+
+	manifestPath := filepath.Join(dir, "manifest.json")
+	require.NoError(t, Manifest{Artifacts: []Artifact{artifact}}.Write(manifestPath))
+	bts, err = os.ReadFile(manifestPath)
+	require.NoError(t, err)
+	var m Manifest
+	require.NoError(t, json.Unmarshal(bts, &m))
+	require.Equal(t, []Artifact{artifact}, m.Artifacts)
+}
+
+func TestArtifactAPKSignature(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "foo.apk")
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+
+	info := &nfpm.Info{Name: "foo", Maintainer: "Foo <foo@example.com>"}
+	info.APK.Signature.KeyFile = "../sign/testdata/rsa.priv"
+
+	artifact, err := NewArtifact("apk", path, info)
+	require.NoError(t, err)
+	require.Equal(t, &Signature{Type: "rsa", KeyName: "foo@example.com.rsa.pub"}, artifact.Signature)
+}
+
+func TestArtifactUnsigned(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "foo.rpm")
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+
+	artifact, err := NewArtifact("rpm", path, &nfpm.Info{Name: "foo"})
+	require.NoError(t, err)
+	require.Nil(t, artifact.Signature)
+}
+
+func TestArtifactAdditionalSignatureKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "foo.rpm")
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+
+	info := &nfpm.Info{Name: "foo"}
+	info.RPM.Signature.Keys = []nfpm.PackageSignature{{KeyFile: "../sign/testdata/privkey.asc"}}
+
+	artifact, err := NewArtifact("rpm", path, info)
+	require.NoError(t, err)
+	require.Equal(t, &Signature{Type: "pgp", KeyID: "9890904dfb2ec88a"}, artifact.Signature)
+}
+
+func TestArtifactSignatureCommand(t *testing.T) {
+	for _, packager := range []string{"archlinux", "ipk"} {
+		t.Run(packager, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "foo")
+			require.NoError(t, os.WriteFile(path, nil, 0o600))
+
+			info := &nfpm.Info{Name: "foo"}
+			info.ArchLinux.Signature.Command = []string{"gpg", "--detach-sign"}
+			info.IPK.Signature.Command = []string{"gpg", "--detach-sign"}
+
+			artifact, err := NewArtifact(packager, path, info)
+			require.NoError(t, err)
+			require.Equal(t, &Signature{Type: "pgp"}, artifact.Signature)
+		})
+	}
+}
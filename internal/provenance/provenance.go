@@ -0,0 +1,340 @@
+// Package provenance creates in-toto statements with a SLSA provenance
+// predicate for the artifacts created by nfpm, and signs them as DSSE
+// envelopes with the signing key of the package.
+package provenance
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/AlekSi/pointer"
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/sign"
+)
+
+const (
+	// StatementType is the type of in-toto v1 statements.
+	StatementType = "https://in-toto.io/Statement/v1"
+	// PredicateType is the type of SLSA v1 provenance predicates.
+	PredicateType = "https://slsa.dev/provenance/v1"
+	// PayloadType is the DSSE payload type of in-toto statements.
+	PayloadType = "application/vnd.in-toto+json"
+	// BuildType describes the external parameters of nfpm builds.
+	BuildType = "https://nfpm.goreleaser.com/provenance/v1"
+	// BuilderID identifies nfpm as the builder.
+	BuilderID = "https://github.com/goreleaser/nfpm"
+	// Extension is appended to the artifact path to name its provenance.
+	Extension = ".intoto.jsonl"
+)
+
+// Statement is an in-toto statement about the artifacts in its subject.
+type Statement struct {
+	Type          string               `json:"_type"`
+	Subject       []ResourceDescriptor `json:"subject"`
+	PredicateType string               `json:"predicateType"`
+	Predicate     Provenance           `json:"predicate"`
+}
+
+// ResourceDescriptor describes an artifact or a source material by its path
+// and digests.
+type ResourceDescriptor struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// Provenance is the SLSA v1 provenance predicate.
+type Provenance struct {
+	BuildDefinition BuildDefinition `json:"buildDefinition"`
+	RunDetails      RunDetails      `json:"runDetails"`
+}
+
+// BuildDefinition holds the inputs of the build.
+type BuildDefinition struct {
+	BuildType            string               `json:"buildType"`
+	ExternalParameters   Parameters           `json:"externalParameters"`
+	ResolvedDependencies []ResourceDescriptor `json:"resolvedDependencies"`
+}
+
+// Parameters are the build parameters nfpm was invoked with.
+type Parameters struct {
+	Config   string   `json:"config"`
+	Packager string   `json:"packager"`
+	Arch     string   `json:"arch,omitempty"`
+	Target   string   `json:"target"`
+	Sets     []string `json:"sets,omitempty"`
+}
+
+// RunDetails describes the builder and the build run.
+type RunDetails struct {
+	Builder  Builder  `json:"builder"`
+	Metadata Metadata `json:"metadata"`
+}
+
+// Builder identifies the nfpm version that created the artifact.
+type Builder struct {
+	ID      string            `json:"id"`
+	Version map[string]string `json:"version"`
+}
+
+// Metadata holds the time the build started and finished.
+type Metadata struct {
+	StartedOn  time.Time `json:"startedOn"`
+	FinishedOn time.Time `json:"finishedOn"`
+}
+
+// Build describes an nfpm run that created an artifact.
+type Build struct {
+	Parameters
+	Version    string
+	StartedOn  time.Time
+	FinishedOn time.Time
+}
+
+// New creates the provenance statement of the artifact at the build target.
+// The config file and the source of every regular file in the contents of
+// the info are recorded as resolved dependencies, so info must be the one
+// the package was created from.
+func New(build Build, info *nfpm.Info) (Statement, error) {
+	subject, err := describe(build.Target, filepath.Base(build.Target))
+	if err != nil {
+		return Statement{}, err
+	}
+
+	var dependencies []ResourceDescriptor
+	// a config read from stdin cannot be hashed afterwards
+	if build.Config != "-" {
+		config, err := describe(build.Config, build.Config)
+		if err != nil {
+			return Statement{}, err
+		}
+		dependencies = append(dependencies, config)
+	}
+
+	sources, err := sourceFiles(build.Packager, info)
+	if err != nil {
+		return Statement{}, err
+	}
+	for _, source := range sources {
+		material, err := describe(source, source)
+		if err != nil {
+			return Statement{}, err
+		}
+		dependencies = append(dependencies, material)
+	}
+
+	return Statement{
+		Type:          StatementType,
+		Subject:       []ResourceDescriptor{subject},
+		PredicateType: PredicateType,
+		Predicate: Provenance{
+			BuildDefinition: BuildDefinition{
+				BuildType:            BuildType,
+				ExternalParameters:   build.Parameters,
+				ResolvedDependencies: dependencies,
+			},
+			RunDetails: RunDetails{
+				Builder: Builder{
+					ID:      BuilderID,
+					Version: map[string]string{"nfpm": build.Version},
+				},
+				Metadata: Metadata{
+					StartedOn:  build.StartedOn.UTC(),
+					FinishedOn: build.FinishedOn.UTC(),
+				},
+			},
+		},
+	}, nil
+}
+
+// sourceFiles returns the sorted paths of the regular files the contents
+// of the package are read from.
+func sourceFiles(packager string, info *nfpm.Info) ([]string, error) {
+	contents, err := files.PrepareForPackagerWithFilesystem(
+		info.Contents,
+		info.Umask,
+		packager,
+		info.DisableGlobbing,
+		info.MTime,
+		info.Filesystem,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var sources []string
+	for _, content := range contents {
+		switch content.Type {
+		case files.TypeSymlink, files.TypeHardlink:
+			// the source is the link target inside the package
+			continue
+		}
+		if content.Source == "" || seen[content.Source] {
+			continue
+		}
+		stat, err := os.Stat(content.Source)
+		if err != nil {
+			return nil, err
+		}
+		if !stat.Mode().IsRegular() {
+			continue
+		}
+		seen[content.Source] = true
+		sources = append(sources, content.Source)
+	}
+	sort.Strings(sources)
+	return sources, nil
+}
+
+func describe(path, name string) (ResourceDescriptor, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ResourceDescriptor{}, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ResourceDescriptor{}, fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return ResourceDescriptor{
+		Name:   name,
+		Digest: map[string]string{"sha256": hex.EncodeToString(h.Sum(nil))},
+	}, nil
+}
+
+// Envelope is a DSSE envelope holding the signed statement.
+type Envelope struct {
+	PayloadType string              `json:"payloadType"`
+	Payload     string              `json:"payload"`
+	Signatures  []EnvelopeSignature `json:"signatures"`
+}
+
+// EnvelopeSignature is a signature of the DSSE envelope payload.
+type EnvelopeSignature struct {
+	KeyID string `json:"keyid,omitempty"`
+	Sig   string `json:"sig"`
+}
+
+// Sign signs the statement as a DSSE envelope with the signing key
+// configured for the packager: a binary detached OpenPGP signature, or for
+// apk a PKCS#1 v1.5 RSA signature of the SHA256 digest.
+func Sign(statement Statement, packager string, info *nfpm.Info) (Envelope, error) {
+	payload, err := json.Marshal(statement)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	signer, keyID, err := signerOf(packager, info)
+	if err != nil {
+		return Envelope{}, err
+	}
+	signature, err := signer(PAE(PayloadType, payload))
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	return Envelope{
+		PayloadType: PayloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures: []EnvelopeSignature{{
+			KeyID: keyID,
+			Sig:   base64.StdEncoding.EncodeToString(signature),
+		}},
+	}, nil
+}
+
+// PAE returns the DSSE pre-authentication encoding of the payload, which is
+// the message that is signed.
+func PAE(payloadType string, payload []byte) []byte {
+	return append([]byte(fmt.Sprintf("DSSEv1 %d %s %d ", len(payloadType), payloadType, len(payload))), payload...)
+}
+
+// CheckSigner returns an error if the provenance of a package of the
+// packager cannot be signed, so it can be checked before the package is
+// built.
+func CheckSigner(packager string, info *nfpm.Info) error {
+	_, _, err := signerOf(packager, info)
+	return err
+}
+
+// signatureOf returns the signing key of the packager and, for apk, its key
+// name. It is the main key, or the first additional key if only those are
+// configured.
+func signatureOf(packager string, info *nfpm.Info) (nfpm.PackageSignature, string) {
+	switch packager {
+	case "deb", "dsc":
+		if sig := info.Deb.Signature; !sig.PackageSignature.IsSet() && len(sig.Keys) > 0 {
+			return sig.Keys[0].PackageSignature, ""
+		}
+		return info.Deb.Signature.PackageSignature, ""
+	case "rpm", "srpm":
+		if sig := info.RPM.Signature; !sig.PackageSignature.IsSet() && len(sig.Keys) > 0 {
+			return sig.Keys[0], ""
+		}
+		return info.RPM.Signature.PackageSignature, ""
+	case "archlinux":
+		return info.ArchLinux.Signature, ""
+	case "ipk":
+		return info.IPK.Signature, ""
+	case "apk":
+		if sig := info.APK.Signature; !sig.PackageSignature.IsSet() && len(sig.Keys) > 0 {
+			return sig.Keys[0].PackageSignature, sig.Keys[0].KeyName
+		}
+		return info.APK.Signature.PackageSignature, info.APK.Signature.KeyName
+	}
+	return nfpm.PackageSignature{}, ""
+}
+
+func signerOf(packager string, info *nfpm.Info) (func([]byte) ([]byte, error), string, error) {
+	sig, keyName := signatureOf(packager, info)
+	if !sig.IsSet() {
+		return nil, "", nfpm.ErrNoSigningKey
+	}
+
+	if packager == "apk" {
+		return func(message []byte) ([]byte, error) {
+			digest := sha256.Sum256(message)
+			if signFn := sign.SignFn(sig, sign.RSADigest); signFn != nil {
+				return signFn(bytes.NewReader(digest[:]))
+			}
+			return sign.RSASignDigest(digest[:], crypto.SHA256, sig.KeyFile, sig.KeyPassphrase)
+		}, keyName, nil
+	}
+
+	if signFn := sign.SignFn(sig, sign.PGPDetached); signFn != nil {
+		return func(message []byte) ([]byte, error) {
+			return signFn(bytes.NewReader(message))
+		}, pointer.GetString(sig.KeyID), nil
+	}
+	keyID, err := sign.PGPKeyID(sig.KeyFile, sig.KeyID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read signing key id: %w", err)
+	}
+	return sign.PGPSignerWithKeyID(sig.KeyFile, sig.KeyPassphrase, sig.KeyID), keyID, nil
+}
+
+// Write writes the envelope as a single JSON line to the given path.
+func (e Envelope) Write(path string) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(e); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write provenance: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package provenance
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/sign"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvenance(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "foo_1.0.0_amd64.deb")
+	config := filepath.Join(dir, "nfpm.yaml")
+	source := filepath.Join(dir, "bin", "foo")
+	require.NoError(t, os.WriteFile(target, []byte("hello"), 0o600))
+	require.NoError(t, os.WriteFile(config, []byte("name: foo"), 0o600))
+	require.NoError(t, os.MkdirAll(filepath.Dir(source), 0o700))
+	require.NoError(t, os.WriteFile(source, []byte("foo"), 0o600))
+
+	info := nfpm.WithDefaults(&nfpm.Info{
+		Name:    "foo",
+		Arch:    "amd64",
+		Version: "1.0.0",
+		Overridables: nfpm.Overridables{
+			Contents: files.Contents{
+				{Source: filepath.Join(dir, "bin", "*"), Destination: "/usr/bin/"},
+				{Source: source, Destination: "/usr/bin/bar", Type: files.TypeConfig},
+				{Source: "/usr/bin/foo", Destination: "/usr/bin/baz", Type: files.TypeSymlink},
+				{Destination: "/var/lib/foo", Type: files.TypeDir},
+			},
+		},
+	})
+	info.Deb.Signature.KeyFile = "../sign/testdata/privkey_unprotected.asc"
+
+	started := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	build := Build{
+		Parameters: Parameters{
+			Config:   config,
+			Packager: "deb",
+			Arch:     "amd64",
+			Target:   target,
+			Sets:     []string{"version=1.0.0", "deb.compression=zstd"},
+		},
+		Version:    "v2.40.0",
+		StartedOn:  started,
+		FinishedOn: started.Add(time.Second),
+	}
+	statement, err := New(build, info)
+	require.NoError(t, err)
+	require.Equal(t, StatementType, statement.Type)
+	require.Equal(t, PredicateType, statement.PredicateType)
+	require.Equal(t, []ResourceDescriptor{{
+		Name:   "foo_1.0.0_amd64.deb",
+		Digest: map[string]string{"sha256": "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"},
+	}}, statement.Subject)
+	require.Equal(t, build.Parameters, statement.Predicate.BuildDefinition.ExternalParameters)
+	require.Equal(t, []ResourceDescriptor{
+		{Name: config, Digest: map[string]string{"sha256": "de67db2c68270fcb96eea259819327276467a1cf570b6dcf5d5e18fe5cc390d7"}},
+		{Name: files.ToNixPath(source), Digest: map[string]string{"sha256": "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"}},
+	}, statement.Predicate.BuildDefinition.ResolvedDependencies)
+	require.Equal(t, Builder{ID: BuilderID, Version: map[string]string{"nfpm": "v2.40.0"}}, statement.Predicate.RunDetails.Builder)
+	require.Equal(t, started.Add(time.Second), statement.Predicate.RunDetails.Metadata.FinishedOn)
+
+	envelope, err := Sign(statement, "deb", info)
+	require.NoError(t, err)
+	require.Len(t, envelope.Signatures, 1)
+	require.Equal(t, "9890904dfb2ec88a", envelope.Signatures[0].KeyID)
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	require.NoError(t, err)
+	sig, err := base64.StdEncoding.DecodeString(envelope.Signatures[0].Sig)
+	require.NoError(t, err)
+	require.NoError(t, sign.PGPVerify(bytes.NewReader(PAE(PayloadType, payload)), sig, "../sign/testdata/pubkey.asc"))
+
+	path := target + Extension
+	require.NoError(t, envelope.Write(path))
+	bts, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, byte('\n'), bts[len(bts)-1])
+	require.NotContains(t, string(bts[:len(bts)-1]), "\n")
+	var written Envelope
+	require.NoError(t, json.Unmarshal(bts, &written))
+	require.Equal(t, envelope, written)
+	var decoded Statement
+	require.NoError(t, json.Unmarshal(payload, &decoded))
+	require.Equal(t, statement, decoded)
+}
+
+func TestSignAPK(t *testing.T) {
+	info := &nfpm.Info{Name: "foo"}
+	info.APK.Signature.KeyFile = "../sign/testdata/rsa_unprotected.priv"
+	info.APK.Signature.KeyName = "foo@example.com"
+
+	envelope, err := Sign(Statement{Type: StatementType}, "apk", info)
+	require.NoError(t, err)
+	require.Equal(t, "foo@example.com", envelope.Signatures[0].KeyID)
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	require.NoError(t, err)
+	sig, err := base64.StdEncoding.DecodeString(envelope.Signatures[0].Sig)
+	require.NoError(t, err)
+	digest := sha256.Sum256(PAE(PayloadType, payload))
+	require.NoError(t, sign.RSAVerifyDigest(digest[:], crypto.SHA256, sig, "../sign/testdata/rsa_unprotected.pub"))
+}
+
+func TestSignAdditionalKey(t *testing.T) {
+	info := &nfpm.Info{Name: "foo"}
+	info.RPM.Signature.Keys = []nfpm.PackageSignature{{KeyFile: "../sign/testdata/privkey_unprotected.asc"}}
+	require.NoError(t, CheckSigner("rpm", info))
+
+	envelope, err := Sign(Statement{Type: StatementType}, "rpm", info)
+	require.NoError(t, err)
+	require.Equal(t, "9890904dfb2ec88a", envelope.Signatures[0].KeyID)
+	payload, err := base64.StdEncoding.DecodeString(envelope.Payload)
+	require.NoError(t, err)
+	sig, err := base64.StdEncoding.DecodeString(envelope.Signatures[0].Sig)
+	require.NoError(t, err)
+	require.NoError(t, sign.PGPVerify(bytes.NewReader(PAE(PayloadType, payload)), sig, "../sign/testdata/pubkey.asc"))
+}
+
+func TestSignUnsigned(t *testing.T) {
+	_, err := Sign(Statement{}, "rpm", &nfpm.Info{Name: "foo"})
+	require.ErrorIs(t, err, nfpm.ErrNoSigningKey)
+	require.ErrorIs(t, CheckSigner("rpm", &nfpm.Info{Name: "foo"}), nfpm.ErrNoSigningKey)
+}
+
+func TestPAE(t *testing.T) {
+	require.Equal(t, "DSSEv1 28 application/vnd.in-toto+json 2 {}", string(PAE(PayloadType, []byte("{}"))))
+}
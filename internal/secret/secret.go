@@ -0,0 +1,129 @@
+// Package secret reads signing keys and passphrases, which can be stored in
+// files, passed through stdin or an inherited file descriptor, or set as
+// base64 data in an environment variable.
+package secret
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// Stdin reads the secret from the standard input.
+	Stdin = "-"
+	// FDPrefix reads the secret from an inherited file descriptor, e.g. fd:3.
+	FDPrefix = "fd:"
+	// EnvPrefix reads the base64 encoded secret from an environment
+	// variable, e.g. env:SIGNING_KEY.
+	EnvPrefix = "env:"
+)
+
+// nolint: gochecknoglobals
+var (
+	// stdin and file descriptors can only be read once, so their content
+	// is kept in memory for the other packagers.
+	streams     = map[string]stream{}
+	streamsLock sync.Mutex
+	stdin       io.Reader = os.Stdin
+)
+
+var errEmpty = errors.New("no data")
+
+// Purposes a secret is read for. A stream can only provide one of them.
+const (
+	purposeKey        = "signing key"
+	purposePassphrase = "passphrase"
+)
+
+// stream is the content of stdin or a file descriptor and what it was read
+// for.
+type stream struct {
+	purpose string
+	data    []byte
+}
+
+// Read returns the secret stored in the given source, which is either a
+// file path, Stdin, an FDPrefix or an EnvPrefix followed by the file
+// descriptor or the environment variable.
+func Read(source string) ([]byte, error) {
+	return read(source, purposeKey)
+}
+
+// ReadPassphrase returns the passphrase stored in the given source, without
+// trailing line breaks.
+func ReadPassphrase(source string) (string, error) {
+	data, err := read(source, purposePassphrase)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func read(source, purpose string) ([]byte, error) {
+	switch {
+	case source == Stdin:
+		return readStream(source, purpose, func() (io.ReadCloser, error) {
+			return io.NopCloser(stdin), nil
+		})
+	case strings.HasPrefix(source, FDPrefix):
+		fd, err := strconv.ParseUint(strings.TrimPrefix(source, FDPrefix), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file descriptor %q: %w", source, err)
+		}
+		return readStream(source, purpose, func() (io.ReadCloser, error) {
+			f := os.NewFile(uintptr(fd), source)
+			if f == nil {
+				return nil, fmt.Errorf("invalid file descriptor %q", source)
+			}
+			return f, nil
+		})
+	case strings.HasPrefix(source, EnvPrefix):
+		name := strings.TrimPrefix(source, EnvPrefix)
+		value := os.Getenv(name)
+		if value == "" {
+			return nil, fmt.Errorf("reading $%s: %w", name, errEmpty)
+		}
+		// allow line-wrapped output, as written by base64
+		data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(value), ""))
+		if err != nil {
+			return nil, fmt.Errorf("decoding base64 data of $%s: %w", name, err)
+		}
+		return data, nil
+	default:
+		return os.ReadFile(source)
+	}
+}
+
+func readStream(source, purpose string, open func() (io.ReadCloser, error)) ([]byte, error) {
+	streamsLock.Lock()
+	defer streamsLock.Unlock()
+
+	if s, ok := streams[source]; ok {
+		if s.purpose != purpose {
+			return nil, fmt.Errorf("%s already provides the %s, it cannot provide the %s as well", source, s.purpose, purpose)
+		}
+		return s.data, nil
+	}
+
+	r, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close() // nolint: errcheck
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", source, err)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("reading %s: %w", source, errEmpty)
+	}
+	streams[source] = stream{purpose: purpose, data: data}
+	return data, nil
+}
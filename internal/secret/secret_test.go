@@ -0,0 +1,73 @@
+package secret
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRead(t *testing.T) {
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "key")
+		require.NoError(t, os.WriteFile(path, []byte("key"), 0o600))
+		data, err := Read(path)
+		require.NoError(t, err)
+		require.Equal(t, []byte("key"), data)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		encoded := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("key", 40)))
+		t.Setenv("NFPM_TEST_KEY", encoded[:60]+"\n"+encoded[60:]+"\n")
+		data, err := Read("env:NFPM_TEST_KEY")
+		require.NoError(t, err)
+		require.Equal(t, []byte(strings.Repeat("key", 40)), data)
+
+		t.Setenv("NFPM_TEST_KEY", "not base64!")
+		_, err = Read("env:NFPM_TEST_KEY")
+		require.ErrorContains(t, err, "decoding base64 data of $NFPM_TEST_KEY")
+	})
+
+	t.Run("stdin", func(t *testing.T) {
+		t.Cleanup(func() { delete(streams, Stdin) })
+		stdin = strings.NewReader("key")
+		t.Cleanup(func() { stdin = os.Stdin })
+
+		for i := 0; i < 2; i++ {
+			data, err := Read(Stdin)
+			require.NoError(t, err)
+			require.Equal(t, []byte("key"), data)
+		}
+
+		_, err := ReadPassphrase(Stdin)
+		require.EqualError(t, err, "- already provides the signing key, it cannot provide the passphrase as well")
+	})
+
+	t.Run("fd", func(t *testing.T) {
+		r, w, err := os.Pipe()
+		require.NoError(t, err)
+		_, err = w.WriteString("key\n")
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		// Read takes over and closes the file descriptor
+		source := fmt.Sprintf("fd:%d", r.Fd())
+		t.Cleanup(func() {
+			delete(streams, source)
+			_ = r.Close()
+		})
+		passphrase, err := ReadPassphrase(source)
+		require.NoError(t, err)
+		require.Equal(t, "key", passphrase)
+
+		_, err = Read(source)
+		require.EqualError(t, err, source+" already provides the passphrase, it cannot provide the signing key as well")
+
+		_, err = Read("fd:three")
+		require.ErrorContains(t, err, `invalid file descriptor "fd:three"`)
+	})
+}
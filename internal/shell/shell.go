@@ -0,0 +1,9 @@
+// Package shell contains helpers to generate snippets of maintainer scripts.
+package shell
+
+import "strings"
+
+// Quote quotes the given value for POSIX shells.
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,36 @@
+package sign
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// CommandSigner returns a signing function that runs the given command with
+// the data to sign on its standard input, and uses what the command writes to
+// its standard output as the signature.
+func CommandSigner(command []string) func(io.Reader) ([]byte, error) {
+	return func(message io.Reader) ([]byte, error) {
+		if len(command) == 0 {
+			return nil, fmt.Errorf("no signing command given")
+		}
+
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command(command[0], command[1:]...) // nolint:gosec
+		cmd.Stdin = message
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return nil, fmt.Errorf("signing command %s: %w: %s", command[0], err, msg)
+			}
+			return nil, fmt.Errorf("signing command %s: %w", command[0], err)
+		}
+		if stdout.Len() == 0 {
+			return nil, fmt.Errorf("signing command %s: no signature written to standard output", command[0])
+		}
+		return stdout.Bytes(), nil
+	}
+}
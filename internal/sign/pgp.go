@@ -8,12 +8,15 @@ import (
 	"io"
 	"os"
 	"strconv"
+	"time"
 	"unicode"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
 	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
 	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/internal/secret"
 )
 
 // PGPSignerWithKeyID returns a PGP signer that creates a detached non-ASCII-armored
@@ -29,6 +32,10 @@ func PGPSignerWithKeyID(keyFile, passphrase string, hexKeyID *string) func([]byt
 		if err != nil {
 			return nil, &nfpm.ErrSigningFailure{Err: err}
 		}
+		signingKey, err := selectSigningKey(key, keyID, time.Now())
+		if err != nil {
+			return nil, &nfpm.ErrSigningFailure{Err: err}
+		}
 
 		var signature bytes.Buffer
 
@@ -37,7 +44,7 @@ func PGPSignerWithKeyID(keyFile, passphrase string, hexKeyID *string) func([]byt
 			key,
 			bytes.NewReader(data),
 			&packet.Config{
-				SigningKeyId: keyID,
+				SigningKeyId: signingKey.PublicKey.KeyId,
 				DefaultHash:  crypto.SHA256,
 			},
 		); err != nil {
@@ -48,6 +55,28 @@ func PGPSignerWithKeyID(keyFile, passphrase string, hexKeyID *string) func([]byt
 	}
 }
 
+// PGPDetachSign returns a binary detached OpenPGP signature of the message,
+// created with the signer configured for the given signature or its key
+// file.
+func PGPDetachSign(signature nfpm.PackageSignature, message io.Reader) ([]byte, error) {
+	if signFn := SignFn(signature, PGPDetached); signFn != nil {
+		sig, err := signFn(message)
+		if err != nil {
+			return nil, &nfpm.ErrSigningFailure{Err: err}
+		}
+		return sig, nil
+	}
+	if signature.KeyFile == "" {
+		return nil, nfpm.ErrNoSigningKey
+	}
+
+	data, err := io.ReadAll(message)
+	if err != nil {
+		return nil, err
+	}
+	return PGPSignerWithKeyID(signature.KeyFile, signature.KeyPassphrase, signature.KeyID)(data)
+}
+
 // PGPArmoredDetachSign creates an ASCII-armored detached signature.
 func PGPArmoredDetachSign(message io.Reader, keyFile, passphrase string) ([]byte, error) {
 	return PGPArmoredDetachSignWithKeyID(message, keyFile, passphrase, nil)
@@ -64,11 +93,15 @@ func PGPArmoredDetachSignWithKeyID(message io.Reader, keyFile, passphrase string
 	if err != nil {
 		return nil, fmt.Errorf("armored detach sign: %w", err)
 	}
+	signingKey, err := selectSigningKey(key, keyID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("armored detach sign: %w", err)
+	}
 
 	var signature bytes.Buffer
 
 	err = openpgp.ArmoredDetachSign(&signature, key, message, &packet.Config{
-		SigningKeyId: keyID,
+		SigningKeyId: signingKey.PublicKey.KeyId,
 		DefaultHash:  crypto.SHA256,
 	})
 	if err != nil {
@@ -88,14 +121,18 @@ func PGPClearSignWithKeyID(message io.Reader, keyFile, passphrase string, hexKey
 	if err != nil {
 		return nil, fmt.Errorf("clear sign: %w", err)
 	}
+	signingKey, err := selectSigningKey(key, keyID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("clear sign: %w", err)
+	}
 
 	var signature bytes.Buffer
 
 	writeCloser, err := clearsign.Encode(
 		&signature,
-		key.PrivateKey,
+		signingKey.PrivateKey,
 		&packet.Config{
-			SigningKeyId: keyID,
+			SigningKeyId: signingKey.PublicKey.KeyId,
 			DefaultHash:  crypto.SHA256,
 		},
 	)
@@ -114,6 +151,39 @@ func PGPClearSignWithKeyID(message io.Reader, keyFile, passphrase string, hexKey
 	return signature.Bytes(), nil
 }
 
+// PGPJoinArmoredSignatures joins ASCII-armored detached signatures of the same
+// message into a single armored block, which verifiers check signature by
+// signature.
+func PGPJoinArmoredSignatures(signatures ...[]byte) ([]byte, error) {
+	if len(signatures) == 1 {
+		return signatures[0], nil
+	}
+
+	var packets bytes.Buffer
+	for _, signature := range signatures {
+		block, err := armor.Decode(bytes.NewReader(signature))
+		if err != nil {
+			return nil, fmt.Errorf("decode armored signature: %w", err)
+		}
+		if _, err := io.Copy(&packets, block.Body); err != nil {
+			return nil, fmt.Errorf("decode armored signature: %w", err)
+		}
+	}
+
+	var joined bytes.Buffer
+	w, err := armor.Encode(&joined, openpgp.SignatureType, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(packets.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return joined.Bytes(), nil
+}
+
 // PGPVerify is exported for use in tests and verifies an ASCII-armored or non-ASCII-armored
 // signature using an ASCII-armored or non-ASCII-armored public key file. The signer
 // identity is not explicitly checked, other that the obvious fact that the signer's key must
@@ -189,10 +259,170 @@ var (
 	errMoreThanOneKey = errors.New("more than one signing key in keyring")
 	errNoKeys         = errors.New("no signing key in keyring")
 	errNoPassword     = errors.New("key is encrypted but no passphrase was provided")
+	errInvalidKey     = errors.New("invalid signing key")
 )
 
+// selectSigningKey returns the key of the entity with the given key id, or
+// the newest valid subkey that can sign, falling back to the primary key.
+// Unlike openpgp, which silently picks another key, it fails if the key that
+// should sign is expired, revoked or lacks the signing flag, as packages
+// signed with it are rejected at install time.
+func selectSigningKey(entity *openpgp.Entity, keyID uint64, now time.Time) (openpgp.Key, error) {
+	selfSig, identity := entity.PrimarySelfSignature()
+	if selfSig == nil {
+		return openpgp.Key{}, fmt.Errorf("%w: key %016x has no self-signature", errInvalidKey, entity.PrimaryKey.KeyId)
+	}
+	primary := openpgp.Key{
+		Entity:        entity,
+		PublicKey:     entity.PrimaryKey,
+		PrivateKey:    entity.PrivateKey,
+		SelfSignature: selfSig,
+		Revocations:   entity.Revocations,
+	}
+	// subkeys cannot be used once the primary key is invalid
+	revoked := entity.Revoked(now) || (identity != nil && identity.Revoked(now))
+	if err := checkKeyValidity(primary, revoked, now); err != nil {
+		return openpgp.Key{}, err
+	}
+
+	// prefer the newest valid subkey that can sign, but report the newest
+	// one if none of them is valid
+	var newest, newestValid *openpgp.Subkey
+	for i := range entity.Subkeys {
+		sub := &entity.Subkeys[i]
+		if keyID != 0 {
+			if sub.PublicKey.KeyId == keyID {
+				return checkSubkey(entity, sub, now)
+			}
+			continue
+		}
+		if !sub.Sig.FlagsValid || !sub.Sig.FlagSign || sub.PrivateKey == nil {
+			continue
+		}
+		if newest == nil || sub.Sig.CreationTime.After(newest.Sig.CreationTime) {
+			newest = sub
+		}
+		if checkKeyValidity(subkey(entity, sub), sub.Revoked(now), now) == nil &&
+			(newestValid == nil || sub.Sig.CreationTime.After(newestValid.Sig.CreationTime)) {
+			newestValid = sub
+		}
+	}
+	if newestValid != nil {
+		return checkSubkey(entity, newestValid, now)
+	}
+	if newest != nil {
+		return checkSubkey(entity, newest, now)
+	}
+
+	if keyID != 0 && entity.PrimaryKey.KeyId != keyID {
+		return openpgp.Key{}, fmt.Errorf("%w: no key with id %016x", errInvalidKey, keyID)
+	}
+	if err := checkSigningFlag(primary); err != nil {
+		return openpgp.Key{}, err
+	}
+	return primary, nil
+}
+
+func subkey(entity *openpgp.Entity, sub *openpgp.Subkey) openpgp.Key {
+	return openpgp.Key{
+		Entity:        entity,
+		PublicKey:     sub.PublicKey,
+		PrivateKey:    sub.PrivateKey,
+		SelfSignature: sub.Sig,
+		Revocations:   sub.Revocations,
+	}
+}
+
+func checkSubkey(entity *openpgp.Entity, sub *openpgp.Subkey, now time.Time) (openpgp.Key, error) {
+	key := subkey(entity, sub)
+	if err := checkKeyValidity(key, sub.Revoked(now), now); err != nil {
+		return openpgp.Key{}, err
+	}
+	if err := checkSigningFlag(key); err != nil {
+		return openpgp.Key{}, err
+	}
+	return key, nil
+}
+
+func checkKeyValidity(key openpgp.Key, revoked bool, now time.Time) error {
+	switch {
+	case revoked:
+		return fmt.Errorf("%w: key %016x is revoked", errInvalidKey, key.PublicKey.KeyId)
+	case key.PublicKey.CreationTime.Unix() > now.Unix():
+		return fmt.Errorf("%w: key %016x is created in the future", errInvalidKey, key.PublicKey.KeyId)
+	case key.PublicKey.KeyExpired(key.SelfSignature, now):
+		expiry := key.PublicKey.CreationTime.Add(time.Duration(*key.SelfSignature.KeyLifetimeSecs) * time.Second)
+		return fmt.Errorf("%w: key %016x expired on %s", errInvalidKey, key.PublicKey.KeyId, expiry.Format(time.DateOnly))
+	case key.SelfSignature.SigExpired(now):
+		return fmt.Errorf("%w: self-signature of key %016x expired", errInvalidKey, key.PublicKey.KeyId)
+	}
+	return nil
+}
+
+func checkSigningFlag(key openpgp.Key) error {
+	if (key.SelfSignature.FlagsValid && !key.SelfSignature.FlagSign) || !key.PublicKey.PubKeyAlgo.CanSign() {
+		return fmt.Errorf("%w: key %016x is not allowed to sign", errInvalidKey, key.PublicKey.KeyId)
+	}
+	return nil
+}
+
+// PGPKeyID returns the hex ID of the key that is used when signing with the
+// given key file and optional key ID. The key does not need to be decrypted.
+func PGPKeyID(keyFile string, hexKeyID *string) (string, error) {
+	keyID, err := parseKeyID(hexKeyID)
+	if err != nil {
+		return "", fmt.Errorf("%v is not a valid key id: %w", hexKeyID, err)
+	}
+	if keyID != 0 {
+		return fmt.Sprintf("%016x", keyID), nil
+	}
+
+	key, err := readSigningEntity(keyFile)
+	if err != nil {
+		return "", err
+	}
+
+	signingKey, err := selectSigningKey(key, 0, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%016x", signingKey.PublicKey.KeyId), nil
+}
+
+// readSigningKey reads and decrypts the signing key. The key file is either a
+// path or one of the sources supported by secret.Read, in which case the key
+// is decoded in memory.
 func readSigningKey(keyFile, passphrase string) (*openpgp.Entity, error) {
-	fileContent, err := os.ReadFile(keyFile)
+	key, err := readSigningEntity(keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if key.PrivateKey.Encrypted {
+		if passphrase == "" {
+			return nil, errNoPassword
+		}
+		pw := []byte(passphrase)
+		err = key.PrivateKey.Decrypt(pw)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt secret signing key: %w", err)
+		}
+		for _, sub := range key.Subkeys {
+			if sub.PrivateKey != nil {
+				if err := sub.PrivateKey.Decrypt(pw); err != nil {
+					return nil, fmt.Errorf("gopenpgp: error in unlocking sub key: %w", err)
+				}
+			}
+		}
+	}
+
+	return key, nil
+}
+
+// readSigningEntity reads the keyring in the given key file and returns the only
+// entity in it that can be used for signing.
+func readSigningEntity(keyFile string) (*openpgp.Entity, error) {
+	fileContent, err := secret.Read(keyFile)
 	if err != nil {
 		return nil, fmt.Errorf("reading PGP key file: %w", err)
 	}
@@ -232,24 +462,6 @@ func readSigningKey(keyFile, passphrase string) (*openpgp.Entity, error) {
 		return nil, errNoKeys
 	}
 
-	if key.PrivateKey.Encrypted {
-		if passphrase == "" {
-			return nil, errNoPassword
-		}
-		pw := []byte(passphrase)
-		err = key.PrivateKey.Decrypt(pw)
-		if err != nil {
-			return nil, fmt.Errorf("decrypt secret signing key: %w", err)
-		}
-		for _, sub := range key.Subkeys {
-			if sub.PrivateKey != nil {
-				if err := sub.PrivateKey.Decrypt(pw); err != nil {
-					return nil, fmt.Errorf("gopenpgp: error in unlocking sub key: %w", err)
-				}
-			}
-		}
-	}
-
 	return key, nil
 }
 
@@ -2,11 +2,16 @@ package sign
 
 import (
 	"bytes"
+	"encoding/base64"
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/AlekSi/pointer"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	"github.com/ProtonMail/gopenpgp/v2/crypto"
 	"github.com/goreleaser/nfpm/v2"
 	"github.com/stretchr/testify/require"
@@ -138,6 +143,18 @@ func TestReadKey(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestReadKeyFromEnv(t *testing.T) {
+	key, err := os.ReadFile("testdata/privkey.gpg")
+	require.NoError(t, err)
+	t.Setenv("NFPM_TEST_SIGNING_KEY", base64.StdEncoding.EncodeToString(key))
+
+	_, err = readSigningKey("env:NFPM_TEST_SIGNING_KEY", pass)
+	require.NoError(t, err)
+
+	_, err = readSigningKey("env:NFPM_TEST_MISSING_SIGNING_KEY", pass)
+	require.EqualError(t, err, "reading PGP key file: reading $NFPM_TEST_MISSING_SIGNING_KEY: no data")
+}
+
 func TestIsASCII(t *testing.T) {
 	data, err := os.ReadFile("testdata/privkey.asc")
 	require.NoError(t, err)
@@ -147,3 +164,154 @@ func TestIsASCII(t *testing.T) {
 	require.NoError(t, err)
 	require.False(t, isASCII(data))
 }
+
+func TestPGPKeyID(t *testing.T) {
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			keyID, err := PGPKeyID(testCase.privKeyFile, testCase.keyID)
+			require.NoError(t, err)
+			require.Len(t, keyID, 16)
+			if testCase.keyID != nil {
+				require.Equal(t, *testCase.keyID, keyID)
+			}
+
+			sig, err := PGPSignerWithKeyID(testCase.privKeyFile, testCase.pass, testCase.keyID)([]byte("testdata"))
+			require.NoError(t, err)
+			sigIDs, _ := crypto.NewPGPSignature(sig).GetSignatureKeyIDs()
+			require.Len(t, sigIDs, 1)
+			require.Equal(t, fmt.Sprintf("%016x", sigIDs[0]), keyID)
+		})
+	}
+}
+
+func TestPGPKeyIDError(t *testing.T) {
+	_, err := PGPKeyID("testdata/pubkey.asc", nil)
+	require.EqualError(t, err, "no signing key in keyring")
+}
+
+func TestKeyAlgorithms(t *testing.T) {
+	for name, config := range map[string]*packet.Config{
+		"ed25519 legacy": {Algorithm: packet.PubKeyAlgoEdDSA},
+		"ecdsa p256":     {Algorithm: packet.PubKeyAlgoECDSA, Curve: packet.CurveNistP256},
+		"ed25519 v6":     {Algorithm: packet.PubKeyAlgoEd25519, V6Keys: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			entity, err := openpgp.NewEntity("nfpm", "", "nfpm@example.com", config)
+			require.NoError(t, err)
+			privKeyFile, pubKeyFile := writeEntity(t, entity)
+			data := []byte("testdata")
+
+			sig, err := PGPSignerWithKeyID(privKeyFile, "", nil)(data)
+			require.NoError(t, err)
+			require.NoError(t, PGPVerify(bytes.NewReader(data), sig, pubKeyFile))
+
+			sig, err = PGPArmoredDetachSignWithKeyID(bytes.NewReader(data), privKeyFile, "", nil)
+			require.NoError(t, err)
+			require.NoError(t, PGPVerify(bytes.NewReader(data), sig, pubKeyFile))
+
+			sig, err = PGPClearSignWithKeyID(bytes.NewReader(data), privKeyFile, "", nil)
+			require.NoError(t, err)
+			plaintext, err := PGPReadMessage(sig, pubKeyFile)
+			require.NoError(t, err)
+			require.Equal(t, data, plaintext)
+		})
+	}
+}
+
+func TestSelectSigningKey(t *testing.T) {
+	now := time.Now()
+	past := func(config *packet.Config) *packet.Config {
+		config.Time = func() time.Time { return now.Add(-48 * time.Hour) }
+		config.KeyLifetimeSecs = 24 * 60 * 60
+		return config
+	}
+	newEntity := func(t *testing.T, config *packet.Config) *openpgp.Entity {
+		t.Helper()
+		entity, err := openpgp.NewEntity("nfpm", "", "nfpm@example.com", config)
+		require.NoError(t, err)
+		return entity
+	}
+
+	t.Run("primary key", func(t *testing.T) {
+		entity := newEntity(t, nil)
+		key, err := selectSigningKey(entity, 0, time.Now())
+		require.NoError(t, err)
+		require.Equal(t, entity.PrimaryKey.KeyId, key.PublicKey.KeyId)
+	})
+
+	t.Run("newest valid subkey", func(t *testing.T) {
+		entity := newEntity(t, nil)
+		require.NoError(t, entity.AddSigningSubkey(nil))
+		require.NoError(t, entity.AddSigningSubkey(&packet.Config{
+			Time: func() time.Time { return now.Add(time.Minute) },
+		}))
+		newest := &entity.Subkeys[len(entity.Subkeys)-1]
+		require.NoError(t, entity.RevokeSubkey(newest, packet.KeySuperseded, "", nil))
+
+		key, err := selectSigningKey(entity, 0, now.Add(time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, entity.Subkeys[1].PublicKey.KeyId, key.PublicKey.KeyId)
+	})
+
+	t.Run("expired primary key", func(t *testing.T) {
+		entity := newEntity(t, past(&packet.Config{}))
+		_, err := selectSigningKey(entity, 0, now)
+		require.ErrorIs(t, err, errInvalidKey)
+		require.ErrorContains(t, err, fmt.Sprintf("key %016x expired on %s",
+			entity.PrimaryKey.KeyId, now.Add(-24*time.Hour).Format(time.DateOnly)))
+	})
+
+	t.Run("revoked primary key", func(t *testing.T) {
+		entity := newEntity(t, nil)
+		require.NoError(t, entity.RevokeKey(packet.KeyCompromised, "", nil))
+		_, err := selectSigningKey(entity, 0, time.Now())
+		require.EqualError(t, err, fmt.Sprintf("invalid signing key: key %016x is revoked", entity.PrimaryKey.KeyId))
+	})
+
+	t.Run("expired subkey", func(t *testing.T) {
+		entity := newEntity(t, &packet.Config{Time: func() time.Time { return now.Add(-72 * time.Hour) }})
+		require.NoError(t, entity.AddSigningSubkey(past(&packet.Config{})))
+		subkey := entity.Subkeys[len(entity.Subkeys)-1]
+
+		// openpgp silently signs with the primary key instead
+		_, err := selectSigningKey(entity, 0, now)
+		require.ErrorContains(t, err, fmt.Sprintf("key %016x expired on", subkey.PublicKey.KeyId))
+		_, err = selectSigningKey(entity, subkey.PublicKey.KeyId, now)
+		require.ErrorContains(t, err, fmt.Sprintf("key %016x expired on", subkey.PublicKey.KeyId))
+
+		privKeyFile, _ := writeEntity(t, entity)
+		_, err = PGPSignerWithKeyID(privKeyFile, "", nil)([]byte("testdata"))
+		require.ErrorContains(t, err, "invalid signing key")
+		_, err = PGPClearSignWithKeyID(bytes.NewReader([]byte("testdata")), privKeyFile, "", nil)
+		require.ErrorIs(t, err, errInvalidKey)
+	})
+
+	t.Run("subkey without signing flag", func(t *testing.T) {
+		entity := newEntity(t, nil)
+		encryptionKey := entity.Subkeys[0].PublicKey.KeyId
+		_, err := selectSigningKey(entity, encryptionKey, time.Now())
+		require.EqualError(t, err, fmt.Sprintf("invalid signing key: key %016x is not allowed to sign", encryptionKey))
+	})
+
+	t.Run("unknown key id", func(t *testing.T) {
+		_, err := selectSigningKey(newEntity(t, nil), 0xbc8acdd415bd80b3, time.Now())
+		require.EqualError(t, err, "invalid signing key: no key with id bc8acdd415bd80b3")
+	})
+}
+
+// writeEntity writes the secret and public keys of the entity to temporary
+// files.
+func writeEntity(t *testing.T, entity *openpgp.Entity) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+
+	var priv, pub bytes.Buffer
+	require.NoError(t, entity.SerializePrivateWithoutSigning(&priv, nil))
+	require.NoError(t, entity.Serialize(&pub))
+
+	privKeyFile, pubKeyFile := filepath.Join(dir, "key.gpg"), filepath.Join(dir, "key.pub.gpg")
+	require.NoError(t, os.WriteFile(privKeyFile, priv.Bytes(), 0o600))
+	require.NoError(t, os.WriteFile(pubKeyFile, pub.Bytes(), 0o600))
+	return privKeyFile, pubKeyFile
+}
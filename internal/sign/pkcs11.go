@@ -0,0 +1,288 @@
+//go:build cgo
+
+package sign
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/miekg/pkcs11"
+)
+
+// PKCS11Sign signs the message in the given format with the key that is
+// stored on a PKCS#11 token. The PIN is used to log in to the token, if set.
+func PKCS11Sign(message io.Reader, cfg nfpm.PKCS11, pin string, hexKeyID *string, format Format) ([]byte, error) {
+	key, err := openPKCS11Key(cfg, pin)
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11: %w", err)
+	}
+	defer key.close()
+
+	signature, err := signWithSigner(message, key, cfg.PublicKeyFile, hexKeyID, format)
+	if err != nil {
+		return nil, fmt.Errorf("pkcs11: %w", err)
+	}
+	return signature, nil
+}
+
+// pkcs11Key is a crypto.Signer for a private key on a PKCS#11 token.
+type pkcs11Key struct {
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	handle  pkcs11.ObjectHandle
+	public  crypto.PublicKey
+}
+
+func openPKCS11Key(cfg nfpm.PKCS11, pin string) (_ *pkcs11Key, err error) {
+	if cfg.Module == "" || cfg.Label == "" {
+		return nil, errors.New("module and label are required")
+	}
+
+	ctx := pkcs11.New(cfg.Module)
+	if ctx == nil {
+		return nil, fmt.Errorf("cannot load module %s", cfg.Module)
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, fmt.Errorf("initialize module: %w", err)
+	}
+
+	key := &pkcs11Key{ctx: ctx}
+	defer func() {
+		if err != nil {
+			key.close()
+		}
+	}()
+
+	slot, err := findSlot(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	key.session, err = ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return nil, fmt.Errorf("open session: %w", err)
+	}
+	if pin != "" {
+		if err := ctx.Login(key.session, pkcs11.CKU_USER, pin); err != nil &&
+			!errors.Is(err, pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN)) {
+			return nil, fmt.Errorf("login: %w", err)
+		}
+	}
+
+	key.handle, err = findObject(ctx, key.session, pkcs11.CKO_PRIVATE_KEY, cfg.Label)
+	if err != nil {
+		return nil, fmt.Errorf("private key %q: %w", cfg.Label, err)
+	}
+	key.public, err = publicKey(ctx, key.session, key.handle, cfg.Label)
+	if err != nil {
+		return nil, fmt.Errorf("public key %q: %w", cfg.Label, err)
+	}
+	return key, nil
+}
+
+func (k *pkcs11Key) close() {
+	if k.session != 0 {
+		_ = k.ctx.Logout(k.session)
+		_ = k.ctx.CloseSession(k.session)
+	}
+	_ = k.ctx.Finalize()
+	k.ctx.Destroy()
+}
+
+// Public implements crypto.Signer.
+func (k *pkcs11Key) Public() crypto.PublicKey {
+	return k.public
+}
+
+// Sign implements crypto.Signer. RSA signatures are PKCS#1 v1.5 signatures
+// and ECDSA signatures are ASN.1 encoded, like the ones of the standard
+// library.
+func (k *pkcs11Key) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	switch k.public.(type) {
+	case *rsa.PublicKey:
+		prefix, ok := digestInfoPrefixes[opts.HashFunc()]
+		if !ok {
+			return nil, fmt.Errorf("unsupported hash %s", opts.HashFunc())
+		}
+		data := append(append([]byte{}, prefix...), digest...)
+		return k.sign(pkcs11.CKM_RSA_PKCS, data)
+	case *ecdsa.PublicKey:
+		sig, err := k.sign(pkcs11.CKM_ECDSA, digest)
+		if err != nil {
+			return nil, err
+		}
+		return asn1.Marshal(struct{ R, S *big.Int }{
+			R: new(big.Int).SetBytes(sig[:len(sig)/2]),
+			S: new(big.Int).SetBytes(sig[len(sig)/2:]),
+		})
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", k.public)
+	}
+}
+
+func (k *pkcs11Key) sign(mechanism uint, data []byte) ([]byte, error) {
+	if err := k.ctx.SignInit(k.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(mechanism, nil)}, k.handle); err != nil {
+		return nil, fmt.Errorf("sign: %w", err)
+	}
+	sig, err := k.ctx.Sign(k.session, data)
+	if err != nil {
+		return nil, fmt.Errorf("sign: %w", err)
+	}
+	return sig, nil
+}
+
+// findSlot returns the slot of the token with the configured label, the
+// configured slot, or the first slot with a token, in this order.
+func findSlot(ctx *pkcs11.Ctx, cfg nfpm.PKCS11) (uint, error) {
+	if cfg.TokenLabel == "" && cfg.Slot != nil {
+		return *cfg.Slot, nil
+	}
+
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("list slots: %w", err)
+	}
+	for _, slot := range slots {
+		if cfg.TokenLabel == "" {
+			return slot, nil
+		}
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil {
+			return 0, fmt.Errorf("token info: %w", err)
+		}
+		if info.Label == cfg.TokenLabel {
+			return slot, nil
+		}
+	}
+	if cfg.TokenLabel != "" {
+		return 0, fmt.Errorf("no token labeled %q", cfg.TokenLabel)
+	}
+	return 0, errors.New("no token present")
+}
+
+func findObject(ctx *pkcs11.Ctx, session pkcs11.SessionHandle, class uint, label string) (pkcs11.ObjectHandle, error) {
+	if err := ctx.FindObjectsInit(session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}); err != nil {
+		return 0, err
+	}
+	objects, _, err := ctx.FindObjects(session, 2)
+	if ferr := ctx.FindObjectsFinal(session); err == nil {
+		err = ferr
+	}
+	switch {
+	case err != nil:
+		return 0, err
+	case len(objects) == 0:
+		return 0, errors.New("not found")
+	case len(objects) > 1:
+		return 0, errors.New("more than one key with this label")
+	default:
+		return objects[0], nil
+	}
+}
+
+// publicKey reads the public key that belongs to the given private key. RSA
+// private keys hold their public key, while the public point of EC keys is
+// read from the public key with the same label.
+func publicKey(ctx *pkcs11.Ctx, session pkcs11.SessionHandle, private pkcs11.ObjectHandle, label string) (crypto.PublicKey, error) {
+	attrs, err := ctx.GetAttributeValue(session, private, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch keyType := bytesToUint(attrs[0].Value); keyType {
+	case pkcs11.CKK_RSA:
+		attrs, err := ctx.GetAttributeValue(session, private, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(attrs[0].Value),
+			E: int(new(big.Int).SetBytes(attrs[1].Value).Int64()),
+		}, nil
+	case pkcs11.CKK_EC:
+		public, err := findObject(ctx, session, pkcs11.CKO_PUBLIC_KEY, label)
+		if err != nil {
+			return nil, err
+		}
+		attrs, err := ctx.GetAttributeValue(session, public, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return ecPublicKey(attrs[0].Value, attrs[1].Value)
+	default:
+		return nil, fmt.Errorf("unsupported key type %d", keyType)
+	}
+}
+
+func ecPublicKey(params, point []byte) (*ecdsa.PublicKey, error) {
+	var oid asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(params, &oid); err != nil {
+		return nil, fmt.Errorf("parse curve: %w", err)
+	}
+	var curve elliptic.Curve
+	switch oid.String() {
+	case "1.2.840.10045.3.1.7":
+		curve = elliptic.P256()
+	case "1.3.132.0.34":
+		curve = elliptic.P384()
+	case "1.3.132.0.35":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %s", oid)
+	}
+
+	// the point is usually wrapped in a DER octet string
+	var raw []byte
+	if rest, err := asn1.Unmarshal(point, &raw); err != nil || len(rest) > 0 {
+		raw = point
+	}
+	x, y := elliptic.Unmarshal(curve, raw) // nolint:staticcheck
+	if x == nil {
+		return nil, errors.New("invalid EC point")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// bytesToUint decodes a CK_ULONG attribute, which is in native byte order.
+func bytesToUint(b []byte) uint {
+	switch len(b) {
+	case 8:
+		return uint(binary.NativeEndian.Uint64(b))
+	case 4:
+		return uint(binary.NativeEndian.Uint32(b))
+	default:
+		return 0
+	}
+}
+
+// digestInfoPrefixes are the DER encoded DigestInfo prefixes of PKCS#1 v1.5
+// signatures, see RFC 8017, section 9.2.
+//
+// nolint: gochecknoglobals
+var digestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA1:   {0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14},
+	crypto.SHA224: {0x30, 0x2d, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x04, 0x05, 0x00, 0x04, 0x1c},
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
@@ -0,0 +1,16 @@
+//go:build !cgo
+
+package sign
+
+import (
+	"errors"
+	"io"
+
+	"github.com/goreleaser/nfpm/v2"
+)
+
+// PKCS11Sign is not available without cgo, as PKCS#11 modules are shared
+// libraries.
+func PKCS11Sign(io.Reader, nfpm.PKCS11, string, *string, Format) ([]byte, error) {
+	return nil, errors.New("pkcs11: nfpm was built without cgo, which is needed to load PKCS#11 modules")
+}
@@ -0,0 +1,105 @@
+//go:build cgo
+
+package sign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1" // nolint:gosec
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/miekg/pkcs11"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPKCS11Sign needs SoftHSM, its module can be set with
+// NFPM_TEST_PKCS11_MODULE.
+func TestPKCS11Sign(t *testing.T) {
+	module := os.Getenv("NFPM_TEST_PKCS11_MODULE")
+	if module == "" {
+		module = "/usr/lib/softhsm/libsofthsm2.so"
+	}
+	if _, err := os.Stat(module); err != nil {
+		t.Skip("SoftHSM is not installed")
+	}
+
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "softhsm2.conf")
+	require.NoError(t, os.WriteFile(conf, []byte("directories.tokendir = "+dir+"\n"), 0o600))
+	t.Setenv("SOFTHSM2_CONF", conf)
+
+	cfg := nfpm.PKCS11{
+		Module:     module,
+		TokenLabel: "nfpm",
+		Label:      "signing key",
+	}
+	createSoftHSMKey(t, cfg, "1234")
+
+	key, err := openPKCS11Key(cfg, "1234")
+	require.NoError(t, err)
+	public := key.Public().(*rsa.PublicKey)
+	key.close()
+
+	digest := sha1.Sum([]byte("testdata")) // nolint:gosec
+	sig, err := PKCS11Sign(bytes.NewReader(digest[:]), cfg, "1234", nil, RSADigest)
+	require.NoError(t, err)
+	require.NoError(t, rsa.VerifyPKCS1v15(public, crypto.SHA1, digest[:], sig))
+
+	_, err = PKCS11Sign(bytes.NewReader(digest[:]), cfg, "4321", nil, RSADigest)
+	require.ErrorContains(t, err, "CKR_PIN_INCORRECT")
+
+	cfg.Label = "missing"
+	_, err = PKCS11Sign(bytes.NewReader(digest[:]), cfg, "1234", nil, RSADigest)
+	require.EqualError(t, err, `pkcs11: private key "missing": not found`)
+}
+
+func createSoftHSMKey(t *testing.T, cfg nfpm.PKCS11, pin string) {
+	t.Helper()
+
+	ctx := pkcs11.New(cfg.Module)
+	require.NotNil(t, ctx)
+	require.NoError(t, ctx.Initialize())
+	defer ctx.Destroy()
+	defer ctx.Finalize() // nolint:errcheck
+
+	slots, err := ctx.GetSlotList(false)
+	require.NoError(t, err)
+	require.NotEmpty(t, slots)
+	require.NoError(t, ctx.InitToken(slots[0], "so-pin", cfg.TokenLabel))
+
+	// SoftHSM moves initialized tokens to a new slot
+	slot, err := findSlot(ctx, cfg)
+	require.NoError(t, err)
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	require.NoError(t, err)
+	defer ctx.CloseSession(session) // nolint:errcheck
+
+	require.NoError(t, ctx.Login(session, pkcs11.CKU_SO, "so-pin"))
+	require.NoError(t, ctx.InitPIN(session, pin))
+	require.NoError(t, ctx.Logout(session))
+	require.NoError(t, ctx.Login(session, pkcs11.CKU_USER, pin))
+	defer ctx.Logout(session) // nolint:errcheck
+
+	_, _, err = ctx.GenerateKeyPair(session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN, nil)},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, []byte{1, 0, 1}),
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS_BITS, 2048),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, cfg.Label),
+		},
+		[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, cfg.Label),
+		},
+	)
+	require.NoError(t, err)
+}
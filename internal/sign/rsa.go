@@ -11,11 +11,13 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
+
+	"github.com/goreleaser/nfpm/v2/internal/secret"
 )
 
 var (
 	errNoPemBlock   = errors.New("no PEM block found")
-	errDigestNotSH1 = errors.New("digest is not a SHA1 hash")
 	errNoPassphrase = errors.New("key is encrypted but no passphrase was provided")
 	errNoRSAKey     = errors.New("key is not an RSA key")
 )
@@ -28,11 +30,29 @@ const (
 // RSASignSHA1Digest signs the provided SHA1 message digest. The key file
 // must be in the PEM format and can either be encrypted or not.
 func RSASignSHA1Digest(sha1Digest []byte, keyFile, passphrase string) ([]byte, error) {
-	if len(sha1Digest) != sha1.Size {
-		return nil, errDigestNotSH1
+	return RSASignDigest(sha1Digest, crypto.SHA1, keyFile, passphrase)
+}
+
+// RSASignSHA256Digest signs the provided SHA256 message digest.
+func RSASignSHA256Digest(sha256Digest []byte, keyFile, passphrase string) ([]byte, error) {
+	return RSASignDigest(sha256Digest, crypto.SHA256, keyFile, passphrase)
+}
+
+// RSASignSHA512Digest signs the provided SHA512 message digest.
+func RSASignSHA512Digest(sha512Digest []byte, keyFile, passphrase string) ([]byte, error) {
+	return RSASignDigest(sha512Digest, crypto.SHA512, keyFile, passphrase)
+}
+
+// RSASignDigest creates a PKCS#1 v1.5 signature of the provided message
+// digest, which was created with the given hash. The key file must be in the
+// PEM format and can either be encrypted or not. Like with PGP keys, it can
+// also be read from stdin, a file descriptor or an environment variable.
+func RSASignDigest(digest []byte, hash crypto.Hash, keyFile, passphrase string) ([]byte, error) {
+	if len(digest) != hash.Size() {
+		return nil, errDigestSize(hash)
 	}
 
-	keyFileContent, err := os.ReadFile(keyFile)
+	keyFileContent, err := secret.Read(keyFile)
 	if err != nil {
 		return nil, fmt.Errorf("reading key file: %w", err)
 	}
@@ -80,7 +100,7 @@ func RSASignSHA1Digest(sha1Digest []byte, keyFile, passphrase string) ([]byte, e
 		return nil, fmt.Errorf(`key type "%v" is not supported`, block.Type)
 	}
 
-	signature, err := priv.Sign(rand.Reader, sha1Digest, crypto.SHA1)
+	signature, err := priv.Sign(rand.Reader, digest, hash)
 	if err != nil {
 		return nil, fmt.Errorf("signing: %w", err)
 	}
@@ -101,8 +121,15 @@ func rsaSign(message io.Reader, keyFile, passphrase string) ([]byte, error) {
 // RSAVerifySHA1Digest is exported for use in tests and verifies a signature over the
 // provided SHA1 hash of a message. The key file must be in the PEM format.
 func RSAVerifySHA1Digest(sha1Digest, signature []byte, publicKeyFile string) error {
-	if len(sha1Digest) != sha1.Size {
-		return errDigestNotSH1
+	return RSAVerifyDigest(sha1Digest, crypto.SHA1, signature, publicKeyFile)
+}
+
+// RSAVerifyDigest is exported for use in tests and verifies a signature over
+// the provided digest of a message, which was created with the given hash.
+// The key file must be in the PEM format.
+func RSAVerifyDigest(digest []byte, hash crypto.Hash, signature []byte, publicKeyFile string) error {
+	if len(digest) != hash.Size() {
+		return errDigestSize(hash)
 	}
 
 	keyFileContent, err := os.ReadFile(publicKeyFile)
@@ -125,7 +152,7 @@ func RSAVerifySHA1Digest(sha1Digest, signature []byte, publicKeyFile string) err
 		return errNoRSAKey
 	}
 
-	err = rsa.VerifyPKCS1v15(rsaPub, crypto.SHA1, sha1Digest, signature)
+	err = rsa.VerifyPKCS1v15(rsaPub, hash, digest, signature)
 	if err != nil {
 		return fmt.Errorf("verify PKCS1v15 signature: %w", err)
 	}
@@ -142,3 +169,8 @@ func rsaVerify(message io.Reader, signature []byte, publicKeyFile string) error
 
 	return RSAVerifySHA1Digest(sha1Hash.Sum(nil), signature, publicKeyFile)
 }
+
+func errDigestSize(hash crypto.Hash) error {
+	// SHA-256 is called SHA256 everywhere else in nfpm
+	return fmt.Errorf("digest is not a %s hash", strings.ReplaceAll(hash.String(), "-", ""))
+}
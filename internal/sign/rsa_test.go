@@ -2,7 +2,10 @@ package sign
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/sha1" // nolint:gosec
+	"crypto/sha256"
+	"crypto/sha512"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -34,6 +37,22 @@ func TestRSASignAndVerify(t *testing.T) {
 	}
 }
 
+func TestRSASignAndVerifyDigest(t *testing.T) {
+	sha256Digest := sha256.Sum256([]byte("test"))
+	sig, err := RSASignSHA256Digest(sha256Digest[:], "testdata/rsa.priv", pass)
+	require.NoError(t, err)
+	require.NoError(t, RSAVerifyDigest(sha256Digest[:], crypto.SHA256, sig, "testdata/rsa.pub"))
+	require.Error(t, RSAVerifyDigest(sha256Digest[:], crypto.SHA256, sig, "testdata/rsa_pkcs8.pub"))
+
+	sha512Digest := sha512.Sum512([]byte("test"))
+	sig, err = RSASignSHA512Digest(sha512Digest[:], "testdata/rsa_pkcs8.priv", "")
+	require.NoError(t, err)
+	require.NoError(t, RSAVerifyDigest(sha512Digest[:], crypto.SHA512, sig, "testdata/rsa_pkcs8.pub"))
+
+	_, err = RSASignSHA512Digest(sha256Digest[:], "testdata/rsa_pkcs8.priv", "")
+	require.EqualError(t, err, "digest is not a SHA512 hash")
+}
+
 func TestWrongPassphrase(t *testing.T) {
 	testData := []byte("test")
 	_, err := rsaSign(bytes.NewReader(testData), "testdata/rsa.priv", "password123")
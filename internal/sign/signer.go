@@ -0,0 +1,184 @@
+package sign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1" // nolint:gosec
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	pgpecdsa "github.com/ProtonMail/go-crypto/openpgp/ecdsa"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/goreleaser/nfpm/v2"
+)
+
+// Format is the format of the signatures a packager expects from a signing
+// function.
+type Format int
+
+const (
+	// PGPDetached is a binary detached OpenPGP signature, as used by rpm,
+	// archlinux and ipk packages.
+	PGPDetached Format = iota
+	// PGPArmoredDetached is an ASCII-armored detached OpenPGP signature, as
+	// used by debsign.
+	PGPArmoredDetached
+	// PGPClearSign is a clear-signed OpenPGP message, as used by dpkg-sig.
+	PGPClearSign
+	// RSADigest is a PKCS#1 v1.5 RSA signature over the given message digest,
+	// as used by apk packages.
+	RSADigest
+)
+
+var (
+	errNoPublicKeyFile = errors.New("an OpenPGP public key file is needed to create OpenPGP signatures")
+	errNoMatchingKey   = errors.New("no key in the public key file matches the signing key")
+	errUnknownDigest   = errors.New("digest size does not match any supported hash")
+)
+
+// SignFn returns the function that signs data with the backend configured in
+// the given signature: its SignFn, its command or its PKCS#11 key, in this
+// order. The signatures are created in the given format. It returns nil if
+// none of them is configured, in which case the key file should be used.
+func SignFn(signature nfpm.PackageSignature, format Format) func(io.Reader) ([]byte, error) {
+	switch {
+	case signature.SignFn != nil:
+		return signature.SignFn
+	case len(signature.Command) > 0:
+		return CommandSigner(signature.Command)
+	case signature.PKCS11 != nil:
+		cfg := *signature.PKCS11
+		return func(message io.Reader) ([]byte, error) {
+			return PKCS11Sign(message, cfg, signature.KeyPassphrase, signature.KeyID, format)
+		}
+	default:
+		return nil
+	}
+}
+
+// signWithSigner signs the message with the given crypto.Signer in the given
+// format. OpenPGP signatures need the public key of the signer, which is
+// looked up in the OpenPGP public key file.
+func signWithSigner(message io.Reader, signer crypto.Signer, publicKeyFile string, hexKeyID *string, format Format) ([]byte, error) {
+	if format == RSADigest {
+		digest, err := io.ReadAll(message)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := digestHash(digest)
+		if err != nil {
+			return nil, err
+		}
+		return signer.Sign(rand.Reader, digest, hash)
+	}
+
+	if publicKeyFile == "" {
+		return nil, errNoPublicKeyFile
+	}
+	keyID, err := parseKeyID(hexKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("%v is not a valid key id: %w", hexKeyID, err)
+	}
+	entity, key, err := signerEntity(signer, publicKeyFile, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := selectSigningKey(entity, key.KeyId, time.Now()); err != nil {
+		return nil, err
+	}
+
+	config := &packet.Config{
+		SigningKeyId: key.KeyId,
+		DefaultHash:  crypto.SHA256,
+	}
+	var signature bytes.Buffer
+	switch format {
+	case PGPArmoredDetached:
+		err = openpgp.ArmoredDetachSign(&signature, entity, message, config)
+	case PGPClearSign:
+		var w io.WriteCloser
+		w, err = clearsign.Encode(&signature, key, config)
+		if err == nil {
+			if _, err = io.Copy(w, message); err == nil {
+				err = w.Close()
+			}
+		}
+	default:
+		err = openpgp.DetachSign(&signature, entity, message, config)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return signature.Bytes(), nil
+}
+
+// signerEntity returns the entity of the public key file whose primary key or
+// subkey belongs to the given signer, with the signer as its private key.
+func signerEntity(signer crypto.Signer, publicKeyFile string, keyID uint64) (*openpgp.Entity, *packet.PrivateKey, error) {
+	keyring, err := readKeyRing(publicKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, entity := range keyring {
+		if (keyID == 0 || entity.PrimaryKey.KeyId == keyID) && samePublicKey(entity.PrimaryKey, signer.Public()) {
+			entity.PrivateKey = &packet.PrivateKey{PublicKey: *entity.PrimaryKey, PrivateKey: signer}
+			return entity, entity.PrivateKey, nil
+		}
+		for i, sub := range entity.Subkeys {
+			if (keyID == 0 || sub.PublicKey.KeyId == keyID) && samePublicKey(sub.PublicKey, signer.Public()) {
+				entity.Subkeys[i].PrivateKey = &packet.PrivateKey{PublicKey: *sub.PublicKey, PrivateKey: signer}
+				return entity, entity.Subkeys[i].PrivateKey, nil
+			}
+		}
+	}
+	return nil, nil, errNoMatchingKey
+}
+
+func readKeyRing(keyFile string) (openpgp.EntityList, error) {
+	keyFileContent, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading public key file: %w", err)
+	}
+	if isASCII(keyFileContent) {
+		return openpgp.ReadArmoredKeyRing(bytes.NewReader(keyFileContent))
+	}
+	return openpgp.ReadKeyRing(bytes.NewReader(keyFileContent))
+}
+
+func samePublicKey(pk *packet.PublicKey, pub crypto.PublicKey) bool {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		key, ok := pk.PublicKey.(*rsa.PublicKey)
+		return ok && key.Equal(pub)
+	case *ecdsa.PublicKey:
+		key, ok := pk.PublicKey.(*pgpecdsa.PublicKey)
+		return ok && key.X.Cmp(pub.X) == 0 && key.Y.Cmp(pub.Y) == 0
+	default:
+		return false
+	}
+}
+
+// digestHash returns the hash that created the given digest.
+func digestHash(digest []byte) (crypto.Hash, error) {
+	switch len(digest) {
+	case sha1.Size:
+		return crypto.SHA1, nil
+	case sha256.Size:
+		return crypto.SHA256, nil
+	case sha512.Size:
+		return crypto.SHA512, nil
+	default:
+		return 0, errUnknownDigest
+	}
+}
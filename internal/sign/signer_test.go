@@ -0,0 +1,75 @@
+package sign
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"io"
+	"os/exec"
+	"testing"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignFn(t *testing.T) {
+	require.Nil(t, SignFn(nfpm.PackageSignature{KeyFile: "key.asc"}, PGPDetached))
+
+	signFn := func(r io.Reader) ([]byte, error) { return []byte("signed"), nil }
+	sig, err := SignFn(nfpm.PackageSignature{
+		SignFn:  signFn,
+		Command: []string{"false"},
+	}, PGPDetached)(bytes.NewReader(nil))
+	require.NoError(t, err)
+	require.Equal(t, []byte("signed"), sig)
+}
+
+func TestCommandSigner(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	sig, err := CommandSigner([]string{"sh", "-c", "printf sig; cat"})(bytes.NewReader([]byte("data")))
+	require.NoError(t, err)
+	require.Equal(t, []byte("sigdata"), sig)
+
+	_, err = CommandSigner([]string{"sh", "-c", "echo no key >&2; exit 2"})(bytes.NewReader(nil))
+	require.EqualError(t, err, "signing command sh: exit status 2: no key")
+
+	_, err = CommandSigner([]string{"sh", "-c", "cat >/dev/null"})(bytes.NewReader([]byte("data")))
+	require.EqualError(t, err, "signing command sh: no signature written to standard output")
+}
+
+func TestSignWithSigner(t *testing.T) {
+	key, err := readSigningKey("testdata/privkey_unprotected.asc", "")
+	require.NoError(t, err)
+	signer := key.PrivateKey.PrivateKey.(crypto.Signer)
+	data := []byte("testdata")
+
+	for _, format := range []Format{PGPDetached, PGPArmoredDetached} {
+		sig, err := signWithSigner(bytes.NewReader(data), signer, "testdata/pubkey.asc", nil, format)
+		require.NoError(t, err)
+		require.NoError(t, PGPVerify(bytes.NewReader(data), sig, "testdata/pubkey.asc"))
+	}
+
+	sig, err := signWithSigner(bytes.NewReader(data), signer, "testdata/pubkey.gpg", nil, PGPClearSign)
+	require.NoError(t, err)
+	plaintext, err := PGPReadMessage(sig, "testdata/pubkey.asc")
+	require.NoError(t, err)
+	require.Equal(t, data, plaintext)
+
+	digest := sha256.Sum256(data)
+	sig, err = signWithSigner(bytes.NewReader(digest[:]), signer, "", nil, RSADigest)
+	require.NoError(t, err)
+	require.NoError(t, rsa.VerifyPKCS1v15(signer.Public().(*rsa.PublicKey), crypto.SHA256, digest[:], sig))
+
+	_, err = signWithSigner(bytes.NewReader(data), signer, "", nil, PGPDetached)
+	require.ErrorIs(t, err, errNoPublicKeyFile)
+
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, err = signWithSigner(bytes.NewReader(data), other, "testdata/pubkey.asc", nil, PGPDetached)
+	require.ErrorIs(t, err, errNoMatchingKey)
+}
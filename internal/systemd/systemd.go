@@ -0,0 +1,76 @@
+// Package systemd generates maintainer script snippets managing the systemd
+// units of a package with plain systemctl calls, for the formats without
+// helpers of their own.
+package systemd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/internal/shell"
+)
+
+const running = "[ -d /run/systemd/system ]"
+
+// Snippets are the snippets to run after the respective maintainer scripts
+// of the package, or before them in case of PreRemove.
+type Snippets struct {
+	PostInstall string
+	PostUpgrade string
+	PreRemove   string
+	PostRemove  string
+}
+
+// Systemctl returns the snippets enabling and starting the units on install,
+// restarting them on upgrade and stopping and disabling them on removal.
+func Systemctl(units []nfpm.SystemdUnit) Snippets {
+	if len(units) == 0 {
+		return Snippets{}
+	}
+
+	all := Names(units, nil)
+	enable := Names(units, func(unit nfpm.SystemdUnit) bool { return unit.Enable })
+	start := Names(units, func(unit nfpm.SystemdUnit) bool { return unit.Start })
+	restart := Names(units, func(unit nfpm.SystemdUnit) bool { return unit.RestartOnUpgrade })
+
+	reload := fmt.Sprintf("if %s; then\n\tsystemctl daemon-reload >/dev/null 2>&1 || :\nfi\n", running)
+
+	var postInstall strings.Builder
+	postInstall.WriteString(reload)
+	if enable != "" {
+		fmt.Fprintf(&postInstall, "systemctl enable %s >/dev/null 2>&1 || :\n", enable)
+	}
+	if start != "" {
+		fmt.Fprintf(&postInstall, "if %s; then\n\tsystemctl start %s >/dev/null 2>&1 || :\nfi\n", running, start)
+	}
+
+	var postUpgrade strings.Builder
+	postUpgrade.WriteString(reload)
+	if restart != "" {
+		fmt.Fprintf(&postUpgrade, "if %s; then\n\tsystemctl try-restart %s >/dev/null 2>&1 || :\nfi\n", running, restart)
+	}
+
+	var preRemove strings.Builder
+	fmt.Fprintf(&preRemove, "systemctl --no-reload disable %s >/dev/null 2>&1 || :\n", all)
+	fmt.Fprintf(&preRemove, "if %s; then\n\tsystemctl stop %s >/dev/null 2>&1 || :\nfi\n", running, all)
+
+	return Snippets{
+		PostInstall: postInstall.String(),
+		PostUpgrade: postUpgrade.String(),
+		PreRemove:   preRemove.String(),
+		PostRemove:  reload,
+	}
+}
+
+// Names returns the quoted names of the units matching the filter, or of all
+// units if there is none, separated by spaces.
+func Names(units []nfpm.SystemdUnit, filter func(nfpm.SystemdUnit) bool) string {
+	var names []string
+	for _, unit := range units {
+		if filter == nil || filter(unit) {
+			names = append(names, shell.Quote(unit.Name()))
+		}
+	}
+	return strings.Join(names, " ")
+}
@@ -104,7 +104,11 @@ func (*IPK) SetPackagerDefaults(info *nfpm.Info) {
 	// if in the long run we should be more strict about this and error when
 	// not set?
 	if strings.TrimSpace(info.Maintainer) == "" {
-		deprecation.Println("Leaving the 'maintainer' field unset will not be allowed in a future version")
+		deprecation.Warn(deprecation.Warning{
+			Code:    deprecation.Deprecated,
+			Path:    "maintainer",
+			Message: "leaving the field unset will not be allowed in a future version",
+		})
 		info.Maintainer = "Unset Maintainer <unset@localhost>"
 	}
 }
@@ -113,6 +117,7 @@ func (*IPK) SetPackagerDefaults(info *nfpm.Info) {
 func (d *IPK) Package(info *nfpm.Info, ipk io.Writer) error {
 	info = ensureValidArch(info)
 
+	info = nfpm.WithCopyrightIfRequested(info, fmt.Sprintf("/usr/share/doc/%s/copyright", info.Name))
 	if err := nfpm.PrepareForPackager(info, packagerName); err != nil {
 		return err
 	}
@@ -180,11 +185,16 @@ func createIPK(info *nfpm.Info, ipk *tar.Writer) error {
 
 // populateDataTar populates the data tarball with the files specified in the info.
 func populateDataTar(info *nfpm.Info, tw *tar.Writer) (instSize int64, err error) {
+	// hard links are added last as their targets must come first in the tar
+	var hardlinks []*files.Content
+
 	// create files and implicit directories
 	for _, file := range info.Contents {
 		var size int64
 
 		switch file.Type {
+		case files.TypeHardlink:
+			hardlinks = append(hardlinks, file)
 		case files.TypeDir, files.TypeImplicitDir:
 			err = tw.WriteHeader(
 				&tar.Header{
@@ -195,6 +205,8 @@ func populateDataTar(info *nfpm.Info, tw *tar.Writer) (instSize int64, err error
 					Mode:     int64(file.FileInfo.Mode),
 					Uname:    file.FileInfo.Owner,
 					Gname:    file.FileInfo.Group,
+					Uid:      int(file.FileInfo.UID),
+					Gid:      int(file.FileInfo.GID),
 				})
 		case files.TypeSymlink:
 			err = tw.WriteHeader(
@@ -205,8 +217,29 @@ func populateDataTar(info *nfpm.Info, tw *tar.Writer) (instSize int64, err error
 					ModTime:  modtime.Get(info.MTime),
 					Linkname: file.Source,
 				})
+		case files.TypeCharDevice, files.TypeBlockDevice, files.TypeFIFO:
+			err = tw.WriteHeader(
+				&tar.Header{
+					Name:     files.AsExplicitRelativePath(file.Destination),
+					Typeflag: files.SpecialFileTypeflag(file),
+					Format:   tar.FormatGNU,
+					ModTime:  modtime.Get(info.MTime),
+					Mode:     int64(file.FileInfo.Mode),
+					Uname:    file.FileInfo.Owner,
+					Gname:    file.FileInfo.Group,
+					Uid:      int(file.FileInfo.UID),
+					Gid:      int(file.FileInfo.GID),
+					Devmajor: int64(file.FileInfo.DevMajor),
+					Devminor: int64(file.FileInfo.DevMinor),
+				})
 		case files.TypeFile, files.TypeTree, files.TypeConfig, files.TypeConfigNoReplace, files.TypeConfigMissingOK:
 			size, err = writeFile(tw, file)
+		case files.TypeCopyright:
+			var copyright []byte
+			if copyright, err = info.FormatCopyright(); err == nil {
+				size = int64(len(copyright))
+				err = writeToFile(tw, file.Destination, copyright, modtime.Get(info.MTime))
+			}
 		default:
 			// ignore everything else
 		}
@@ -216,6 +249,25 @@ func populateDataTar(info *nfpm.Info, tw *tar.Writer) (instSize int64, err error
 		instSize += size
 	}
 
+	for _, file := range hardlinks {
+		err = tw.WriteHeader(
+			&tar.Header{
+				Name:     files.AsExplicitRelativePath(file.Destination),
+				Typeflag: tar.TypeLink,
+				Format:   tar.FormatGNU,
+				ModTime:  modtime.Get(info.MTime),
+				Mode:     int64(file.FileInfo.Mode),
+				Uname:    file.FileInfo.Owner,
+				Gname:    file.FileInfo.Group,
+				Uid:      int(file.FileInfo.UID),
+				Gid:      int(file.FileInfo.GID),
+				Linkname: files.AsExplicitRelativePath(file.Source),
+			})
+		if err != nil {
+			return 0, err
+		}
+	}
+
 	return instSize, nil
 }
 
@@ -414,8 +466,8 @@ Version: {{ if .Info.Epoch}}{{ .Info.Epoch }}:{{ end }}{{.Info.Version}}
 {{- if .Info.IPK.ABIVersion}}
 ABIVersion: {{.Info.IPK.ABIVersion}}
 {{- end}}
-{{- if .Info.IPK.Alternatives}}
-Alternatives: {{ range $index, $element := .Info.IPK.Alternatives }}{{ if $index }}, {{end}}{{ $element.Priority }}:{{ $element.LinkName}}:{{ $element.Target}}{{- end }}
+{{- with alternatives .Info}}
+Alternatives: {{ range $index, $element := . }}{{ if $index }}, {{end}}{{ $element.Priority }}:{{ $element.LinkName}}:{{ $element.Target}}{{- end }}
 {{- end}}
 {{- if .Info.IPK.AutoInstalled}}
 Auto-Installed: yes
@@ -474,9 +526,24 @@ type controlData struct {
 	InstalledSize int64
 }
 
+// alternatives returns the ipk specific alternatives along with the format
+// neutral ones.
+func alternatives(info *nfpm.Info) []nfpm.IPKAlternative {
+	result := append([]nfpm.IPKAlternative{}, info.IPK.Alternatives...)
+	for _, alternative := range info.Alternatives {
+		result = append(result, nfpm.IPKAlternative{
+			Priority: alternative.Priority,
+			Target:   alternative.Target,
+			LinkName: alternative.LinkName,
+		})
+	}
+	return result
+}
+
 func renderControl(w io.Writer, data controlData) error {
 	tmpl := template.New("control")
 	tmpl.Funcs(template.FuncMap{
+		"alternatives": alternatives,
 		"join": func(strs []string) string {
 			return strings.Trim(strings.Join(strs, ", "), " ")
 		},
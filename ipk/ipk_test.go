@@ -15,6 +15,7 @@ import (
 
 	"github.com/goreleaser/nfpm/v2"
 	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/sign"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -262,6 +263,20 @@ func TestControl(t *testing.T) {
 	require.Equal(t, string(bts), w.String())
 }
 
+func TestControlAlternatives(t *testing.T) {
+	info := exampleInfo()
+	info.IPK.Alternatives = []nfpm.IPKAlternative{
+		{Priority: 100, LinkName: "/bin/sh", Target: "/bin/bash"},
+	}
+	info.Alternatives = []nfpm.Alternative{
+		{Priority: 1700, LinkName: "/usr/bin/java", Target: "/usr/lib/jvm/java-17/bin/java"},
+	}
+
+	var w bytes.Buffer
+	require.NoError(t, renderControl(&w, controlData{Info: info}))
+	require.Contains(t, w.String(), "\nAlternatives: 100:/bin/sh:/bin/bash, 1700:/usr/bin/java:/usr/lib/jvm/java-17/bin/java\n")
+}
+
 func TestNoJoinsControl(t *testing.T) {
 	var w bytes.Buffer
 	require.NoError(t, renderControl(&w, controlData{
@@ -573,6 +588,46 @@ func TestSymlink(t *testing.T) {
 	require.Equal(t, symlinkTarget, packagedSymlinkHeader.Linkname)
 }
 
+func TestCopyright(t *testing.T) {
+	info := exampleInfo()
+	info.Copyright.Files = []nfpm.CopyrightFiles{{
+		Paths:     []string{"*"},
+		Copyright: []string{"2023 Carlos A Becker"},
+		License:   "MIT",
+	}}
+	info = nfpm.WithCopyrightIfRequested(info, "/usr/share/doc/foo/copyright")
+	require.NoError(t, nfpm.PrepareForPackager(info, packagerName))
+
+	var buf bytes.Buffer
+	tarball := tar.NewWriter(&buf)
+	_, err := populateDataTar(info, tarball)
+	require.NoError(t, err)
+	require.NoError(t, tarball.Close())
+
+	expected, err := info.FormatCopyright()
+	require.NoError(t, err)
+	require.Equal(t, expected, extractFileFromTar(t, buf.Bytes(), "/usr/share/doc/foo/copyright"))
+}
+
+func TestInlineContent(t *testing.T) {
+	info := exampleInfo()
+	info.Contents = append(info.Contents, &files.Content{
+		Destination: "/etc/default/foo",
+		Type:        files.TypeConfig,
+		Inline:      "FOO=bar\n",
+	})
+	require.NoError(t, nfpm.PrepareForPackager(info, packagerName))
+
+	var buf bytes.Buffer
+	tarball := tar.NewWriter(&buf)
+	_, err := populateDataTar(info, tarball)
+	require.NoError(t, err)
+	require.NoError(t, tarball.Close())
+
+	require.Equal(t, "FOO=bar\n", string(extractFileFromTar(t, buf.Bytes(), "/etc/default/foo")))
+	require.Contains(t, string(conffiles(info)), "/etc/default/foo\n")
+}
+
 func TestEnsureRelativePrefixInTarballs(t *testing.T) {
 	info := exampleInfo()
 	info.Contents = []*files.Content{
@@ -1038,3 +1093,22 @@ func Test_stripDisallowedFields(t *testing.T) {
 		})
 	}
 }
+
+func TestSignDetached(t *testing.T) {
+	info := exampleInfo()
+
+	var pkg bytes.Buffer
+	require.NoError(t, Default.Package(info, &pkg))
+	require.ErrorIs(t, Default.SignDetached(info, bytes.NewReader(pkg.Bytes()), io.Discard), nfpm.ErrNoSigningKey)
+
+	info.IPK.Signature.KeyFile = "../internal/sign/testdata/privkey.asc"
+	info.IPK.Signature.KeyPassphrase = "hunter2"
+
+	var sig bytes.Buffer
+	require.NoError(t, Default.SignDetached(info, bytes.NewReader(pkg.Bytes()), &sig))
+	require.NoError(t, sign.PGPVerify(bytes.NewReader(pkg.Bytes()), sig.Bytes(), "../internal/sign/testdata/pubkey.asc"))
+
+	info.IPK.Signature.KeyPassphrase = "wrongpass"
+	var expectedError *nfpm.ErrSigningFailure
+	require.ErrorAs(t, Default.SignDetached(info, bytes.NewReader(pkg.Bytes()), io.Discard), &expectedError)
+}
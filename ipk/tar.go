@@ -6,7 +6,6 @@ import (
 	"compress/gzip"
 	"fmt"
 	"io"
-	"os"
 	"time"
 
 	"github.com/goreleaser/nfpm/v2/files"
@@ -43,7 +42,7 @@ func newTGZ(name string, populate func(*tar.Writer) error) ([]byte, error) {
 
 // writeFile writes a file from the filesystem to the tarball.
 func writeFile(out *tar.Writer, file *files.Content) (int64, error) {
-	f, err := os.OpenFile(file.Source, os.O_RDONLY, 0o600) //nolint:gosec
+	f, err := file.Open()
 	if err != nil {
 		return 0, fmt.Errorf("could not open file %s to read and include in the archive: %w", file.Source, err)
 	}
@@ -69,6 +68,8 @@ func writeFile(out *tar.Writer, file *files.Content) (int64, error) {
 	header.Size = size
 	header.Uname = file.FileInfo.Owner
 	header.Gname = file.FileInfo.Group
+	header.Uid = int(file.FileInfo.UID)
+	header.Gid = int(file.FileInfo.GID)
 	if err := out.WriteHeader(header); err != nil {
 		return 0, fmt.Errorf("cannot write tar header for file %s to archive: %w", file.Source, err)
 	}
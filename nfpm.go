@@ -7,18 +7,26 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"net/mail"
 	"os"
+	"path"
+	"path/filepath"
+	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	"dario.cat/mergo"
 	"github.com/AlekSi/pointer"
 	"github.com/Masterminds/semver/v3"
 	"github.com/goreleaser/chglog"
+	"github.com/goreleaser/nfpm/v2/deprecation"
 	"github.com/goreleaser/nfpm/v2/files"
 	"github.com/goreleaser/nfpm/v2/internal/modtime"
+	"github.com/goreleaser/nfpm/v2/internal/secret"
 	"gopkg.in/yaml.v3"
 )
 
@@ -94,6 +102,11 @@ func ParseWithEnvMapping(in io.Reader, mapping func(string) string) (config Conf
 	}
 
 	config.expandEnvVars()
+	if err = config.readPassphraseFiles(); err != nil {
+		return
+	}
+	config.prerelease = config.Info.Prerelease
+	config.versionMetadata = config.Info.VersionMetadata
 	WithDefaults(&config.Info)
 	return config, nil
 }
@@ -128,16 +141,65 @@ type PackagerWithExtension interface {
 	ConventionalExtension() string
 }
 
+// Signer is implemented by packagers that can sign packages that were built
+// before, without building them again.
+type Signer interface {
+	// Sign adds or replaces the signature of the package read from r and
+	// writes the signed package to w.
+	Sign(info *Info, r io.Reader, w io.Writer) error
+}
+
+// DetachedSigner is implemented by packagers that keep the signature in a
+// file next to the package.
+type DetachedSigner interface {
+	// SignDetached writes the signature of the package read from r to w.
+	SignDetached(info *Info, r io.Reader, w io.Writer) error
+}
+
+// AdditionalFilesWriter is implemented by packagers that write files next to
+// the package, like the tarballs of debian source packages.
+type AdditionalFilesWriter interface {
+	// AdditionalFiles returns the paths of the files written next to
+	// info.Target.
+	AdditionalFiles(info *Info) ([]string, error)
+}
+
 // Config contains the top level configuration for packages.
 type Config struct {
 	Info           `yaml:",inline" json:",inline"`
 	Overrides      map[string]*Overridables `yaml:"overrides,omitempty" json:"overrides,omitempty" jsonschema:"title=overrides,description=override some fields when packaging with a specific packager,enum=apk,enum=deb,enum=rpm"`
+	Arches         []string                 `yaml:"arches,omitempty" json:"arches,omitempty" jsonschema:"title=architectures,description=create a package for each of the architectures instead of the one in arch,example=amd64,example=arm64"`
 	envMappingFunc func(string) string
+	// the prerelease and version metadata as configured, before they were
+	// extracted from the version
+	prerelease      string
+	versionMetadata string
 }
 
 // Get returns the Info struct for the given packager format. Overrides
 // for the given format are merged into the final struct.
 func (c *Config) Get(format string) (info *Info, err error) {
+	return c.GetForArch(format, "")
+}
+
+// GetForArch is like Get, but also sets the architecture of the package, if
+// the given one is not empty. Templates in the sources of the contents, like
+// "dist/app_linux_{{.Arch}}/app", are rendered with the architecture.
+func (c *Config) GetForArch(format, arch string) (*Info, error) {
+	info, err := c.get(format)
+	if err != nil {
+		return nil, err
+	}
+	if arch != "" {
+		info.Arch = arch
+	}
+	if err := renderSources(info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func (c *Config) get(format string) (info *Info, err error) {
 	info = &Info{}
 	// make a deep copy of info
 	if err = mergo.Merge(info, c.Info, mergo.WithOverride); err != nil {
@@ -162,10 +224,45 @@ func (c *Config) Get(format string) (info *Info, err error) {
 	return info, nil
 }
 
+// renderSources renders the templates in the sources of the contents. The
+// contents are shared with the config, so the rendered ones are copies.
+func renderSources(info *Info) error {
+	contents := make(files.Contents, 0, len(info.Contents))
+	for _, content := range info.Contents {
+		if !strings.Contains(content.Source, "{{") {
+			contents = append(contents, content)
+			continue
+		}
+		tmpl, err := template.New("src").Option("missingkey=error").Parse(content.Source)
+		if err != nil {
+			return fmt.Errorf("invalid template in source %q: %w", content.Source, err)
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, struct{ Arch string }{info.Arch}); err != nil {
+			return fmt.Errorf("failed to render source %q: %w", content.Source, err)
+		}
+		rendered := *content
+		rendered.Source = b.String()
+		contents = append(contents, &rendered)
+	}
+	info.Contents = contents
+	return nil
+}
+
 // Validate ensures that the config is well typed.
 func (c *Config) Validate() error {
-	if err := Validate(&c.Info); err != nil {
-		return err
+	arches := c.Arches
+	if len(arches) == 0 {
+		arches = []string{""}
+	}
+	for _, arch := range arches {
+		info, err := c.GetForArch("", arch)
+		if err != nil {
+			return err
+		}
+		if err := Validate(info); err != nil {
+			return err
+		}
 	}
 	for format := range c.Overrides {
 		if _, err := Get(format); err != nil {
@@ -175,6 +272,156 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// Set sets the field at the given path to the value, which is decoded as
+// YAML unless the field is a string. The path is made of the config keys
+// separated by dots, with indices for lists, e.g. "deb.compression" or
+// "overrides.rpm.depends[0]". An index one past the end of a list appends
+// to it. Values are not expanded with the environment.
+func (c *Config) Set(path, value string) error {
+	if err := set(reflect.ValueOf(c).Elem(), strings.Split(path, "."), value); err != nil {
+		return fmt.Errorf("failed to set %s: %w", path, err)
+	}
+
+	switch path {
+	case "version":
+		// extract the prerelease and metadata from the new version instead
+		c.Info.Prerelease = c.prerelease
+		c.Info.VersionMetadata = c.versionMetadata
+	case "prerelease":
+		c.prerelease = c.Info.Prerelease
+	case "version_metadata":
+		c.versionMetadata = c.Info.VersionMetadata
+	}
+	WithDefaults(&c.Info)
+	return c.readPassphraseFiles()
+}
+
+func set(v reflect.Value, keys []string, value string) error {
+	v = deref(v)
+	if len(keys) == 0 {
+		if v.Kind() == reflect.String {
+			v.SetString(value)
+			return nil
+		}
+		return yaml.Unmarshal([]byte(value), v.Addr().Interface())
+	}
+
+	name, indices, err := parseKey(keys[0])
+	if err != nil {
+		return err
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		if f, ok := structField(v, name); ok {
+			return setElement(f, indices, keys[1:], value)
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			break
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		key := reflect.ValueOf(name).Convert(v.Type().Key())
+		// map entries are not addressable, so a copy is set and stored
+		entry := reflect.New(v.Type().Elem()).Elem()
+		if current := v.MapIndex(key); current.IsValid() {
+			entry.Set(current)
+		}
+		if err := setElement(entry, indices, keys[1:], value); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, entry)
+		return nil
+	}
+	return fmt.Errorf("unknown field %q", name)
+}
+
+// setElement sets the value in the list element at the given indices.
+func setElement(v reflect.Value, indices []int, keys []string, value string) error {
+	for _, index := range indices {
+		v = deref(v)
+		if v.Kind() != reflect.Slice {
+			return fmt.Errorf("%s is not a list", v.Type())
+		}
+		switch {
+		case index == v.Len():
+			v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+		case index > v.Len():
+			return fmt.Errorf("index %d out of range, the list has %d elements", index, v.Len())
+		}
+		v = v.Index(index)
+	}
+	return set(v, keys, value)
+}
+
+// parseKey splits a path key like "depends[0]" into its name and indices.
+func parseKey(key string) (string, []int, error) {
+	name, rest, found := strings.Cut(key, "[")
+	if name == "" || strings.Contains(name, "]") {
+		return "", nil, fmt.Errorf("invalid key %q", key)
+	}
+	var indices []int
+	for found {
+		var index string
+		index, rest, found = strings.Cut(rest, "]")
+		if !found {
+			return "", nil, fmt.Errorf("invalid key %q", key)
+		}
+		i, err := strconv.Atoi(index)
+		if err != nil || i < 0 {
+			return "", nil, fmt.Errorf("invalid index %q in key %q", index, key)
+		}
+		indices = append(indices, i)
+		if rest == "" {
+			break
+		}
+		if rest, found = strings.CutPrefix(rest, "["); !found {
+			return "", nil, fmt.Errorf("invalid key %q", key)
+		}
+	}
+	return name, indices, nil
+}
+
+// deref allocates and follows pointers until it reaches a non pointer value.
+func deref(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// structField returns the field with the given YAML name, looking into
+// inlined structs as well.
+func structField(v reflect.Value, name string) (reflect.Value, bool) {
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tag, opts, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+		if tag == "-" {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			if f, ok := structField(deref(v.Field(i)), name); ok {
+				return f, true
+			}
+			continue
+		}
+		if tag == "" {
+			tag = strings.ToLower(sf.Name)
+		}
+		if tag == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
 func (c *Config) expandEnvVarsStringSlice(items []string) []string {
 	for i, dep := range items {
 		val := strings.TrimSpace(os.Expand(dep, c.envMappingFunc))
@@ -198,10 +445,58 @@ func (c *Config) expandEnvVarsContents(contents files.Contents) files.Contents {
 		}
 		f.Destination = strings.TrimSpace(os.Expand(f.Destination, c.envMappingFunc))
 		f.Source = strings.TrimSpace(os.Expand(f.Source, c.envMappingFunc))
+		f.Inline = os.Expand(f.Inline, c.envMappingFunc)
 	}
 	return contents
 }
 
+func (c *Config) expandSigningKey(key *PackageSignature, passphrase string) {
+	key.KeyFile = os.Expand(key.KeyFile, c.envMappingFunc)
+	key.PassphraseFile = os.Expand(key.PassphraseFile, c.envMappingFunc)
+	if key.KeyID != nil {
+		key.KeyID = pointer.ToString(os.Expand(*key.KeyID, c.envMappingFunc))
+	}
+	key.Command = c.expandEnvVarsStringSlice(key.Command)
+	if key.PKCS11 != nil {
+		key.PKCS11.Module = os.Expand(key.PKCS11.Module, c.envMappingFunc)
+		key.PKCS11.PublicKeyFile = os.Expand(key.PKCS11.PublicKeyFile, c.envMappingFunc)
+	}
+	key.KeyPassphrase = passphrase
+}
+
+// readPassphraseFiles reads the passphrases of the signatures that set a
+// passphrase file, which take precedence over the environment variables.
+// Additional keys without a passphrase file use the one of their signature.
+func (c *Config) readPassphraseFiles() error {
+	for _, sig := range []*PackageSignature{
+		&c.Info.Deb.Signature.PackageSignature,
+		&c.Info.RPM.Signature.PackageSignature,
+		&c.Info.APK.Signature.PackageSignature,
+		&c.Info.ArchLinux.Signature,
+		&c.Info.IPK.Signature,
+	} {
+		if err := sig.readPassphraseFile(); err != nil {
+			return err
+		}
+	}
+	for i := range c.Info.Deb.Signature.Keys {
+		if err := c.Info.Deb.Signature.Keys[i].readPassphraseFileOr(c.Info.Deb.Signature.KeyPassphrase); err != nil {
+			return err
+		}
+	}
+	for i := range c.Info.RPM.Signature.Keys {
+		if err := c.Info.RPM.Signature.Keys[i].readPassphraseFileOr(c.Info.RPM.Signature.KeyPassphrase); err != nil {
+			return err
+		}
+	}
+	for i := range c.Info.APK.Signature.Keys {
+		if err := c.Info.APK.Signature.Keys[i].readPassphraseFileOr(c.Info.APK.Signature.KeyPassphrase); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *Config) expandEnvVars() {
 	// Version related fields
 	c.Info.Release = os.Expand(c.Info.Release, c.envMappingFunc)
@@ -209,6 +504,7 @@ func (c *Config) expandEnvVars() {
 	c.Info.Prerelease = os.Expand(c.Info.Prerelease, c.envMappingFunc)
 	c.Info.Platform = os.Expand(c.Info.Platform, c.envMappingFunc)
 	c.Info.Arch = os.Expand(c.Info.Arch, c.envMappingFunc)
+	c.Arches = c.expandEnvVarsStringSlice(c.Arches)
 	for or := range c.Overrides {
 		c.Overrides[or].Conflicts = c.expandEnvVarsStringSlice(c.Overrides[or].Conflicts)
 		c.Overrides[or].Depends = c.expandEnvVarsStringSlice(c.Overrides[or].Depends)
@@ -237,15 +533,43 @@ func (c *Config) expandEnvVars() {
 	c.Info.Deb.Signature.KeyFile = os.Expand(c.Deb.Signature.KeyFile, c.envMappingFunc)
 	c.Info.RPM.Signature.KeyFile = os.Expand(c.RPM.Signature.KeyFile, c.envMappingFunc)
 	c.Info.APK.Signature.KeyFile = os.Expand(c.APK.Signature.KeyFile, c.envMappingFunc)
+	c.Info.ArchLinux.Signature.KeyFile = os.Expand(c.ArchLinux.Signature.KeyFile, c.envMappingFunc)
+	c.Info.IPK.Signature.KeyFile = os.Expand(c.IPK.Signature.KeyFile, c.envMappingFunc)
+	c.Info.Deb.Signature.PassphraseFile = os.Expand(c.Deb.Signature.PassphraseFile, c.envMappingFunc)
+	c.Info.RPM.Signature.PassphraseFile = os.Expand(c.RPM.Signature.PassphraseFile, c.envMappingFunc)
+	c.Info.APK.Signature.PassphraseFile = os.Expand(c.APK.Signature.PassphraseFile, c.envMappingFunc)
+	c.Info.ArchLinux.Signature.PassphraseFile = os.Expand(c.ArchLinux.Signature.PassphraseFile, c.envMappingFunc)
+	c.Info.IPK.Signature.PassphraseFile = os.Expand(c.IPK.Signature.PassphraseFile, c.envMappingFunc)
 	c.Info.Deb.Signature.KeyID = pointer.ToString(os.Expand(pointer.GetString(c.Deb.Signature.KeyID), c.envMappingFunc))
 	c.Info.RPM.Signature.KeyID = pointer.ToString(os.Expand(pointer.GetString(c.RPM.Signature.KeyID), c.envMappingFunc))
 	c.Info.APK.Signature.KeyID = pointer.ToString(os.Expand(pointer.GetString(c.APK.Signature.KeyID), c.envMappingFunc))
+	c.Info.ArchLinux.Signature.KeyID = pointer.ToString(os.Expand(pointer.GetString(c.ArchLinux.Signature.KeyID), c.envMappingFunc))
+	c.Info.IPK.Signature.KeyID = pointer.ToString(os.Expand(pointer.GetString(c.IPK.Signature.KeyID), c.envMappingFunc))
+	c.Info.Deb.Signature.Command = c.expandEnvVarsStringSlice(c.Deb.Signature.Command)
+	c.Info.RPM.Signature.Command = c.expandEnvVarsStringSlice(c.RPM.Signature.Command)
+	c.Info.APK.Signature.Command = c.expandEnvVarsStringSlice(c.APK.Signature.Command)
+	c.Info.ArchLinux.Signature.Command = c.expandEnvVarsStringSlice(c.ArchLinux.Signature.Command)
+	c.Info.IPK.Signature.Command = c.expandEnvVarsStringSlice(c.IPK.Signature.Command)
+	for _, p := range []*PKCS11{
+		c.Deb.Signature.PKCS11,
+		c.RPM.Signature.PKCS11,
+		c.APK.Signature.PKCS11,
+		c.ArchLinux.Signature.PKCS11,
+		c.IPK.Signature.PKCS11,
+	} {
+		if p != nil {
+			p.Module = os.Expand(p.Module, c.envMappingFunc)
+			p.PublicKeyFile = os.Expand(p.PublicKeyFile, c.envMappingFunc)
+		}
+	}
 
 	// Package signing passphrase
 	generalPassphrase := os.Expand("$NFPM_PASSPHRASE", c.envMappingFunc)
 	c.Info.Deb.Signature.KeyPassphrase = generalPassphrase
 	c.Info.RPM.Signature.KeyPassphrase = generalPassphrase
 	c.Info.APK.Signature.KeyPassphrase = generalPassphrase
+	c.Info.ArchLinux.Signature.KeyPassphrase = generalPassphrase
+	c.Info.IPK.Signature.KeyPassphrase = generalPassphrase
 
 	debPassphrase := os.Expand("$NFPM_DEB_PASSPHRASE", c.envMappingFunc)
 	if debPassphrase != "" {
@@ -262,6 +586,27 @@ func (c *Config) expandEnvVars() {
 		c.Info.APK.Signature.KeyPassphrase = apkPassphrase
 	}
 
+	archlinuxPassphrase := os.Expand("$NFPM_ARCHLINUX_PASSPHRASE", c.envMappingFunc)
+	if archlinuxPassphrase != "" {
+		c.Info.ArchLinux.Signature.KeyPassphrase = archlinuxPassphrase
+	}
+
+	ipkPassphrase := os.Expand("$NFPM_IPK_PASSPHRASE", c.envMappingFunc)
+	if ipkPassphrase != "" {
+		c.Info.IPK.Signature.KeyPassphrase = ipkPassphrase
+	}
+
+	// Additional signing keys use the passphrase of their signature
+	for i := range c.Info.Deb.Signature.Keys {
+		c.expandSigningKey(&c.Info.Deb.Signature.Keys[i].PackageSignature, c.Info.Deb.Signature.KeyPassphrase)
+	}
+	for i := range c.Info.RPM.Signature.Keys {
+		c.expandSigningKey(&c.Info.RPM.Signature.Keys[i], c.Info.RPM.Signature.KeyPassphrase)
+	}
+	for i := range c.Info.APK.Signature.Keys {
+		c.expandSigningKey(&c.Info.APK.Signature.Keys[i].PackageSignature, c.Info.APK.Signature.KeyPassphrase)
+	}
+
 	// RPM specific
 	c.Info.RPM.Packager = os.Expand(c.RPM.Packager, c.envMappingFunc)
 
@@ -284,26 +629,31 @@ func (c *Config) expandEnvVars() {
 // Info contains information about a single package.
 type Info struct {
 	Overridables    `yaml:",inline" json:",inline"`
-	Name            string    `yaml:"name" json:"name" jsonschema:"title=package name"`
-	Arch            string    `yaml:"arch" json:"arch" jsonschema:"title=target architecture,example=amd64"`
-	Platform        string    `yaml:"platform,omitempty" json:"platform,omitempty" jsonschema:"title=target platform,example=linux,default=linux"`
-	Epoch           string    `yaml:"epoch,omitempty" json:"epoch,omitempty" jsonschema:"title=version epoch,example=2,default=extracted from version"`
-	Version         string    `yaml:"version" json:"version" jsonschema:"title=version,example=v1.0.2,example=2.0.1"`
-	VersionSchema   string    `yaml:"version_schema,omitempty" json:"version_schema,omitempty" jsonschema:"title=version schema,enum=semver,enum=none,default=semver"`
-	Release         string    `yaml:"release,omitempty" json:"release,omitempty" jsonschema:"title=version release,example=1"`
-	Prerelease      string    `yaml:"prerelease,omitempty" json:"prerelease,omitempty" jsonschema:"title=version prerelease,default=extracted from version"`
-	VersionMetadata string    `yaml:"version_metadata,omitempty" json:"version_metadata,omitempty" jsonschema:"title=version metadata,example=git"`
-	Section         string    `yaml:"section,omitempty" json:"section,omitempty" jsonschema:"title=package section,example=default"`
-	Priority        string    `yaml:"priority,omitempty" json:"priority,omitempty" jsonschema:"title=package priority,example=extra"`
-	Maintainer      string    `yaml:"maintainer,omitempty" json:"maintainer,omitempty" jsonschema:"title=package maintainer,example=me@example.com"`
-	Description     string    `yaml:"description,omitempty" json:"description,omitempty" jsonschema:"title=package description"`
-	Vendor          string    `yaml:"vendor,omitempty" json:"vendor,omitempty" jsonschema:"title=package vendor,example=MyCorp"`
-	Homepage        string    `yaml:"homepage,omitempty" json:"homepage,omitempty" jsonschema:"title=package homepage,example=https://example.com"`
-	License         string    `yaml:"license,omitempty" json:"license,omitempty" jsonschema:"title=package license,example=MIT"`
-	Changelog       string    `yaml:"changelog,omitempty" json:"changelog,omitempty" jsonschema:"title=package changelog,example=changelog.yaml,description=see https://github.com/goreleaser/chglog for more details"`
-	DisableGlobbing bool      `yaml:"disable_globbing,omitempty" json:"disable_globbing,omitempty" jsonschema:"title=whether to disable file globbing,default=false"`
-	MTime           time.Time `yaml:"mtime,omitempty" json:"mtime,omitempty" jsonschema:"title=time to set into the files generated by nFPM"`
-	Target          string    `yaml:"-" json:"-"`
+	Name            string        `yaml:"name" json:"name" jsonschema:"title=package name"`
+	Arch            string        `yaml:"arch" json:"arch" jsonschema:"title=target architecture,example=amd64"`
+	Platform        string        `yaml:"platform,omitempty" json:"platform,omitempty" jsonschema:"title=target platform,example=linux,default=linux"`
+	Epoch           string        `yaml:"epoch,omitempty" json:"epoch,omitempty" jsonschema:"title=version epoch,example=2,default=extracted from version"`
+	Version         string        `yaml:"version" json:"version" jsonschema:"title=version,example=v1.0.2,example=2.0.1"`
+	VersionSchema   string        `yaml:"version_schema,omitempty" json:"version_schema,omitempty" jsonschema:"title=version schema,enum=semver,enum=none,default=semver"`
+	Release         string        `yaml:"release,omitempty" json:"release,omitempty" jsonschema:"title=version release,example=1"`
+	Prerelease      string        `yaml:"prerelease,omitempty" json:"prerelease,omitempty" jsonschema:"title=version prerelease,default=extracted from version"`
+	VersionMetadata string        `yaml:"version_metadata,omitempty" json:"version_metadata,omitempty" jsonschema:"title=version metadata,example=git"`
+	Section         string        `yaml:"section,omitempty" json:"section,omitempty" jsonschema:"title=package section,example=default"`
+	Priority        string        `yaml:"priority,omitempty" json:"priority,omitempty" jsonschema:"title=package priority,example=extra"`
+	Maintainer      string        `yaml:"maintainer,omitempty" json:"maintainer,omitempty" jsonschema:"title=package maintainer,example=me@example.com"`
+	Description     string        `yaml:"description,omitempty" json:"description,omitempty" jsonschema:"title=package description"`
+	Vendor          string        `yaml:"vendor,omitempty" json:"vendor,omitempty" jsonschema:"title=package vendor,example=MyCorp"`
+	Homepage        string        `yaml:"homepage,omitempty" json:"homepage,omitempty" jsonschema:"title=package homepage,example=https://example.com"`
+	License         string        `yaml:"license,omitempty" json:"license,omitempty" jsonschema:"title=package license,example=MIT"`
+	Copyright       Copyright     `yaml:"copyright,omitempty" json:"copyright,omitempty" jsonschema:"title=machine-readable copyright information"`
+	Changelog       string        `yaml:"changelog,omitempty" json:"changelog,omitempty" jsonschema:"title=package changelog,example=changelog.yaml,description=see https://github.com/goreleaser/chglog for more details"`
+	DisableGlobbing bool          `yaml:"disable_globbing,omitempty" json:"disable_globbing,omitempty" jsonschema:"title=whether to disable file globbing,default=false"`
+	MTime           time.Time     `yaml:"mtime,omitempty" json:"mtime,omitempty" jsonschema:"title=time to set into the files generated by nFPM"`
+	Users           []User        `yaml:"users,omitempty" json:"users,omitempty" jsonschema:"title=system users to create on install"`
+	Groups          []Group       `yaml:"groups,omitempty" json:"groups,omitempty" jsonschema:"title=system groups to create on install"`
+	Systemd         []SystemdUnit `yaml:"systemd,omitempty" json:"systemd,omitempty" jsonschema:"title=systemd units to install and manage"`
+	Alternatives    []Alternative `yaml:"alternatives,omitempty" json:"alternatives,omitempty" jsonschema:"title=alternatives to register on install"`
+	Target          string        `yaml:"-" json:"-"`
 }
 
 func (i *Info) Validate() error {
@@ -344,22 +694,415 @@ func (i *Info) parseSemver() {
 	}
 }
 
+// Copyright describes the copyright holders and licenses of the packaged
+// files. It is rendered in the machine-readable Debian copyright format, see
+// https://www.debian.org/doc/packaging-manuals/copyright-format/1.0/
+type Copyright struct {
+	UpstreamName    string             `yaml:"upstream_name,omitempty" json:"upstream_name,omitempty" jsonschema:"title=upstream name,default=package name"`
+	UpstreamContact string             `yaml:"upstream_contact,omitempty" json:"upstream_contact,omitempty" jsonschema:"title=upstream contact,example=Foo Bar <foo@example.com>"`
+	Source          string             `yaml:"source,omitempty" json:"source,omitempty" jsonschema:"title=where the upstream source can be obtained,default=homepage"`
+	Files           []CopyrightFiles   `yaml:"files,omitempty" json:"files,omitempty" jsonschema:"title=copyright and license of files"`
+	Licenses        []CopyrightLicense `yaml:"licenses,omitempty" json:"licenses,omitempty" jsonschema:"title=full texts of the used licenses"`
+}
+
+// CopyrightFiles declares the copyright holders and license of the files
+// matching the given paths.
+type CopyrightFiles struct {
+	Paths     []string `yaml:"paths" json:"paths" jsonschema:"title=file patterns,example=*"`
+	Copyright []string `yaml:"copyright" json:"copyright" jsonschema:"title=copyright holders,example=2023 Foo Bar"`
+	License   string   `yaml:"license" json:"license" jsonschema:"title=SPDX license expression,example=MIT"`
+	Comment   string   `yaml:"comment,omitempty" json:"comment,omitempty" jsonschema:"title=comment"`
+}
+
+// CopyrightLicense is a stand-alone license paragraph with the full text of
+// a license that is referenced by the files.
+type CopyrightLicense struct {
+	Name string `yaml:"name" json:"name" jsonschema:"title=SPDX license identifier,example=MIT"`
+	File string `yaml:"file" json:"file" jsonschema:"title=file containing the license text,example=LICENSE"`
+}
+
+// FormatCopyright renders the copyright information in the machine-readable
+// Debian copyright format. If no files are configured, a single paragraph
+// for all files is derived from the vendor (or maintainer) and the license.
+func (i *Info) FormatCopyright() ([]byte, error) {
+	c := i.Copyright
+	var b strings.Builder
+	b.WriteString("Format: https://www.debian.org/doc/packaging-manuals/copyright-format/1.0/\n")
+	writeCopyrightField(&b, "Upstream-Name", firstNonEmpty(c.UpstreamName, i.Name))
+	writeCopyrightField(&b, "Upstream-Contact", c.UpstreamContact)
+	writeCopyrightField(&b, "Source", firstNonEmpty(c.Source, i.Homepage))
+
+	paragraphs := c.Files
+	if len(paragraphs) == 0 {
+		holder := firstNonEmpty(i.Vendor, i.Maintainer)
+		if addr, err := mail.ParseAddress(holder); err == nil && addr.Name != "" {
+			holder = addr.Name
+		}
+		paragraphs = []CopyrightFiles{{
+			Paths:     []string{"*"},
+			Copyright: []string{holder},
+			License:   firstNonEmpty(i.License, "unknown"),
+		}}
+	}
+
+	for n, paragraph := range paragraphs {
+		if len(paragraph.Paths) == 0 {
+			return nil, fmt.Errorf("copyright files entry %d: no paths provided", n)
+		}
+		if len(paragraph.Copyright) == 0 || paragraph.License == "" {
+			return nil, fmt.Errorf("copyright files entry %d: copyright and license must be provided", n)
+		}
+		b.WriteString("\n")
+		writeCopyrightField(&b, "Files", strings.Join(paragraph.Paths, " "))
+		writeCopyrightField(&b, "Copyright", strings.Join(paragraph.Copyright, "\n"))
+		writeCopyrightField(&b, "License", paragraph.License)
+		writeCopyrightField(&b, "Comment", paragraph.Comment)
+	}
+
+	for _, license := range c.Licenses {
+		if license.Name == "" {
+			return nil, fmt.Errorf("copyright license %q: no name provided", license.File)
+		}
+		text, err := os.ReadFile(license.File)
+		if err != nil {
+			return nil, fmt.Errorf("copyright license %s: %w", license.Name, err)
+		}
+		b.WriteString("\n")
+		writeCopyrightField(&b, "License", license.Name+"\n"+strings.TrimRight(string(text), "\n"))
+	}
+
+	return []byte(b.String()), nil
+}
+
+// writeCopyrightField writes a possibly multiline field, empty lines of the
+// continuation are replaced by a single dot as required by the format.
+func writeCopyrightField(b *strings.Builder, name, value string) {
+	if value == "" {
+		return
+	}
+	lines := strings.Split(value, "\n")
+	fmt.Fprintf(b, "%s: %s\n", name, lines[0])
+	for _, line := range lines[1:] {
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			line = "."
+		}
+		fmt.Fprintf(b, " %s\n", line)
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// WithCopyrightIfRequested adds the machine-readable copyright file at the
+// given destination to the contents if copyright files are configured and
+// the destination is not already occupied. The content is of type
+// files.TypeCopyright and must be rendered by the packager using
+// Info.FormatCopyright.
+func WithCopyrightIfRequested(info *Info, destination string) *Info {
+	if len(info.Copyright.Files) == 0 || info.Contents.ContainsDestination(destination) {
+		return info
+	}
+
+	info.Contents = append(info.Contents, &files.Content{
+		Destination: destination,
+		Type:        files.TypeCopyright,
+		FileInfo: &files.ContentFileInfo{
+			Mode: 0o644,
+		},
+	})
+
+	return info
+}
+
+// WarnUnsupportedFields emits a deprecation.UnsupportedField warning for
+// each of the given dependency fields that is set in the info, although the
+// packager ignores it. Fields are named as in the config, e.g. "conflicts".
+func WarnUnsupportedFields(info *Info, packager string, fields ...string) {
+	for _, field := range fields {
+		var values []string
+		switch field {
+		case "conflicts":
+			values = info.Conflicts
+		case "recommends":
+			values = info.Recommends
+		case "suggests":
+			values = info.Suggests
+		case "replaces":
+			values = info.Replaces
+		case "provides":
+			values = info.Provides
+		}
+		if len(values) == 0 {
+			continue
+		}
+		deprecation.Warn(deprecation.Warning{
+			Code:    deprecation.UnsupportedField,
+			Path:    field,
+			Message: fmt.Sprintf("%s packages do not support this field, it is ignored", packager),
+		})
+	}
+}
+
+// User is a system user that is created when the package is installed.
+type User struct {
+	Name        string   `yaml:"name" json:"name" jsonschema:"title=user name,example=myapp"`
+	UID         uint32   `yaml:"uid,omitempty" json:"uid,omitempty" jsonschema:"title=user id,description=allocated on install if not set"`
+	Group       string   `yaml:"group,omitempty" json:"group,omitempty" jsonschema:"title=primary group,description=a group with the name of the user is created if not set"`
+	Groups      []string `yaml:"groups,omitempty" json:"groups,omitempty" jsonschema:"title=supplementary groups"`
+	Home        string   `yaml:"home,omitempty" json:"home,omitempty" jsonschema:"title=home directory,example=/var/lib/myapp"`
+	Shell       string   `yaml:"shell,omitempty" json:"shell,omitempty" jsonschema:"title=login shell,example=/usr/sbin/nologin"`
+	Description string   `yaml:"description,omitempty" json:"description,omitempty" jsonschema:"title=user description"`
+}
+
+// Group is a system group that is created when the package is installed.
+type Group struct {
+	Name string `yaml:"name" json:"name" jsonschema:"title=group name,example=myapp"`
+	GID  uint32 `yaml:"gid,omitempty" json:"gid,omitempty" jsonschema:"title=group id,description=allocated on install if not set"`
+}
+
+// PrimaryGroup returns the name of the primary group of the user.
+func (u User) PrimaryGroup() string {
+	return firstNonEmpty(u.Group, u.Name)
+}
+
+// FormatSysusers returns the sysusers.d(5) configuration creating the users
+// and groups of the package.
+func (i *Info) FormatSysusers() []byte {
+	var b strings.Builder
+	for _, group := range i.Groups {
+		fmt.Fprintf(&b, "g %s %s\n", group.Name, sysusersID(group.GID))
+	}
+	for _, user := range i.Users {
+		id := sysusersID(user.UID)
+		if user.Group != "" {
+			id += ":" + user.Group
+		}
+		description := "-"
+		if user.Description != "" {
+			description = strconv.Quote(user.Description)
+		}
+		fmt.Fprintf(&b, "u %s %s %s %s %s\n",
+			user.Name,
+			id,
+			description,
+			firstNonEmpty(user.Home, "-"),
+			firstNonEmpty(user.Shell, "-"),
+		)
+	}
+	for _, user := range i.Users {
+		for _, group := range user.Groups {
+			fmt.Fprintf(&b, "m %s %s\n", user.Name, group)
+		}
+	}
+	return []byte(b.String())
+}
+
+func sysusersID(id uint32) string {
+	if id == 0 {
+		return "-"
+	}
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+// SysusersPath returns the location of the sysusers.d(5) configuration of
+// the package.
+func SysusersPath(info *Info) string {
+	return fmt.Sprintf("/usr/lib/sysusers.d/%s.conf", info.Name)
+}
+
+// withSysusersIfRequested adds the sysusers.d(5) configuration to the
+// contents if users or groups are configured and the destination is not
+// already occupied.
+func withSysusersIfRequested(info *Info) *Info {
+	destination := SysusersPath(info)
+	if len(info.Users) == 0 && len(info.Groups) == 0 ||
+		info.Contents.ContainsDestination(destination) {
+		return info
+	}
+
+	info.Contents = append(info.Contents, &files.Content{
+		Destination: destination,
+		Type:        files.TypeFile,
+		FileInfo: &files.ContentFileInfo{
+			Mode: 0o644,
+		},
+		Data: info.FormatSysusers(),
+	})
+
+	return info
+}
+
+func validateUsers(info *Info) error {
+	for _, group := range info.Groups {
+		if group.Name == "" {
+			return errors.New("groups: name must be provided")
+		}
+	}
+	for _, user := range info.Users {
+		if user.Name == "" {
+			return errors.New("users: name must be provided")
+		}
+	}
+	return nil
+}
+
+// SystemdUnitDir is where the systemd units of a package are installed.
+const SystemdUnitDir = "/usr/lib/systemd/system"
+
+// SystemdUnit is a systemd unit file that is installed along with the
+// maintainer script snippets managing it.
+type SystemdUnit struct {
+	Source           string `yaml:"src" json:"src" jsonschema:"title=path to the unit file,example=foo.service"`
+	Enable           bool   `yaml:"enable,omitempty" json:"enable,omitempty" jsonschema:"title=enable the unit on install,default=false"`
+	Start            bool   `yaml:"start,omitempty" json:"start,omitempty" jsonschema:"title=start the unit on install,default=false"`
+	RestartOnUpgrade bool   `yaml:"restart_on_upgrade,omitempty" json:"restart_on_upgrade,omitempty" jsonschema:"title=restart the unit on upgrade if it is running,default=false"`
+}
+
+// Name returns the name of the unit, which is the name of its file.
+func (u SystemdUnit) Name() string {
+	return filepath.Base(u.Source)
+}
+
+// withSystemdUnits adds the unit files to the contents unless their
+// destination is already occupied.
+func withSystemdUnits(info *Info) *Info {
+	for _, unit := range info.Systemd {
+		destination := path.Join(SystemdUnitDir, unit.Name())
+		if info.Contents.ContainsDestination(destination) {
+			continue
+		}
+		info.Contents = append(info.Contents, &files.Content{
+			Source:      unit.Source,
+			Destination: destination,
+			Type:        files.TypeFile,
+			FileInfo: &files.ContentFileInfo{
+				Mode: 0o644,
+			},
+		})
+	}
+
+	return info
+}
+
+func validateSystemd(info *Info) error {
+	for _, unit := range info.Systemd {
+		if unit.Source == "" {
+			return errors.New("systemd: src must be provided")
+		}
+	}
+	return nil
+}
+
+// Alternative is a candidate for a generic name managed by the alternatives
+// system, e.g. one of the installed versions of /usr/bin/java.
+type Alternative struct {
+	Name     string `yaml:"name,omitempty" json:"name,omitempty" jsonschema:"title=generic name,description=defaults to the base name of the link,example=java"`
+	LinkName string `yaml:"link_name" json:"link_name" jsonschema:"title=link name,example=/usr/bin/java"`
+	Target   string `yaml:"target" json:"target" jsonschema:"title=target,example=/usr/lib/jvm/java-17/bin/java"`
+	Priority int    `yaml:"priority,omitempty" json:"priority,omitempty" jsonschema:"title=priority"`
+}
+
+// GenericName returns the name of the link group of the alternative.
+func (a Alternative) GenericName() string {
+	return firstNonEmpty(a.Name, path.Base(a.LinkName))
+}
+
+func validateAlternatives(info *Info) error {
+	for _, alternative := range info.Alternatives {
+		if alternative.LinkName == "" || alternative.Target == "" {
+			return errors.New("alternatives: link_name and target must be provided")
+		}
+	}
+	return nil
+}
+
+// MergeScript returns the maintainer script at the given path with the
+// generated snippets running before and after it. If there is something to
+// run after it, the script runs in a subshell so that exiting early does not
+// skip it. The script is returned unchanged if there are no snippets and it
+// is nil if there is neither a script nor a snippet. The snippets are shell
+// code, so scripts run by other interpreters cannot be merged.
+func MergeScript(path, before, after string) ([]byte, error) {
+	var script string
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		script = string(data)
+	}
+	if before == "" && after == "" {
+		if path == "" {
+			return nil, nil
+		}
+		return []byte(script), nil
+	}
+
+	interpreter, body := "#!/bin/sh", script
+	if strings.HasPrefix(body, "#!") {
+		interpreter, body, _ = strings.Cut(body, "\n")
+		if !isShell(interpreter) {
+			return nil, fmt.Errorf("%s: cannot add the generated shell snippets to a script run by %q, use a POSIX shell script instead", path, strings.TrimSpace(strings.TrimPrefix(interpreter, "#!")))
+		}
+	}
+	body = strings.TrimRight(body, "\n")
+
+	var b strings.Builder
+	b.WriteString(interpreter + "\n")
+	b.WriteString(before)
+	switch {
+	case strings.TrimSpace(body) == "":
+	case after != "":
+		fmt.Fprintf(&b, "(\n%s\n) || exit $?\n", body)
+	default:
+		b.WriteString(body + "\n")
+	}
+	b.WriteString(after)
+	return []byte(b.String()), nil
+}
+
+// isShell reports whether the shebang line runs a POSIX shell, directly or
+// through env or busybox.
+func isShell(shebang string) bool {
+	fields := strings.Fields(strings.TrimPrefix(shebang, "#!"))
+	for len(fields) > 1 && (path.Base(fields[0]) == "env" || path.Base(fields[0]) == "busybox") {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return false
+	}
+	switch path.Base(fields[0]) {
+	case "sh", "ash", "bash", "dash", "ksh", "mksh", "zsh":
+		return true
+	default:
+		return false
+	}
+}
+
 // Overridables contain the field which are overridable in a package.
 type Overridables struct {
-	Replaces   []string       `yaml:"replaces,omitempty" json:"replaces,omitempty" jsonschema:"title=replaces directive,example=nfpm"`
-	Provides   []string       `yaml:"provides,omitempty" json:"provides,omitempty" jsonschema:"title=provides directive,example=nfpm"`
-	Depends    []string       `yaml:"depends,omitempty" json:"depends,omitempty" jsonschema:"title=depends directive,example=nfpm"`
-	Recommends []string       `yaml:"recommends,omitempty" json:"recommends,omitempty" jsonschema:"title=recommends directive,example=nfpm"`
-	Suggests   []string       `yaml:"suggests,omitempty" json:"suggests,omitempty" jsonschema:"title=suggests directive,example=nfpm"`
-	Conflicts  []string       `yaml:"conflicts,omitempty" json:"conflicts,omitempty" jsonschema:"title=conflicts directive,example=nfpm"`
-	Contents   files.Contents `yaml:"contents,omitempty" json:"contents,omitempty" jsonschema:"title=files to add to the package"`
-	Umask      os.FileMode    `yaml:"umask,omitempty" json:"umask,omitempty" jsonschema:"title=umask for file contents,example=112"`
-	Scripts    Scripts        `yaml:"scripts,omitempty" json:"scripts,omitempty" jsonschema:"title=scripts to execute"`
-	RPM        RPM            `yaml:"rpm,omitempty" json:"rpm,omitempty" jsonschema:"title=rpm-specific settings"`
-	Deb        Deb            `yaml:"deb,omitempty" json:"deb,omitempty" jsonschema:"title=deb-specific settings"`
-	APK        APK            `yaml:"apk,omitempty" json:"apk,omitempty" jsonschema:"title=apk-specific settings"`
-	ArchLinux  ArchLinux      `yaml:"archlinux,omitempty" json:"archlinux,omitempty" jsonschema:"title=archlinux-specific settings"`
-	IPK        IPK            `yaml:"ipk,omitempty" json:"ipk,omitempty" jsonschema:"title=ipk-specific settings"`
+	Replaces   []string         `yaml:"replaces,omitempty" json:"replaces,omitempty" jsonschema:"title=replaces directive,example=nfpm"`
+	Provides   []string         `yaml:"provides,omitempty" json:"provides,omitempty" jsonschema:"title=provides directive,example=nfpm"`
+	Depends    []string         `yaml:"depends,omitempty" json:"depends,omitempty" jsonschema:"title=depends directive,example=nfpm"`
+	Recommends []string         `yaml:"recommends,omitempty" json:"recommends,omitempty" jsonschema:"title=recommends directive,example=nfpm"`
+	Suggests   []string         `yaml:"suggests,omitempty" json:"suggests,omitempty" jsonschema:"title=suggests directive,example=nfpm"`
+	Conflicts  []string         `yaml:"conflicts,omitempty" json:"conflicts,omitempty" jsonschema:"title=conflicts directive,example=nfpm"`
+	Contents   files.Contents   `yaml:"contents,omitempty" json:"contents,omitempty" jsonschema:"title=files to add to the package"`
+	Umask      os.FileMode      `yaml:"umask,omitempty" json:"umask,omitempty" jsonschema:"title=umask for file contents,example=112"`
+	Filesystem files.Filesystem `yaml:"filesystem,omitempty" json:"filesystem,omitempty" jsonschema:"title=directories owned by the base filesystem of the distribution"`
+	Scripts    Scripts          `yaml:"scripts,omitempty" json:"scripts,omitempty" jsonschema:"title=scripts to execute"`
+	RPM        RPM              `yaml:"rpm,omitempty" json:"rpm,omitempty" jsonschema:"title=rpm-specific settings"`
+	Deb        Deb              `yaml:"deb,omitempty" json:"deb,omitempty" jsonschema:"title=deb-specific settings"`
+	APK        APK              `yaml:"apk,omitempty" json:"apk,omitempty" jsonschema:"title=apk-specific settings"`
+	ArchLinux  ArchLinux        `yaml:"archlinux,omitempty" json:"archlinux,omitempty" jsonschema:"title=archlinux-specific settings"`
+	IPK        IPK              `yaml:"ipk,omitempty" json:"ipk,omitempty" jsonschema:"title=ipk-specific settings"`
 }
 
 type ArchLinux struct {
@@ -367,6 +1110,8 @@ type ArchLinux struct {
 	Arch     string           `yaml:"arch,omitempty" json:"arch,omitempty" jsonschema:"title=architecture in archlinux nomenclature"`
 	Packager string           `yaml:"packager,omitempty" json:"packager,omitempty" jsonschema:"title=organization that packaged the software"`
 	Scripts  ArchLinuxScripts `yaml:"scripts,omitempty" json:"scripts,omitempty" jsonschema:"title=archlinux-specific scripts"`
+	// Signature is written next to the package as a detached signature.
+	Signature PackageSignature `yaml:"signature,omitempty" json:"signature,omitempty" jsonschema:"title=detached signature"`
 }
 
 type ArchLinuxScripts struct {
@@ -397,18 +1142,75 @@ type PackageSignature struct {
 	// PGP secret key, can be ASCII-armored
 	KeyFile       string  `yaml:"key_file,omitempty" json:"key_file,omitempty" jsonschema:"title=key file,example=key.gpg"`
 	KeyID         *string `yaml:"key_id,omitempty" json:"key_id,omitempty" jsonschema:"title=key id,example=bc8acdd415bd80b3"`
-	KeyPassphrase string  `yaml:"-" json:"-"` // populated from environment variable
+	KeyPassphrase string  `yaml:"-" json:"-"` // populated from environment variable or PassphraseFile
+	// PassphraseFile, if set, is read instead of the passphrase environment
+	// variables. Like KeyFile, it can be "-" for stdin, "fd:<n>" for an
+	// inherited file descriptor or "env:<name>" for base64 data in an
+	// environment variable.
+	PassphraseFile string `yaml:"passphrase_file,omitempty" json:"passphrase_file,omitempty" jsonschema:"title=passphrase file,example=/run/secrets/passphrase"`
 	// SignFn, if set, will be called with the package-specific data to sign.
 	// For deb and rpm packages, data is the full package content.
-	// For apk packages, data is the SHA1 digest of control tgz.
+	// For apk packages, data is the digest of control tgz, created with the
+	// hash set in apk.signature.digest.
 	//
 	// This allows for signing implementations other than using a local file
 	// (for example using a remote signer like KMS).
 	SignFn func(data io.Reader) ([]byte, error) `yaml:"-" json:"-"` // populated when used as a library
+	// Command, if set, is run with the data to sign on its standard input and
+	// must write the signature to its standard output, in the same format
+	// SignFn returns.
+	Command []string `yaml:"command,omitempty" json:"command,omitempty" jsonschema:"title=signing command,example=gpg --batch --detach-sign"`
+	// PKCS11, if set, signs with a key stored on a PKCS#11 token. The
+	// passphrase is used as the user PIN.
+	PKCS11 *PKCS11 `yaml:"pkcs11,omitempty" json:"pkcs11,omitempty" jsonschema:"title=PKCS#11 key"`
+}
+
+// IsSet reports whether a key file or another signing backend is configured.
+func (s PackageSignature) IsSet() bool {
+	return s.KeyFile != "" || s.SignFn != nil || len(s.Command) > 0 || s.PKCS11 != nil
+}
+
+func (s *PackageSignature) readPassphraseFile() error {
+	if s.PassphraseFile == "" {
+		return nil
+	}
+	passphrase, err := secret.ReadPassphrase(s.PassphraseFile)
+	if err != nil {
+		return fmt.Errorf("failed to read signing passphrase: %w", err)
+	}
+	s.KeyPassphrase = passphrase
+	return nil
+}
+
+func (s *PackageSignature) readPassphraseFileOr(passphrase string) error {
+	s.KeyPassphrase = passphrase
+	return s.readPassphraseFile()
+}
+
+// PKCS11 identifies a key on a PKCS#11 token, like a hardware security
+// module or a smart card.
+type PKCS11 struct {
+	// path to the PKCS#11 module of the token
+	Module string `yaml:"module" json:"module" jsonschema:"title=PKCS#11 module,example=/usr/lib/softhsm/libsofthsm2.so"`
+	// defaults to the first slot with a token, unless TokenLabel is set
+	Slot       *uint  `yaml:"slot,omitempty" json:"slot,omitempty" jsonschema:"title=slot"`
+	TokenLabel string `yaml:"token_label,omitempty" json:"token_label,omitempty" jsonschema:"title=token label"`
+	// label of the private key
+	Label string `yaml:"label" json:"label" jsonschema:"title=key label"`
+	// OpenPGP public key of the token key, needed for OpenPGP signatures
+	PublicKeyFile string `yaml:"public_key_file,omitempty" json:"public_key_file,omitempty" jsonschema:"title=OpenPGP public key file,example=key.pub.asc"`
 }
 
 type RPMSignature struct {
 	PackageSignature `yaml:",inline" json:",inline"`
+	// Keys sign the package as well, for example the old key during a key
+	// rotation.
+	Keys []PackageSignature `yaml:"keys,omitempty" json:"keys,omitempty" jsonschema:"title=additional signing keys"`
+}
+
+// IsSet reports whether a signing key is configured.
+func (s RPMSignature) IsSet() bool {
+	return s.PackageSignature.IsSet() || len(s.Keys) > 0
 }
 
 type APK struct {
@@ -421,6 +1223,22 @@ type APKSignature struct {
 	PackageSignature `yaml:",inline" json:",inline"`
 	// defaults to <maintainer email>.rsa.pub
 	KeyName string `yaml:"key_name,omitempty" json:"key_name,omitempty" jsonschema:"title=key name,example=origin,default=maintainer_email.rsa.pub"`
+	// sha1, sha256 or sha512 (defaults to sha1)
+	Digest string `yaml:"digest,omitempty" json:"digest,omitempty" jsonschema:"title=digest algorithm,enum=sha1,enum=sha256,enum=sha512,default=sha1"`
+	// Keys sign the package as well, for example the old key during a key
+	// rotation.
+	Keys []APKSignatureKey `yaml:"keys,omitempty" json:"keys,omitempty" jsonschema:"title=additional signing keys"`
+}
+
+// IsSet reports whether a signing key is configured.
+func (s APKSignature) IsSet() bool {
+	return s.PackageSignature.IsSet() || len(s.Keys) > 0
+}
+
+type APKSignatureKey struct {
+	PackageSignature `yaml:",inline" json:",inline"`
+	// must differ from the key names of the other keys
+	KeyName string `yaml:"key_name,omitempty" json:"key_name,omitempty" jsonschema:"title=key name,example=origin"`
 }
 
 type APKScripts struct {
@@ -447,6 +1265,20 @@ type DebSignature struct {
 	// origin, maint or archive (defaults to origin)
 	Type   string `yaml:"type,omitempty" json:"type,omitempty" jsonschema:"title=signer role,enum=origin,enum=maint,enum=archive,default=origin"`
 	Signer string `yaml:"signer,omitempty" json:"signer,omitempty" jsonschema:"title=signer"`
+	// Keys sign the package as well, for example the old key during a key
+	// rotation, or another role.
+	Keys []DebSignatureKey `yaml:"keys,omitempty" json:"keys,omitempty" jsonschema:"title=additional signing keys"`
+}
+
+// IsSet reports whether a signing key is configured.
+func (s DebSignature) IsSet() bool {
+	return s.PackageSignature.IsSet() || len(s.Keys) > 0
+}
+
+type DebSignatureKey struct {
+	PackageSignature `yaml:",inline" json:",inline"`
+	// defaults to the type of the signature
+	Type string `yaml:"type,omitempty" json:"type,omitempty" jsonschema:"title=signer role,enum=origin,enum=maint,enum=archive"`
 }
 
 // DebTriggers contains triggers only available for deb packages.
@@ -478,6 +1310,8 @@ type IPK struct {
 	Fields        map[string]string `yaml:"fields,omitempty" json:"fields,omitempty" jsonschema:"title=fields"`
 	Predepends    []string          `yaml:"predepends,omitempty" json:"predepends,omitempty" jsonschema:"title=predepends directive,example=nfpm"`
 	Tags          []string          `yaml:"tags,omitempty" json:"tags,omitempty" jsonschema:"title=tags"`
+	// Signature is written next to the package as a detached signature.
+	Signature PackageSignature `yaml:"signature,omitempty" json:"signature,omitempty" jsonschema:"title=detached signature"`
 }
 
 // IPKAlternative represents an alternative for an IPK package.
@@ -520,13 +1354,24 @@ func PrepareForPackager(info *Info, packager string) (err error) {
 	if info.Version == "" {
 		return ErrFieldEmpty{"version"}
 	}
+	if err := validateUsers(info); err != nil {
+		return err
+	}
+	if err := validateSystemd(info); err != nil {
+		return err
+	}
+	if err := validateAlternatives(info); err != nil {
+		return err
+	}
 
-	info.Contents, err = files.PrepareForPackager(
+	info = withSystemdUnits(withSysusersIfRequested(info))
+	info.Contents, err = files.PrepareForPackagerWithFilesystem(
 		info.Contents,
 		info.Umask,
 		packager,
 		info.DisableGlobbing,
 		info.MTime,
+		info.Filesystem,
 	)
 
 	return err
@@ -544,14 +1389,24 @@ func Validate(info *Info) (err error) {
 	if info.Version == "" {
 		return ErrFieldEmpty{"version"}
 	}
+	if err := validateUsers(info); err != nil {
+		return err
+	}
+	if err := validateSystemd(info); err != nil {
+		return err
+	}
+	if err := validateAlternatives(info); err != nil {
+		return err
+	}
 
 	for packager := range packagers {
-		_, err := files.PrepareForPackager(
+		_, err := files.PrepareForPackagerWithFilesystem(
 			info.Contents,
 			info.Umask,
 			packager,
 			info.DisableGlobbing,
 			info.MTime,
+			info.Filesystem,
 		)
 		if err != nil {
 			return err
@@ -615,3 +1470,7 @@ func (s *ErrSigningFailure) Error() string {
 func (s *ErrSigningFailure) Unwarp() error {
 	return s.Err
 }
+
+// ErrNoSigningKey happens when a package should be signed, but neither a key
+// file nor a signing function is configured.
+var ErrNoSigningKey = errors.New("no signing key configured")
@@ -3,8 +3,10 @@ package nfpm_test
 import (
 	"fmt"
 	"io"
+	"io/fs"
 	"net/mail"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
@@ -311,9 +313,13 @@ func TestParseFile(t *testing.T) {
 	_, err = parseAndValidate("./testdata/doesnotexist.yaml")
 	require.Error(t, err)
 	t.Setenv("RPM_KEY_FILE", "my/rpm/key/file")
+	t.Setenv("OLD_RPM_KEY_FILE", "my/old/rpm/key/file")
+	t.Setenv("NFPM_RPM_PASSPHRASE", "rpm passphrase")
 	t.Setenv("TEST_RELEASE_ENV_VAR", "1234")
 	t.Setenv("TEST_PRERELEASE_ENV_VAR", "beta1")
 	t.Setenv("TEST_DESCRIPTION_ENV_VAR", "description")
+	t.Setenv("SIGNING_KEY", "packager@example.com")
+	t.Setenv("PKCS11_MODULE", "/usr/lib/softhsm/libsofthsm2.so")
 	config, err := parseAndValidate("./testdata/env-fields.yaml")
 	require.NoError(t, err)
 	require.Equal(t, fmt.Sprintf("v%s", os.Getenv("GOROOT")), config.Version)
@@ -321,8 +327,14 @@ func TestParseFile(t *testing.T) {
 	require.Equal(t, "beta1", config.Prerelease)
 	require.Equal(t, "My description", config.Description)
 	require.Equal(t, "my/rpm/key/file", config.RPM.Signature.KeyFile)
+	require.Len(t, config.RPM.Signature.Keys, 1)
+	require.Equal(t, "my/old/rpm/key/file", config.RPM.Signature.Keys[0].KeyFile)
+	require.Equal(t, "rpm passphrase", config.RPM.Signature.Keys[0].KeyPassphrase)
 	require.Equal(t, "hard/coded/file", config.Deb.Signature.KeyFile)
 	require.Equal(t, "", config.APK.Signature.KeyFile)
+	require.Equal(t, []string{"gpg", "--local-user", "packager@example.com", "--detach-sign"}, config.ArchLinux.Signature.Command)
+	require.Equal(t, "/usr/lib/softhsm/libsofthsm2.so", config.IPK.Signature.PKCS11.Module)
+	require.Equal(t, "signing key", config.IPK.Signature.PKCS11.Label)
 }
 
 func TestParseEnhancedFile(t *testing.T) {
@@ -471,6 +483,31 @@ maintainer: '"$GIT_COMMITTER_NAME" <$GIT_COMMITTER_EMAIL>'
 		require.Equal(t, apkPass, info.APK.Signature.KeyPassphrase)
 	})
 
+	t.Run("passphrase file", func(t *testing.T) {
+		t.Setenv("NFPM_PASSPHRASE", globalPass)
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "deb"), []byte(debPass+"\n"), 0o600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "rpm"), []byte(rpmPass), 0o600))
+		t.Setenv("PASSPHRASE_DIR", dir)
+		info, err := nfpm.Parse(strings.NewReader(`---
+name: foo
+deb:
+  signature:
+    passphrase_file: ${PASSPHRASE_DIR}/deb
+    keys:
+      - key_file: old.gpg
+      - key_file: new.gpg
+        passphrase_file: ${PASSPHRASE_DIR}/rpm`))
+		require.NoError(t, err)
+		require.Equal(t, debPass, info.Deb.Signature.KeyPassphrase)
+		require.Equal(t, debPass, info.Deb.Signature.Keys[0].KeyPassphrase)
+		require.Equal(t, rpmPass, info.Deb.Signature.Keys[1].KeyPassphrase)
+		require.Equal(t, globalPass, info.RPM.Signature.KeyPassphrase)
+
+		_, err = nfpm.Parse(strings.NewReader("name: foo\nrpm:\n  signature:\n    passphrase_file: ${PASSPHRASE_DIR}/missing"))
+		require.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
 	t.Run("packager", func(t *testing.T) {
 		t.Setenv("PACKAGER", packager)
 		info, err := nfpm.Parse(strings.NewReader("name: foo\nrpm:\n  packager: $PACKAGER"))
@@ -603,6 +640,348 @@ func TestOverrides(t *testing.T) {
 	})
 }
 
+func TestConfigSet(t *testing.T) {
+	config, err := nfpm.ParseFile("./testdata/overrides.yaml")
+	require.NoError(t, err)
+
+	for path, value := range map[string]string{
+		"version":                    "v2.0.0-beta.1+git",
+		"release":                    "3",
+		"deb.compression":            "zstd",
+		"deb.fields.Bugs":            "https://example.com",
+		"rpm.signature.key_id":       "bc8acdd415bd80b3",
+		"overrides.rpm.depends[0]":   "foo",
+		"overrides.rpm.depends[1]":   "bar",
+		"overrides.ipk.provides":     "[a, b]",
+		"contents[1].dst":            "/deb/other.conf",
+		"contents[0].file_info.mode": "0600",
+		"disable_globbing":           "true",
+	} {
+		require.NoError(t, config.Set(path, value), path)
+	}
+
+	require.Equal(t, "2.0.0", config.Version)
+	require.Equal(t, "beta.1", config.Prerelease)
+	require.Equal(t, "git", config.VersionMetadata)
+	require.Equal(t, "3", config.Release)
+	require.Equal(t, "zstd", config.Deb.Compression)
+	require.Equal(t, map[string]string{"Bugs": "https://example.com"}, config.Deb.Fields)
+	require.Equal(t, "bc8acdd415bd80b3", *config.RPM.Signature.KeyID)
+	require.Equal(t, []string{"foo", "bar"}, config.Overrides["rpm"].Depends)
+	require.Equal(t, []string{"a", "b"}, config.Overrides["ipk"].Provides)
+	require.Equal(t, "/deb/other.conf", config.Contents[1].Destination)
+	require.Equal(t, fs.FileMode(0o600), config.Contents[0].FileInfo.Mode)
+	require.True(t, config.DisableGlobbing)
+
+	require.NoError(t, config.Set("version", "2.1.0"))
+	require.Equal(t, "2.1.0", config.Version)
+	require.Empty(t, config.Prerelease)
+
+	info, err := config.Get("rpm")
+	require.NoError(t, err)
+	require.Equal(t, []string{"foo", "bar"}, info.Depends)
+
+	for path, expected := range map[string]string{
+		"foo":                      `unknown field "foo"`,
+		"name.foo":                 `unknown field "foo"`,
+		"overrides.rpm.depends[5]": "index 5 out of range, the list has 2 elements",
+		"depends[x]":               `invalid index "x"`,
+		"depends[0":                "invalid key",
+		"name[0]":                  "string is not a list",
+		"disable_globbing":         "cannot unmarshal",
+	} {
+		require.ErrorContains(t, config.Set(path, "nope"), expected, path)
+	}
+}
+
+func TestArches(t *testing.T) {
+	dir := t.TempDir()
+	for _, arch := range []string{"amd64", "arm64"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, arch), 0o700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, arch, "app"), []byte(arch), 0o600))
+	}
+	config, err := nfpm.ParseWithEnvMapping(strings.NewReader(fmt.Sprintf(`
+name: foo
+version: 1.0.0
+arches: [amd64, "${ARCH}"]
+contents:
+- src: %s/{{.Arch}}/app
+  dst: /usr/bin/app
+- src: ./testdata/whatever.conf
+  dst: /etc/foo.conf
+`, filepath.ToSlash(dir))), func(s string) string {
+		return map[string]string{"ARCH": "arm64"}[s]
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"amd64", "arm64"}, config.Arches)
+	require.NoError(t, config.Validate())
+
+	for _, arch := range config.Arches {
+		info, err := config.GetForArch("deb", arch)
+		require.NoError(t, err)
+		require.Equal(t, arch, info.Arch)
+		require.Equal(t, filepath.ToSlash(dir)+"/"+arch+"/app", info.Contents[0].Source)
+		require.Same(t, config.Contents[1], info.Contents[1])
+	}
+	require.Equal(t, filepath.ToSlash(dir)+"/{{.Arch}}/app", config.Contents[0].Source)
+
+	info, err := config.Get("deb")
+	require.NoError(t, err)
+	require.Equal(t, "amd64", info.Arch)
+	require.Equal(t, filepath.ToSlash(dir)+"/amd64/app", info.Contents[0].Source)
+
+	config.Contents[0].Source = "{{.Foo}}"
+	_, err = config.Get("deb")
+	require.ErrorContains(t, err, `failed to render source "{{.Foo}}"`)
+	config.Contents[0].Source = "{{.Arch"
+	_, err = config.Get("deb")
+	require.ErrorContains(t, err, `invalid template in source "{{.Arch"`)
+}
+
+func TestInlineContentEnvExpansion(t *testing.T) {
+	config, err := nfpm.ParseWithEnvMapping(strings.NewReader(`
+name: foo
+version: 1.0.0
+arch: amd64
+contents:
+- dst: /etc/default/foo
+  type: config
+  expand: true
+  content: |
+    FOO_ENV=${ENVIRONMENT}
+- dst: /etc/foo/raw
+  content: |
+    FOO_ENV=${ENVIRONMENT}
+`), func(s string) string {
+		return map[string]string{"ENVIRONMENT": "staging"}[s]
+	})
+	require.NoError(t, err)
+	require.NoError(t, config.Validate())
+
+	info, err := config.Get("deb")
+	require.NoError(t, err)
+	require.Equal(t, "FOO_ENV=staging\n", info.Contents[0].Inline)
+	require.Equal(t, "FOO_ENV=${ENVIRONMENT}\n", info.Contents[1].Inline)
+}
+
+func TestFormatCopyright(t *testing.T) {
+	license := filepath.Join(t.TempDir(), "LICENSE")
+	require.NoError(t, os.WriteFile(license, []byte("Permission is hereby granted...\n\nTHE SOFTWARE IS PROVIDED \"AS IS\"\n"), 0o600))
+
+	info := &nfpm.Info{
+		Name:     "foo",
+		Homepage: "https://example.com",
+		Copyright: nfpm.Copyright{
+			UpstreamContact: "Foo Bar <foo@example.com>",
+			Files: []nfpm.CopyrightFiles{
+				{
+					Paths:     []string{"*"},
+					Copyright: []string{"2023 Foo Bar", "2024 Acme Inc."},
+					License:   "MIT",
+				},
+				{
+					Paths:     []string{"usr/share/foo/vendor/*", "usr/share/foo/other/*"},
+					Copyright: []string{"2020 Someone Else"},
+					License:   "Apache-2.0",
+					Comment:   "Bundled dependencies.",
+				},
+			},
+			Licenses: []nfpm.CopyrightLicense{
+				{Name: "MIT", File: license},
+			},
+		},
+	}
+
+	copyright, err := info.FormatCopyright()
+	require.NoError(t, err)
+	require.Equal(t, `Format: https://www.debian.org/doc/packaging-manuals/copyright-format/1.0/
+Upstream-Name: foo
+Upstream-Contact: Foo Bar <foo@example.com>
+Source: https://example.com
+
+Files: *
+Copyright: 2023 Foo Bar
+ 2024 Acme Inc.
+License: MIT
+
+Files: usr/share/foo/vendor/* usr/share/foo/other/*
+Copyright: 2020 Someone Else
+License: Apache-2.0
+Comment: Bundled dependencies.
+
+License: MIT
+ Permission is hereby granted...
+ .
+ THE SOFTWARE IS PROVIDED "AS IS"
+`, string(copyright))
+}
+
+func TestFormatCopyrightDefaults(t *testing.T) {
+	copyright, err := (&nfpm.Info{
+		Name:       "foo",
+		Maintainer: "Foo Bar <foo@example.com>",
+		License:    "MIT",
+	}).FormatCopyright()
+	require.NoError(t, err)
+	require.Equal(t, `Format: https://www.debian.org/doc/packaging-manuals/copyright-format/1.0/
+Upstream-Name: foo
+
+Files: *
+Copyright: Foo Bar
+License: MIT
+`, string(copyright))
+}
+
+func TestFormatCopyrightErrors(t *testing.T) {
+	for name, c := range map[string]nfpm.Copyright{
+		"copyright files entry 0: no paths provided": {
+			Files: []nfpm.CopyrightFiles{{Copyright: []string{"me"}, License: "MIT"}},
+		},
+		"copyright files entry 1: copyright and license must be provided": {
+			Files: []nfpm.CopyrightFiles{
+				{Paths: []string{"*"}, Copyright: []string{"me"}, License: "MIT"},
+				{Paths: []string{"*"}, License: "MIT"},
+			},
+		},
+		`copyright license "LICENSE": no name provided`: {
+			Licenses: []nfpm.CopyrightLicense{{File: "LICENSE"}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := (&nfpm.Info{Name: "foo", Copyright: c}).FormatCopyright()
+			require.EqualError(t, err, name)
+		})
+	}
+
+	_, err := (&nfpm.Info{Name: "foo", Copyright: nfpm.Copyright{
+		Licenses: []nfpm.CopyrightLicense{{Name: "MIT", File: "does-not-exist"}},
+	}}).FormatCopyright()
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestFormatSysusers(t *testing.T) {
+	info := &nfpm.Info{
+		Name: "foo",
+		Groups: []nfpm.Group{
+			{Name: "foo-data", GID: 950},
+			{Name: "foo-admin"},
+		},
+		Users: []nfpm.User{
+			{
+				Name:        "foo",
+				UID:         950,
+				Group:       "foo-data",
+				Groups:      []string{"foo-admin", "adm"},
+				Home:        "/var/lib/foo",
+				Shell:       "/usr/sbin/nologin",
+				Description: "Foo daemon",
+			},
+			{Name: "foo-worker"},
+		},
+	}
+	require.Equal(t, `g foo-data 950
+g foo-admin -
+u foo 950:foo-data "Foo daemon" /var/lib/foo /usr/sbin/nologin
+u foo-worker - - - -
+m foo foo-admin
+m foo adm
+`, string(info.FormatSysusers()))
+
+	require.NoError(t, nfpm.PrepareForPackager(nfpm.WithDefaults(info), "deb"))
+	var sysusers *files.Content
+	for _, content := range info.Contents {
+		if content.Destination == "/usr/lib/sysusers.d/foo.conf" {
+			sysusers = content
+		}
+	}
+	require.NotNil(t, sysusers)
+	require.Equal(t, info.FormatSysusers(), sysusers.Data)
+	require.Equal(t, fs.FileMode(0o644), sysusers.Mode())
+
+	require.EqualError(t, nfpm.PrepareForPackager(&nfpm.Info{
+		Name:    "foo",
+		Arch:    "amd64",
+		Version: "1.0.0",
+		Users:   []nfpm.User{{UID: 950}},
+	}, "deb"), "users: name must be provided")
+}
+
+func TestSystemdUnits(t *testing.T) {
+	info := nfpm.WithDefaults(&nfpm.Info{
+		Name: "foo",
+		Systemd: []nfpm.SystemdUnit{
+			{Source: "./testdata/systemd/foo.service", Enable: true},
+		},
+	})
+	require.NoError(t, nfpm.PrepareForPackager(info, "rpm"))
+
+	var unit *files.Content
+	for _, content := range info.Contents {
+		if content.Destination == "/usr/lib/systemd/system/foo.service" {
+			unit = content
+		}
+	}
+	require.NotNil(t, unit)
+	require.Equal(t, "testdata/systemd/foo.service", unit.Source)
+	require.Equal(t, fs.FileMode(0o644), unit.Mode())
+
+	info.Systemd = append(info.Systemd, nfpm.SystemdUnit{Enable: true})
+	require.EqualError(t, nfpm.Validate(info), "systemd: src must be provided")
+}
+
+func TestAlternatives(t *testing.T) {
+	require.Equal(t, "java", nfpm.Alternative{LinkName: "/usr/bin/java"}.GenericName())
+	require.Equal(t, "java-17", nfpm.Alternative{Name: "java-17", LinkName: "/usr/bin/java"}.GenericName())
+
+	info := nfpm.WithDefaults(&nfpm.Info{
+		Name:         "foo",
+		Alternatives: []nfpm.Alternative{{LinkName: "/usr/bin/java"}},
+	})
+	require.EqualError(t, nfpm.Validate(info), "alternatives: link_name and target must be provided")
+}
+
+func TestMergeScript(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "postinstall.sh")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/bash\necho installed\nexit 0\n"), 0o600))
+
+	merged, err := nfpm.MergeScript("", "", "")
+	require.NoError(t, err)
+	require.Nil(t, merged)
+
+	merged, err = nfpm.MergeScript(script, "", "")
+	require.NoError(t, err)
+	require.Equal(t, "#!/bin/bash\necho installed\nexit 0\n", string(merged))
+
+	merged, err = nfpm.MergeScript("", "echo before\n", "")
+	require.NoError(t, err)
+	require.Equal(t, "#!/bin/sh\necho before\n", string(merged))
+
+	merged, err = nfpm.MergeScript(script, "echo before\n", "")
+	require.NoError(t, err)
+	require.Equal(t, "#!/bin/bash\necho before\necho installed\nexit 0\n", string(merged))
+
+	merged, err = nfpm.MergeScript(script, "echo before\n", "echo after\n")
+	require.NoError(t, err)
+	require.Equal(t, "#!/bin/bash\necho before\n(\necho installed\nexit 0\n) || exit $?\necho after\n", string(merged))
+
+	_, err = nfpm.MergeScript("does-not-exist", "echo before\n", "")
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	env := filepath.Join(t.TempDir(), "postinstall.sh")
+	require.NoError(t, os.WriteFile(env, []byte("#!/usr/bin/env sh\necho installed\n"), 0o600))
+	merged, err = nfpm.MergeScript(env, "echo before\n", "")
+	require.NoError(t, err)
+	require.Equal(t, "#!/usr/bin/env sh\necho before\necho installed\n", string(merged))
+
+	python := filepath.Join(t.TempDir(), "postinstall.py")
+	require.NoError(t, os.WriteFile(python, []byte("#!/usr/bin/env python3\nprint('installed')\n"), 0o600))
+	merged, err = nfpm.MergeScript(python, "", "")
+	require.NoError(t, err)
+	require.Equal(t, "#!/usr/bin/env python3\nprint('installed')\n", string(merged))
+	_, err = nfpm.MergeScript(python, "echo before\n", "")
+	require.EqualError(t, err, python+`: cannot add the generated shell snippets to a script run by "/usr/bin/env python3", use a POSIX shell script instead`)
+}
+
 type fakePackager struct{}
 
 func (*fakePackager) ConventionalFileName(_ *nfpm.Info) string {
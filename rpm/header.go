@@ -0,0 +1,205 @@
+package rpm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// rpmpack does not allow to leave out tags it always writes, so source RPMs,
+// which must not have a SOURCERPM tag, are written with this minimal header
+// implementation instead.
+// See https://rpm-software-management.github.io/rpm/manual/format.html
+const (
+	headerTypeChar        = 1
+	headerTypeInt8        = 2
+	headerTypeInt16       = 3
+	headerTypeInt32       = 4
+	headerTypeInt64       = 5
+	headerTypeString      = 6
+	headerTypeBinary      = 7
+	headerTypeStringArray = 8
+	headerTypeI18NString  = 9
+
+	// regionSignatures is the region tag of signature headers.
+	regionSignatures = 62
+	// regionImmutable is the region tag of main headers.
+	regionImmutable = 63
+)
+
+type headerEntry struct {
+	typ   int32
+	count int32
+	data  []byte
+}
+
+// header is a RPM header structure, used for both the signature and the
+// main header.
+type header struct {
+	region  int32
+	entries map[int32]headerEntry
+}
+
+func newHeader(region int32) *header {
+	return &header{region: region, entries: map[int32]headerEntry{}}
+}
+
+func (h *header) addInt16(tag int32, values ...uint16) {
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.BigEndian, values)
+	h.entries[tag] = headerEntry{headerTypeInt16, int32(len(values)), buf.Bytes()}
+}
+
+func (h *header) addInt32(tag int32, values ...int32) {
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.BigEndian, values)
+	h.entries[tag] = headerEntry{headerTypeInt32, int32(len(values)), buf.Bytes()}
+}
+
+func (h *header) addString(tag int32, value string) {
+	h.entries[tag] = headerEntry{headerTypeString, 1, append([]byte(value), 0)}
+}
+
+func (h *header) addStrings(tag int32, values ...string) {
+	data := []byte(strings.Join(values, "\x00"))
+	h.entries[tag] = headerEntry{headerTypeStringArray, int32(len(values)), append(data, 0)}
+}
+
+func (h *header) addBinary(tag int32, value []byte) {
+	h.entries[tag] = headerEntry{headerTypeBinary, int32(len(value)), value}
+}
+
+// Bytes serializes the header including its region trailer.
+func (h *header) Bytes() []byte {
+	tags := make([]int32, 0, len(h.entries))
+	for tag := range h.entries {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+
+	var store bytes.Buffer
+	offsets := make([]int32, len(tags))
+	for i, tag := range tags {
+		entry := h.entries[tag]
+		// integers have to be aligned to their size
+		align := map[int32]int{headerTypeInt16: 2, headerTypeInt32: 4, headerTypeInt64: 8}[entry.typ]
+		if align > 0 && store.Len()%align != 0 {
+			store.Write(make([]byte, align-store.Len()%align))
+		}
+		offsets[i] = int32(store.Len())
+		store.Write(entry.data)
+	}
+
+	count := int32(len(tags) + 1)
+	trailerOffset := int32(store.Len())
+	writeIndex(&store, h.region, headerTypeBinary, -count*16, 16)
+
+	var buf bytes.Buffer
+	buf.Write([]byte{0x8e, 0xad, 0xe8, 0x01, 0, 0, 0, 0})
+	_ = binary.Write(&buf, binary.BigEndian, []int32{count, int32(store.Len())})
+	writeIndex(&buf, h.region, headerTypeBinary, trailerOffset, 16)
+	for i, tag := range tags {
+		entry := h.entries[tag]
+		writeIndex(&buf, tag, entry.typ, offsets[i], entry.count)
+	}
+	buf.Write(store.Bytes())
+	return buf.Bytes()
+}
+
+// readHeader parses the header at the start of data and returns it together
+// with its size. The region entry is not part of the returned entries, it is
+// written again by Bytes.
+func readHeader(data []byte) (*header, int, error) {
+	if len(data) < 16 || !bytes.Equal(data[:8], []byte{0x8e, 0xad, 0xe8, 0x01, 0, 0, 0, 0}) {
+		return nil, 0, errNotRPM
+	}
+	count := int(binary.BigEndian.Uint32(data[8:]))
+	storeSize := int(binary.BigEndian.Uint32(data[12:]))
+	storeStart := 16 + count*16
+	size := storeStart + storeSize
+	if count <= 0 || storeSize < 0 || size > len(data) {
+		return nil, 0, errNotRPM
+	}
+	store := data[storeStart:size]
+
+	var h *header
+	for i := 0; i < count; i++ {
+		var index [4]int32
+		_ = binary.Read(bytes.NewReader(data[16+i*16:]), binary.BigEndian, &index)
+		tag, typ, offset, n := index[0], index[1], index[2], index[3]
+		if i == 0 {
+			// the region entry always comes first
+			h = newHeader(tag)
+			continue
+		}
+		if offset < 0 || int(offset) > len(store) {
+			return nil, 0, fmt.Errorf("invalid offset of tag %d", tag)
+		}
+		length, err := entryLength(typ, n, store[offset:])
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid tag %d: %w", tag, err)
+		}
+		h.entries[tag] = headerEntry{typ, n, store[offset : int(offset)+length]}
+	}
+	return h, size, nil
+}
+
+// entryLength returns the size of the data of an entry with the given type
+// and count.
+func entryLength(typ, count int32, data []byte) (int, error) {
+	length := 0
+	switch typ {
+	case headerTypeString, headerTypeStringArray, headerTypeI18NString:
+		if typ == headerTypeString {
+			count = 1
+		}
+		for i := int32(0); i < count; i++ {
+			end := bytes.IndexByte(data[length:], 0)
+			if end < 0 {
+				return 0, errors.New("unterminated string")
+			}
+			length += end + 1
+		}
+	case headerTypeBinary, headerTypeChar, headerTypeInt8:
+		length = int(count)
+	case headerTypeInt16:
+		length = 2 * int(count)
+	case headerTypeInt32:
+		length = 4 * int(count)
+	case headerTypeInt64:
+		length = 8 * int(count)
+	default:
+		return 0, fmt.Errorf("unknown type %d", typ)
+	}
+	if length < 0 || length > len(data) {
+		return 0, errors.New("data out of bounds")
+	}
+	return length, nil
+}
+
+func writeIndex(buf *bytes.Buffer, tag, typ, offset, count int32) {
+	_ = binary.Write(buf, binary.BigEndian, []int32{tag, typ, offset, count})
+}
+
+// sourceLead returns the legacy lead of a source RPM.
+func sourceLead(name string) []byte {
+	n := []byte(name)
+	if len(n) > 65 {
+		n = n[:65]
+	}
+	n = append(n, make([]byte, 66-len(n))...)
+
+	var buf bytes.Buffer
+	// magic and format version 3.0
+	buf.Write([]byte{0xed, 0xab, 0xee, 0xdb, 0x03, 0x00})
+	// type source and arch number
+	_ = binary.Write(&buf, binary.BigEndian, []int16{1, 1})
+	buf.Write(n)
+	// os number and header-style signature type
+	_ = binary.Write(&buf, binary.BigEndian, []int16{1, 5})
+	buf.Write(make([]byte, 16))
+	return buf.Bytes()
+}
@@ -4,9 +4,13 @@ package rpm
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -16,7 +20,9 @@ import (
 	"github.com/goreleaser/nfpm/v2"
 	"github.com/goreleaser/nfpm/v2/files"
 	"github.com/goreleaser/nfpm/v2/internal/modtime"
+	"github.com/goreleaser/nfpm/v2/internal/shell"
 	"github.com/goreleaser/nfpm/v2/internal/sign"
+	"github.com/goreleaser/nfpm/v2/internal/systemd"
 )
 
 const (
@@ -31,6 +37,20 @@ const (
 	tagLink = 0o120000
 	// Directory
 	tagDirectory = 0o40000
+	// Character device
+	tagCharDevice = 0o20000
+	// Block device
+	tagBlockDevice = 0o60000
+	// Named pipe
+	tagFIFO = 0o10000
+
+	// https://github.com/rpm-software-management/rpm/blob/master/include/rpm/rpmds.h
+	// requirements needed by the scriptlets, as in Requires(pre)
+	senseScriptPre    = 1 << 9
+	senseScriptPost   = 1 << 10
+	senseScriptPostun = 1 << 12
+
+	updateAlternatives = "/usr/sbin/update-alternatives"
 
 	changelogNotesTemplate = `
 {{- range .Changes }}{{$note := splitList "\n" .Note}}
@@ -105,80 +125,113 @@ func (*RPM) ConventionalExtension() string {
 
 // Package writes a new RPM package to the given writer using the given info.
 func (*RPM) Package(info *nfpm.Info, w io.Writer) (err error) {
-	var (
-		meta *rpmpack.RPMMetaData
-		rpm  *rpmpack.RPM
-	)
 	info = setDefaults(info)
 
-	err = nfpm.PrepareForPackager(info, packagerName)
+	err = nfpm.PrepareForPackager(withAlternativeGhosts(withCopyrightIfRequested(info)), packagerName)
 	if err != nil {
 		return err
 	}
 
-	if meta, err = buildRPMMeta(info); err != nil {
+	rpm, added, err := newRPM(info)
+	if err != nil {
 		return err
 	}
-	if rpm, err = rpmpack.NewRPM(*meta); err != nil {
-		return err
+
+	if added.needFileTags() {
+		// rpmpack writes every file that is neither a directory nor a
+		// symlink as a regular file with an inode of its own
+		tags, err := added.fileTags()
+		if err != nil {
+			return err
+		}
+		for tag, entry := range tags {
+			rpm.AddCustomTag(tag, entry)
+		}
 	}
 
-	if info.RPM.Signature.KeyFile != "" {
-		rpm.SetPGPSigner(sign.PGPSignerWithKeyID(
-			info.RPM.Signature.KeyFile,
-			info.RPM.Signature.KeyPassphrase,
-			info.RPM.Signature.KeyID,
-		))
+	if len(pgpSigners(info)) > 0 {
+		// rpmpack only supports a single signing key, and stores its
+		// signatures in the RSA tags regardless of the key algorithm
+		var unsigned bytes.Buffer
+		if err := rpm.Write(&unsigned); err != nil {
+			return err
+		}
+		return signRPM(info, &unsigned, w)
 	}
-	if signFn := info.RPM.Signature.SignFn; signFn != nil {
-		rpm.SetPGPSigner(func(data []byte) ([]byte, error) {
-			return signFn(bytes.NewReader(data))
-		})
+	return rpm.Write(w)
+}
+
+// newRPM creates the rpm with the files, scripts and changelog of the given
+// info.
+func newRPM(info *nfpm.Info) (*rpmpack.RPM, *rpmFiles, error) {
+	meta, err := buildRPMMeta(info)
+	if err != nil {
+		return nil, nil, err
+	}
+	rpm, err := rpmpack.NewRPM(*meta)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if err = createFilesInsideRPM(info, rpm); err != nil {
-		return err
+	added, err := createFilesInsideRPM(info, rpm)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if err = addScriptFiles(info, rpm); err != nil {
-		return err
+	if err := addScriptFiles(info, rpm); err != nil {
+		return nil, nil, err
 	}
 
 	if info.Changelog != "" {
-		if err = addChangeLog(info, rpm); err != nil {
-			return err
+		if err := addChangeLog(info, rpm); err != nil {
+			return nil, nil, err
 		}
 	}
 
-	return rpm.Write(w)
+	return rpm, added, nil
 }
 
 func addChangeLog(info *nfpm.Info, rpm *rpmpack.RPM) error {
-	changelog, err := info.GetChangeLog()
+	times, titles, changes, err := changelogEntries(info)
 	if err != nil {
-		return fmt.Errorf("reading changelog: %w", err)
+		return err
 	}
 
-	if len(changelog.Entries) == 0 {
+	if len(times) == 0 {
 		// no nothing because creating empty tags
 		// would result in an invalid package
 		return nil
 	}
 
+	rpm.AddCustomTag(tagChangelogTime, rpmpack.EntryUint32(times))
+	rpm.AddCustomTag(tagChangelogName, rpmpack.EntryStringSlice(titles))
+	rpm.AddCustomTag(tagChangelogText, rpmpack.EntryStringSlice(changes))
+
+	return nil
+}
+
+// changelogEntries returns the time, title and formatted notes of every
+// changelog entry.
+func changelogEntries(info *nfpm.Info) (times []uint32, titles, changes []string, err error) {
+	changelog, err := info.GetChangeLog()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("reading changelog: %w", err)
+	}
+
 	tpl, err := chglog.LoadTemplateData(changelogNotesTemplate)
 	if err != nil {
-		return fmt.Errorf("parsing RPM changelog template: %w", err)
+		return nil, nil, nil, fmt.Errorf("parsing RPM changelog template: %w", err)
 	}
 
-	changes := make([]string, len(changelog.Entries))
-	titles := make([]string, len(changelog.Entries))
-	times := make([]uint32, len(changelog.Entries))
+	changes = make([]string, len(changelog.Entries))
+	titles = make([]string, len(changelog.Entries))
+	times = make([]uint32, len(changelog.Entries))
 	for idx, entry := range changelog.Entries {
 		var formattedNotes bytes.Buffer
 
 		err := tpl.Execute(&formattedNotes, entry)
 		if err != nil {
-			return fmt.Errorf("formatting changelog notes: %w", err)
+			return nil, nil, nil, fmt.Errorf("formatting changelog notes: %w", err)
 		}
 
 		changes[idx] = strings.TrimSpace(formattedNotes.String())
@@ -186,11 +239,7 @@ func addChangeLog(info *nfpm.Info, rpm *rpmpack.RPM) error {
 		titles[idx] = fmt.Sprintf("%s - %s", entry.Packager, entry.Semver)
 	}
 
-	rpm.AddCustomTag(tagChangelogTime, rpmpack.EntryUint32(times))
-	rpm.AddCustomTag(tagChangelogName, rpmpack.EntryStringSlice(titles))
-	rpm.AddCustomTag(tagChangelogText, rpmpack.EntryStringSlice(changes))
-
-	return nil
+	return times, titles, changes, nil
 }
 
 //nolint:funlen
@@ -234,6 +283,13 @@ func buildRPMMeta(info *nfpm.Info) (*rpmpack.RPMMetaData, error) {
 	if conflicts, err = toRelation(info.Conflicts); err != nil {
 		return nil, err
 	}
+	provides, depends = withUserRelations(info, provides, depends)
+	if len(info.Alternatives) > 0 {
+		depends = append(depends, &rpmpack.Relation{
+			Name:  updateAlternatives,
+			Sense: senseScriptPost | senseScriptPostun,
+		})
+	}
 
 	hostname, err := os.Hostname()
 	if err != nil {
@@ -267,6 +323,207 @@ func buildRPMMeta(info *nfpm.Info) (*rpmpack.RPMMetaData, error) {
 	}, nil
 }
 
+// userCapabilities returns the user(name) and group(name) capabilities of
+// the users and groups created by the package.
+func userCapabilities(info *nfpm.Info) []string {
+	var capabilities []string
+	for _, group := range info.Groups {
+		capabilities = append(capabilities, "group("+group.Name+")")
+	}
+	for _, user := range info.Users {
+		capabilities = append(capabilities, "user("+user.Name+")")
+		if user.Group == "" {
+			capabilities = append(capabilities, "group("+user.Name+")")
+		}
+	}
+	return capabilities
+}
+
+// userPreRequires returns what the %pre scriptlet creating the users and
+// groups needs.
+func userPreRequires(info *nfpm.Info) []string {
+	capabilities := userCapabilities(info)
+	if len(capabilities) == 0 {
+		return nil
+	}
+	return append(capabilities, "/usr/sbin/groupadd", "/usr/sbin/useradd")
+}
+
+// withUserRelations adds the capabilities of the users and groups created
+// by the package along with the requirements of the %pre scriptlet.
+func withUserRelations(info *nfpm.Info, provides, requires rpmpack.Relations) (rpmpack.Relations, rpmpack.Relations) {
+	for _, capability := range userCapabilities(info) {
+		provides = append(provides, &rpmpack.Relation{Name: capability})
+	}
+	for _, requirement := range userPreRequires(info) {
+		requires = append(requires, &rpmpack.Relation{Name: requirement, Sense: senseScriptPre})
+	}
+	return provides, requires
+}
+
+// usersScript returns the %pre snippet creating the users and groups of the
+// package with the shadow utilities.
+func usersScript(info *nfpm.Info) string {
+	var b strings.Builder
+	groupadd := func(name string, gid uint32) {
+		args := "-r"
+		if gid != 0 {
+			args += " -g " + strconv.FormatUint(uint64(gid), 10)
+		}
+		fmt.Fprintf(&b, "getent group %[1]s >/dev/null || groupadd %[2]s %[1]s\n", shell.Quote(name), args)
+	}
+	for _, group := range info.Groups {
+		groupadd(group.Name, group.GID)
+	}
+	for _, user := range info.Users {
+		if user.Group == "" {
+			groupadd(user.Name, user.UID)
+		}
+		args := []string{"-r"}
+		if user.UID != 0 {
+			args = append(args, "-u", strconv.FormatUint(uint64(user.UID), 10))
+		}
+		args = append(args, "-g", shell.Quote(user.PrimaryGroup()))
+		if user.Home != "" {
+			args = append(args, "-d", shell.Quote(user.Home))
+		}
+		if user.Shell != "" {
+			args = append(args, "-s", shell.Quote(user.Shell))
+		}
+		if user.Description != "" {
+			args = append(args, "-c", shell.Quote(user.Description))
+		}
+		fmt.Fprintf(&b, "getent passwd %[1]s >/dev/null || useradd %[2]s %[1]s\n",
+			shell.Quote(user.Name), strings.Join(args, " "))
+		for _, group := range user.Groups {
+			fmt.Fprintf(&b, "usermod -a -G %s %s\n", shell.Quote(group), shell.Quote(user.Name))
+		}
+	}
+	return b.String()
+}
+
+// systemdPost returns the %post snippet enabling and starting the systemd
+// units on install, like the %systemd_post macro.
+func systemdPost(info *nfpm.Info) string {
+	if len(info.Systemd) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	if enable := systemd.Names(info.Systemd, func(unit nfpm.SystemdUnit) bool { return unit.Enable }); enable != "" {
+		fmt.Fprintf(&b, "if [ $1 -eq 1 ]; then\n\tsystemctl --no-reload enable %s >/dev/null 2>&1 || :\nfi\n", enable)
+	}
+	b.WriteString("if [ -d /run/systemd/system ]; then\n")
+	b.WriteString("\tsystemctl daemon-reload >/dev/null 2>&1 || :\n")
+	if start := systemd.Names(info.Systemd, func(unit nfpm.SystemdUnit) bool { return unit.Start }); start != "" {
+		fmt.Fprintf(&b, "\tif [ $1 -eq 1 ]; then\n\t\tsystemctl start %s >/dev/null 2>&1 || :\n\tfi\n", start)
+	}
+	b.WriteString("fi\n")
+	return b.String()
+}
+
+// systemdPreun returns the %preun snippet disabling and stopping the systemd
+// units when the package is removed, like the %systemd_preun macro.
+func systemdPreun(info *nfpm.Info) string {
+	if len(info.Systemd) == 0 {
+		return ""
+	}
+	names := systemd.Names(info.Systemd, nil)
+	return fmt.Sprintf("if [ $1 -eq 0 ]; then\n"+
+		"\tsystemctl --no-reload disable %[1]s >/dev/null 2>&1 || :\n"+
+		"\tif [ -d /run/systemd/system ]; then\n"+
+		"\t\tsystemctl stop %[1]s >/dev/null 2>&1 || :\n"+
+		"\tfi\n"+
+		"fi\n", names)
+}
+
+// systemdPostun returns the %postun snippet restarting the systemd units on
+// upgrade, like the %systemd_postun_with_restart macro.
+func systemdPostun(info *nfpm.Info) string {
+	if len(info.Systemd) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("if [ -d /run/systemd/system ]; then\n")
+	b.WriteString("\tsystemctl daemon-reload >/dev/null 2>&1 || :\n")
+	if restart := systemd.Names(info.Systemd, func(unit nfpm.SystemdUnit) bool { return unit.RestartOnUpgrade }); restart != "" {
+		fmt.Fprintf(&b, "\tif [ $1 -ge 1 ]; then\n\t\tsystemctl try-restart %s >/dev/null 2>&1 || :\n\tfi\n", restart)
+	}
+	b.WriteString("fi\n")
+	return b.String()
+}
+
+// withAlternativeGhosts adds the links managed by the alternatives system as
+// %ghost files, so that they belong to the package.
+func withAlternativeGhosts(info *nfpm.Info) *nfpm.Info {
+	for _, alternative := range info.Alternatives {
+		// the link points to /etc/alternatives, which points to the target
+		alternativesLink := path.Join("/etc/alternatives", alternative.GenericName())
+		for _, link := range [][2]string{
+			{alternative.LinkName, alternativesLink},
+			{alternativesLink, alternative.Target},
+		} {
+			if info.Contents.ContainsDestination(link[0]) {
+				continue
+			}
+			info.Contents = append(info.Contents, &files.Content{
+				Source:      link[1],
+				Destination: link[0],
+				Type:        files.TypeRPMGhost,
+				FileInfo:    &files.ContentFileInfo{Mode: os.ModeSymlink | 0o777},
+			})
+		}
+	}
+
+	return info
+}
+
+// alternativesPost returns the %post snippet adding the alternatives of the
+// package.
+func alternativesPost(info *nfpm.Info) string {
+	var b strings.Builder
+	for _, alternative := range info.Alternatives {
+		fmt.Fprintf(&b, "%s --install %s %s %s %d\n",
+			updateAlternatives,
+			shell.Quote(alternative.LinkName),
+			shell.Quote(alternative.GenericName()),
+			shell.Quote(alternative.Target),
+			alternative.Priority,
+		)
+	}
+	return b.String()
+}
+
+// alternativesPostun returns the %postun snippet removing the alternatives
+// of the package when it is removed.
+func alternativesPostun(info *nfpm.Info) string {
+	if len(info.Alternatives) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("if [ $1 -eq 0 ]; then\n")
+	for _, alternative := range info.Alternatives {
+		fmt.Fprintf(&b, "\t%s --remove %s %s\n",
+			updateAlternatives,
+			shell.Quote(alternative.GenericName()),
+			shell.Quote(alternative.Target),
+		)
+	}
+	b.WriteString("fi\n")
+	return b.String()
+}
+
+// checkOwnerNames makes sure that the content has an owner and a group name,
+// as rpm records them by name only.
+func checkOwnerNames(content *files.Content) error {
+	if content.FileInfo.Owner == "" || content.FileInfo.Group == "" {
+		return fmt.Errorf("%s: rpm records owners and groups by name, uid and gid must come with owner and group", content.Destination)
+	}
+	return nil
+}
+
 func formatVersion(info *nfpm.Info) string {
 	version := info.Version
 
@@ -307,36 +564,36 @@ func addScriptFiles(info *nfpm.Info, rpm *rpmpack.RPM) error {
 		}
 		rpm.AddPretrans(string(data))
 	}
-	if info.Scripts.PreInstall != "" {
-		data, err := os.ReadFile(info.Scripts.PreInstall)
-		if err != nil {
-			return err
-		}
-		rpm.AddPrein(string(data))
+	preinstall, err := nfpm.MergeScript(info.Scripts.PreInstall, usersScript(info), "")
+	if err != nil {
+		return err
+	}
+	if preinstall != nil {
+		rpm.AddPrein(string(preinstall))
 	}
 
-	if info.Scripts.PreRemove != "" {
-		data, err := os.ReadFile(info.Scripts.PreRemove)
-		if err != nil {
-			return err
-		}
-		rpm.AddPreun(string(data))
+	preremove, err := nfpm.MergeScript(info.Scripts.PreRemove, systemdPreun(info), "")
+	if err != nil {
+		return err
+	}
+	if preremove != nil {
+		rpm.AddPreun(string(preremove))
 	}
 
-	if info.Scripts.PostInstall != "" {
-		data, err := os.ReadFile(info.Scripts.PostInstall)
-		if err != nil {
-			return err
-		}
-		rpm.AddPostin(string(data))
+	postinstall, err := nfpm.MergeScript(info.Scripts.PostInstall, alternativesPost(info), systemdPost(info))
+	if err != nil {
+		return err
+	}
+	if postinstall != nil {
+		rpm.AddPostin(string(postinstall))
 	}
 
-	if info.Scripts.PostRemove != "" {
-		data, err := os.ReadFile(info.Scripts.PostRemove)
-		if err != nil {
-			return err
-		}
-		rpm.AddPostun(string(data))
+	postremove, err := nfpm.MergeScript(info.Scripts.PostRemove, alternativesPostun(info), systemdPostun(info))
+	if err != nil {
+		return err
+	}
+	if postremove != nil {
+		rpm.AddPostun(string(postremove))
 	}
 
 	if info.RPM.Scripts.PostTrans != "" {
@@ -358,57 +615,302 @@ func addScriptFiles(info *nfpm.Info, rpm *rpmpack.RPM) error {
 	return nil
 }
 
+// pgpSigners returns the signers for the header and payload of the package,
+// starting with the signing key and followed by the additional keys.
+func pgpSigners(info *nfpm.Info) []func([]byte) ([]byte, error) {
+	var signers []func([]byte) ([]byte, error)
+	for _, signature := range append([]nfpm.PackageSignature{info.RPM.Signature.PackageSignature}, info.RPM.Signature.Keys...) {
+		if signer := pgpSigner(signature); signer != nil {
+			signers = append(signers, signer)
+		}
+	}
+	return signers
+}
+
+// pgpSigner returns the signer of the given signature, or nil if no key is
+// set.
+func pgpSigner(signature nfpm.PackageSignature) func([]byte) ([]byte, error) {
+	if signFn := sign.SignFn(signature, sign.PGPDetached); signFn != nil {
+		return func(data []byte) ([]byte, error) {
+			return signFn(bytes.NewReader(data))
+		}
+	}
+	if signature.KeyFile != "" {
+		return sign.PGPSignerWithKeyID(
+			signature.KeyFile,
+			signature.KeyPassphrase,
+			signature.KeyID,
+		)
+	}
+	return nil
+}
+
 // TODO: pass mtime down in all content types
-func createFilesInsideRPM(info *nfpm.Info, rpm *rpmpack.RPM) (err error) {
+func createFilesInsideRPM(info *nfpm.Info, rpm *rpmpack.RPM) (*rpmFiles, error) {
 	mtime := modtime.Get(info.MTime)
+	added := &rpmFiles{
+		files: map[string]addedRPMFile{},
+		links: map[string][]string{},
+	}
 	for _, content := range info.Contents {
 		if content.Packager != "" && content.Packager != packagerName {
 			continue
 		}
 
-		var file *rpmpack.RPMFile
+		if err := checkOwnerNames(content); err != nil {
+			return nil, err
+		}
+
+		var (
+			file *rpmpack.RPMFile
+			err  error
+		)
 
 		switch content.Type {
-		case files.TypeConfig:
-			file, err = asRPMFile(content, rpmpack.ConfigFile)
-		case files.TypeConfigNoReplace:
-			file, err = asRPMFile(content, rpmpack.ConfigFile|rpmpack.NoReplaceFile)
-		case files.TypeConfigMissingOK:
-			file, err = asRPMFile(content, rpmpack.ConfigFile|rpmpack.MissingOkFile)
 		case files.TypeRPMGhost:
+			if content.FileInfo.Mode&os.ModeSymlink != 0 {
+				file = asRPMSymlink(content)
+				file.Mode |= uint(content.FileInfo.Mode.Perm())
+				file.Type = fileType(content)
+				break
+			}
 			if content.FileInfo.Mode == 0 {
 				content.FileInfo.Mode = os.FileMode(0o644)
 			}
 
-			file, err = asRPMFile(content, rpmpack.GhostFile)
-		case files.TypeRPMDoc:
-			file, err = asRPMFile(content, rpmpack.DocFile)
-		case files.TypeRPMLicence, files.TypeRPMLicense:
-			file, err = asRPMFile(content, rpmpack.LicenceFile)
-		case files.TypeRPMReadme:
-			file, err = asRPMFile(content, rpmpack.ReadmeFile)
+			file, err = asRPMFile(content, fileType(content))
 		case files.TypeSymlink:
 			file = asRPMSymlink(content)
+		case files.TypeHardlink:
+			file, err = asRPMHardlink(info.Contents, content)
+		case files.TypeCharDevice, files.TypeBlockDevice, files.TypeFIFO:
+			file, err = asRPMSpecialFile(content)
 		case files.TypeDir:
 			file = asRPMDirectory(content, mtime)
 		case files.TypeImplicitDir:
 			// we don't need to add imlicit directories to RPMs
 			continue
+		case files.TypeCopyright:
+			file, err = asRPMCopyright(info, content)
 		default:
-			file, err = asRPMFile(content, rpmpack.GenericFile)
+			file, err = asRPMFile(content, fileType(content))
 		}
 
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		// clean assures that even folders do not have a trailing slash
 		file.Name = files.ToNixPath(file.Name)
-		rpm.AddFile(*file)
+		added.files[file.Name] = addedRPMFile{file: *file, content: content}
+		if content.Type == files.TypeHardlink {
+			target := files.ToNixPath(content.Source)
+			added.links[target] = append(added.links[target], file.Name)
+		}
+	}
 
+	added.moveHardlinkData()
+	for _, f := range added.files {
+		rpm.AddFile(f.file)
 	}
 
-	return nil
+	return added, nil
+}
+
+// addedRPMFile is a file added to the rpm with the content it was created from.
+type addedRPMFile struct {
+	file    rpmpack.RPMFile
+	content *files.Content
+}
+
+// rpmFiles are the files added to the rpm, and the hard links to each
+// hard link target.
+type rpmFiles struct {
+	files map[string]addedRPMFile
+	links map[string][]string
+}
+
+// linkSet returns the sorted names of the files of the hard link set of the
+// given target.
+func (r *rpmFiles) linkSet(target string) []string {
+	names := append([]string{target}, r.links[target]...)
+	sort.Strings(names)
+	return names
+}
+
+// moveHardlinkData moves the data of each hard link set to its last file, as
+// rpm reads it from there, and leaves the other files empty. rpmpack writes
+// the files sorted by name.
+func (r *rpmFiles) moveHardlinkData() {
+	for target := range r.links {
+		names := r.linkSet(target)
+		last := names[len(names)-1]
+		if last == target {
+			continue
+		}
+
+		from, to := r.files[target], r.files[last]
+		to.file.Body, from.file.Body = from.file.Body, nil
+		r.files[target], r.files[last] = from, to
+	}
+}
+
+// needFileTags reports whether the file tags written by rpmpack have to be
+// corrected.
+func (r *rpmFiles) needFileTags() bool {
+	if len(r.links) > 0 {
+		return true
+	}
+	for _, f := range r.files {
+		if isSpecialFile(f.content) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileTags returns the file tags rpmpack writes for the files, sorted by
+// name, with the modes and device numbers of special files, and the inodes,
+// sizes and digests of hard links, corrected.
+func (r *rpmFiles) fileTags() (map[int]rpmpack.IndexEntry, error) {
+	names := make([]string, 0, len(r.files))
+	for name, f := range r.files {
+		// rpmpack only writes 32 bit file sizes
+		if uint64(len(f.file.Body)) > math.MaxUint32 {
+			return nil, fmt.Errorf("%s: files of 4 GiB or more cannot be packaged with special files or hard links", name)
+		}
+		if name != "/" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	modes := make([]uint16, len(names))
+	rdevs := make([]int16, len(names))
+	sizes := make([]int32, len(names))
+	inodes := make([]int32, len(names))
+	devices := make([]int32, len(names))
+	digests := make([]string, len(names))
+	index := make(map[string]int, len(names))
+	for i, name := range names {
+		index[name] = i
+		f := r.files[name]
+		modes[i], rdevs[i], sizes[i] = uint16(f.file.Mode), 1, int32(len(f.file.Body))
+		inodes[i], devices[i] = int32(i+1), 1
+		switch {
+		case isSpecialFile(f.content):
+			rdevs[i] = int16(f.content.FileInfo.DevMajor<<8 | f.content.FileInfo.DevMinor)
+			sizes[i] = 0
+		case f.file.Mode&tagDirectory != 0:
+			sizes[i] = 4096
+		case f.file.Mode&tagLink == tagLink:
+		default:
+			modes[i] |= 0o100000
+			digests[i] = fmt.Sprintf("%x", sha256.Sum256(f.file.Body))
+		}
+	}
+
+	// the data of a hard link set is in its last file
+	for target := range r.links {
+		set := r.linkSet(target)
+		last := index[set[len(set)-1]]
+		for _, name := range set {
+			i := index[name]
+			inodes[i], sizes[i], digests[i] = inodes[last], sizes[last], digests[last]
+		}
+	}
+
+	tags := map[int]rpmpack.IndexEntry{
+		tagFileModes:   rpmpack.EntryUint16(modes),
+		tagFileRDevs:   rpmpack.EntryInt16(rdevs),
+		tagFileSizes:   rpmpack.EntryInt32(sizes),
+		tagFileINodes:  rpmpack.EntryInt32(inodes),
+		tagFileDigests: rpmpack.EntryStringSlice(digests),
+	}
+	if len(r.links) > 0 {
+		// rpm groups hard links by device and inode
+		tags[tagFileDevices] = rpmpack.EntryInt32(devices)
+	}
+	return tags, nil
+}
+
+func isSpecialFile(content *files.Content) bool {
+	switch content.Type {
+	case files.TypeCharDevice, files.TypeBlockDevice, files.TypeFIFO:
+		return true
+	default:
+		return false
+	}
+}
+
+func asRPMSpecialFile(content *files.Content) (*rpmpack.RPMFile, error) {
+	mode := uint(content.FileInfo.Mode & 0o7777)
+	switch content.Type {
+	case files.TypeCharDevice:
+		mode |= tagCharDevice
+	case files.TypeBlockDevice:
+		mode |= tagBlockDevice
+	default:
+		mode |= tagFIFO
+	}
+
+	// the device number is stored in 16 bits
+	if content.FileInfo.DevMajor > 0xff || content.FileInfo.DevMinor > 0xff {
+		return nil, fmt.Errorf("%s: device major and minor numbers must be below 256", content.Destination)
+	}
+
+	return &rpmpack.RPMFile{
+		Name:  content.Destination,
+		Mode:  mode,
+		MTime: uint32(content.FileInfo.MTime.Unix()),
+		Owner: content.FileInfo.Owner,
+		Group: content.FileInfo.Group,
+	}, nil
+}
+
+// fileType returns the RPM file flags for the given content.
+func fileType(content *files.Content) rpmpack.FileType {
+	switch content.Type {
+	case files.TypeConfig:
+		return rpmpack.ConfigFile
+	case files.TypeConfigNoReplace:
+		return rpmpack.ConfigFile | rpmpack.NoReplaceFile
+	case files.TypeConfigMissingOK:
+		return rpmpack.ConfigFile | rpmpack.MissingOkFile
+	case files.TypeRPMGhost:
+		return rpmpack.GhostFile
+	case files.TypeRPMDoc:
+		return rpmpack.DocFile
+	case files.TypeRPMLicence, files.TypeRPMLicense, files.TypeCopyright:
+		return rpmpack.LicenceFile
+	case files.TypeRPMReadme:
+		return rpmpack.ReadmeFile
+	default:
+		return rpmpack.GenericFile
+	}
+}
+
+// withCopyrightIfRequested adds the copyright file to the location of
+// %license files.
+func withCopyrightIfRequested(info *nfpm.Info) *nfpm.Info {
+	return nfpm.WithCopyrightIfRequested(info, fmt.Sprintf("/usr/share/licenses/%s/copyright", info.Name))
+}
+
+func asRPMCopyright(info *nfpm.Info, content *files.Content) (*rpmpack.RPMFile, error) {
+	data, err := info.FormatCopyright()
+	if err != nil {
+		return nil, err
+	}
+
+	return &rpmpack.RPMFile{
+		Name:  content.Destination,
+		Body:  data,
+		Mode:  uint(content.FileInfo.Mode),
+		MTime: uint32(content.FileInfo.MTime.Unix()),
+		Owner: content.FileInfo.Owner,
+		Group: content.FileInfo.Group,
+		Type:  fileType(content),
+	}, nil
 }
 
 func asRPMDirectory(content *files.Content, mtime time.Time) *rpmpack.RPMFile {
@@ -421,6 +923,27 @@ func asRPMDirectory(content *files.Content, mtime time.Time) *rpmpack.RPMFile {
 	}
 }
 
+// asRPMHardlink returns an empty file with the metadata of the hard link
+// target. It shares the inode, and with it the data, of the target.
+func asRPMHardlink(contents files.Contents, content *files.Content) (*rpmpack.RPMFile, error) {
+	for _, target := range contents {
+		if target.Destination != content.Source {
+			continue
+		}
+
+		return &rpmpack.RPMFile{
+			Name:  content.Destination,
+			Mode:  uint(target.FileInfo.Mode),
+			MTime: uint32(target.FileInfo.MTime.Unix()),
+			Owner: target.FileInfo.Owner,
+			Group: target.FileInfo.Group,
+			Type:  fileType(target),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("hardlink %s: target %s not found", content.Destination, content.Source)
+}
+
 func asRPMSymlink(content *files.Content) *rpmpack.RPMFile {
 	return &rpmpack.RPMFile{
 		Name:  content.Destination,
@@ -433,7 +956,7 @@ func asRPMSymlink(content *files.Content) *rpmpack.RPMFile {
 }
 
 func asRPMFile(content *files.Content, fileType rpmpack.FileType) (*rpmpack.RPMFile, error) {
-	data, err := os.ReadFile(content.Source)
+	data, err := content.ReadData()
 	if err != nil && content.Type != files.TypeRPMGhost {
 		return nil, err
 	}
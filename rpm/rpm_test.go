@@ -2,6 +2,8 @@ package rpm
 
 import (
 	"bytes"
+	"compress/gzip"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
@@ -12,6 +14,8 @@ import (
 	"time"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/google/rpmpack"
 	"github.com/goreleaser/chglog"
 	"github.com/goreleaser/nfpm/v2"
 	"github.com/goreleaser/nfpm/v2/files"
@@ -713,6 +717,401 @@ func TestRPMConventionalFileName(t *testing.T) {
 	}
 }
 
+func TestRPMCopyright(t *testing.T) {
+	info := exampleInfo()
+	info.Copyright.Files = []nfpm.CopyrightFiles{{
+		Paths:     []string{"*"},
+		Copyright: []string{"2023 Carlos A Becker"},
+		License:   "MIT",
+	}}
+
+	var rpmFileBuffer bytes.Buffer
+	require.NoError(t, Default.Package(info, &rpmFileBuffer))
+
+	expected, err := info.FormatCopyright()
+	require.NoError(t, err)
+	copyright, err := extractFileFromRpm(rpmFileBuffer.Bytes(), "/usr/share/licenses/foo/copyright")
+	require.NoError(t, err)
+	require.Equal(t, expected, copyright)
+
+	fileInfos, err := extraFileInfoSliceFromRpm(rpmFileBuffer.Bytes())
+	require.NoError(t, err)
+	for _, fileInfo := range fileInfos {
+		if fileInfo.Name() == "/usr/share/licenses/foo/copyright" {
+			require.Equal(t, int(rpmpack.LicenceFile), fileInfo.Flags())
+			require.Equal(t, 0o100644, fileInfo.Mode())
+			return
+		}
+	}
+	t.Fatal("copyright file not found")
+}
+
+func TestRPMManPage(t *testing.T) {
+	info := exampleInfo()
+	info.Contents = append(info.Contents, &files.Content{
+		Source: "../files/testdata/man/foo.1",
+		Type:   files.TypeManPage,
+	})
+
+	var rpmFileBuffer bytes.Buffer
+	require.NoError(t, Default.Package(info, &rpmFileBuffer))
+
+	manPage, err := extractFileFromRpm(rpmFileBuffer.Bytes(), "/usr/share/man/man1/foo.1.gz")
+	require.NoError(t, err)
+	gz, err := gzip.NewReader(bytes.NewReader(manPage))
+	require.NoError(t, err)
+	data, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	expected, err := os.ReadFile("../files/testdata/man/foo.1")
+	require.NoError(t, err)
+	require.Equal(t, expected, data)
+}
+
+func TestRPMHardlink(t *testing.T) {
+	info := exampleInfo()
+	info.Contents = append(info.Contents, &files.Content{
+		Source:      "/usr/bin/fake",
+		Destination: "/usr/bin/a-fake",
+		Type:        files.TypeHardlink,
+	}, &files.Content{
+		Source:      "/usr/bin/fake",
+		Destination: "/usr/bin/z-fake",
+		Type:        files.TypeHardlink,
+	})
+
+	var rpmFileBuffer bytes.Buffer
+	require.NoError(t, Default.Package(info, &rpmFileBuffer))
+
+	expected, err := os.ReadFile("../testdata/fake")
+	require.NoError(t, err)
+
+	rpm, err := rpmutils.ReadRpm(bytes.NewReader(rpmFileBuffer.Bytes()))
+	require.NoError(t, err)
+	fileInfos, err := rpm.Header.GetFiles()
+	require.NoError(t, err)
+	inodes := map[string]int{}
+	for _, fi := range fileInfos {
+		inodes[fi.Name()] = fi.Inode()
+		if strings.HasSuffix(fi.Name(), "fake") {
+			require.Equal(t, int64(len(expected)), fi.Size(), fi.Name())
+			require.Equal(t, 1, fi.Device(), fi.Name())
+		}
+	}
+	require.Equal(t, inodes["/usr/bin/fake"], inodes["/usr/bin/a-fake"])
+	require.Equal(t, inodes["/usr/bin/fake"], inodes["/usr/bin/z-fake"])
+	require.NotEqual(t, inodes["/usr/bin/fake"], inodes["/etc/fake/fake.conf"])
+
+	// the data is only stored once, with the last file of the link set
+	for name, size := range map[string]int{
+		"/usr/bin/a-fake": 0,
+		"/usr/bin/fake":   0,
+		"/usr/bin/z-fake": len(expected),
+	} {
+		hdr, err := extractFileHeaderFromRpm(rpmFileBuffer.Bytes(), name)
+		require.NoError(t, err)
+		require.Equal(t, size, hdr.Filesize(), name)
+	}
+
+	pr, err := rpm.PayloadReaderExtended()
+	require.NoError(t, err)
+	for {
+		fi, err := pr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		if fi.Name() != "/usr/bin/z-fake" {
+			continue
+		}
+		require.False(t, pr.IsLink())
+		data, err := io.ReadAll(pr)
+		require.NoError(t, err)
+		require.Equal(t, expected, data)
+	}
+}
+
+func TestRPMSpecialFiles(t *testing.T) {
+	info := exampleInfo()
+	info.Contents = append(info.Contents, &files.Content{
+		Destination: "/dev/null",
+		Type:        files.TypeCharDevice,
+		FileInfo:    &files.ContentFileInfo{Mode: 0o666, DevMajor: 1, DevMinor: 3},
+	}, &files.Content{
+		Destination: "/dev/loop0",
+		Type:        files.TypeBlockDevice,
+		FileInfo:    &files.ContentFileInfo{Mode: 0o660, DevMajor: 7},
+	}, &files.Content{
+		Destination: "/run/foo.fifo",
+		Type:        files.TypeFIFO,
+		FileInfo:    &files.ContentFileInfo{Mode: 0o600},
+	})
+
+	var rpmFileBuffer bytes.Buffer
+	require.NoError(t, Default.Package(info, &rpmFileBuffer))
+
+	rpm, err := rpmutils.ReadRpm(bytes.NewReader(rpmFileBuffer.Bytes()))
+	require.NoError(t, err)
+	fileInfos, err := rpm.Header.GetFiles()
+	require.NoError(t, err)
+	rdevs, err := rpm.Header.GetInts(rpmutils.FILERDEVS)
+	require.NoError(t, err)
+	require.Len(t, rdevs, len(fileInfos))
+
+	modes := map[string]int{}
+	devices := map[string]int{}
+	for i, fi := range fileInfos {
+		modes[fi.Name()] = fi.Mode()
+		devices[fi.Name()] = rdevs[i]
+	}
+	require.Equal(t, 0o20666, modes["/dev/null"])
+	require.Equal(t, 1<<8|3, devices["/dev/null"])
+	require.Equal(t, 0o60660, modes["/dev/loop0"])
+	require.Equal(t, 7<<8, devices["/dev/loop0"])
+	require.Equal(t, 0o10600, modes["/run/foo.fifo"])
+	require.Equal(t, 0o100000, modes["/usr/bin/fake"]&0o170000)
+	require.Equal(t, 0o40000, modes["/var/log/whatever"]&0o170000)
+
+	data, err := extractFileFromRpm(rpmFileBuffer.Bytes(), "/usr/bin/fake")
+	require.NoError(t, err)
+	expected, err := os.ReadFile("../testdata/fake")
+	require.NoError(t, err)
+	require.Equal(t, expected, data)
+}
+
+func TestRPMSpecialFilesWithGhostAndSymlink(t *testing.T) {
+	info := exampleInfo()
+	info.Contents = append(info.Contents, &files.Content{
+		Destination: "/dev/null",
+		Type:        files.TypeCharDevice,
+		FileInfo:    &files.ContentFileInfo{Mode: 0o666, DevMajor: 1, DevMinor: 3},
+	}, &files.Content{
+		Destination: "/var/log/foo.log",
+		Type:        files.TypeRPMGhost,
+	}, &files.Content{
+		Source:      "/usr/bin/fake",
+		Destination: "/usr/bin/fake-link",
+		Type:        files.TypeSymlink,
+	})
+
+	var rpmFileBuffer bytes.Buffer
+	require.NoError(t, Default.Package(info, &rpmFileBuffer))
+
+	rpm, err := rpmutils.ReadRpm(bytes.NewReader(rpmFileBuffer.Bytes()))
+	require.NoError(t, err)
+	fileInfos, err := rpm.Header.GetFiles()
+	require.NoError(t, err)
+	rdevs, err := rpm.Header.GetInts(rpmutils.FILERDEVS)
+	require.NoError(t, err)
+
+	found := map[string]bool{}
+	for i, fi := range fileInfos {
+		switch fi.Name() {
+		case "/dev/null":
+			require.Equal(t, 0o20666, fi.Mode())
+			require.Equal(t, 1<<8|3, rdevs[i])
+			require.Zero(t, fi.Size())
+			require.Empty(t, fi.Digest())
+		case "/var/log/foo.log":
+			require.Equal(t, 0o100644, fi.Mode())
+			require.Equal(t, int(rpmpack.GhostFile), fi.Flags())
+			require.Zero(t, fi.Size())
+			require.NotEmpty(t, fi.Digest())
+		case "/usr/bin/fake-link":
+			require.Equal(t, 0o120000, fi.Mode()&0o170000)
+			require.Equal(t, "/usr/bin/fake", fi.Linkname())
+			require.Equal(t, int64(len("/usr/bin/fake")), fi.Size())
+			require.Empty(t, fi.Digest())
+		default:
+			continue
+		}
+		found[fi.Name()] = true
+	}
+	require.Len(t, found, 3)
+
+	tree := getTree(t, rpmFileBuffer.Bytes())
+	require.Contains(t, tree, "/dev/null")
+	require.Contains(t, tree, "/usr/bin/fake-link")
+	require.NotContains(t, tree, "/var/log/foo.log")
+}
+
+func TestRPMDeviceNumberTooLarge(t *testing.T) {
+	info := exampleInfo()
+	info.Contents = append(info.Contents, &files.Content{
+		Destination: "/dev/foo",
+		Type:        files.TypeCharDevice,
+		FileInfo:    &files.ContentFileInfo{DevMajor: 256},
+	})
+	require.EqualError(t, Default.Package(info, io.Discard), "/dev/foo: device major and minor numbers must be below 256")
+}
+
+func TestRPMUsers(t *testing.T) {
+	info := exampleInfo()
+	info.Groups = []nfpm.Group{{Name: "foo-data", GID: 950}}
+	info.Users = []nfpm.User{
+		{Name: "foo", UID: 950, Group: "foo-data", Groups: []string{"adm"}, Shell: "/sbin/nologin"},
+		{Name: "bar"},
+	}
+
+	var rpmFileBuffer bytes.Buffer
+	require.NoError(t, Default.Package(info, &rpmFileBuffer))
+
+	rpm, err := rpmutils.ReadRpm(bytes.NewReader(rpmFileBuffer.Bytes()))
+	require.NoError(t, err)
+
+	provides, err := rpm.Header.GetStrings(rpmutils.PROVIDENAME)
+	require.NoError(t, err)
+	require.Subset(t, provides, []string{"group(foo-data)", "user(foo)", "user(bar)", "group(bar)"})
+
+	requires, err := rpm.Header.GetStrings(rpmutils.REQUIRENAME)
+	require.NoError(t, err)
+	flags, err := rpm.Header.GetInts(rpmutils.REQUIREFLAGS)
+	require.NoError(t, err)
+	preRequires := map[string]bool{}
+	for i, name := range requires {
+		if flags[i]&senseScriptPre != 0 {
+			preRequires[name] = true
+		}
+	}
+	require.Equal(t, map[string]bool{
+		"group(foo-data)":    true,
+		"user(foo)":          true,
+		"user(bar)":          true,
+		"group(bar)":         true,
+		"/usr/sbin/groupadd": true,
+		"/usr/sbin/useradd":  true,
+	}, preRequires)
+
+	prein, err := rpm.Header.GetString(rpmutils.PREIN)
+	require.NoError(t, err)
+	require.Equal(t, `#!/bin/bash
+getent group 'foo-data' >/dev/null || groupadd -r -g 950 'foo-data'
+getent passwd 'foo' >/dev/null || useradd -r -u 950 -g 'foo-data' -s '/sbin/nologin' 'foo'
+usermod -a -G 'adm' 'foo'
+getent group 'bar' >/dev/null || groupadd -r 'bar'
+getent passwd 'bar' >/dev/null || useradd -r -g 'bar' 'bar'
+
+echo "Preinstall" > /dev/null
+`, prein)
+
+	data, err := extractFileFromRpm(rpmFileBuffer.Bytes(), "/usr/lib/sysusers.d/foo.conf")
+	require.NoError(t, err)
+	require.Equal(t, info.FormatSysusers(), data)
+}
+
+func TestRPMSystemd(t *testing.T) {
+	info := exampleInfo()
+	info.Systemd = []nfpm.SystemdUnit{
+		{Source: "../testdata/systemd/foo.service", Enable: true, Start: true, RestartOnUpgrade: true},
+		{Source: "../testdata/systemd/foo-cleanup.timer", Enable: true},
+	}
+
+	var rpmFileBuffer bytes.Buffer
+	require.NoError(t, Default.Package(info, &rpmFileBuffer))
+
+	rpm, err := rpmutils.ReadRpm(bytes.NewReader(rpmFileBuffer.Bytes()))
+	require.NoError(t, err)
+
+	postin, err := rpm.Header.GetString(rpmutils.POSTIN)
+	require.NoError(t, err)
+	require.Equal(t, `#!/bin/bash
+(
+
+echo "Postinstall" > /dev/null
+) || exit $?
+if [ $1 -eq 1 ]; then
+	systemctl --no-reload enable 'foo.service' 'foo-cleanup.timer' >/dev/null 2>&1 || :
+fi
+if [ -d /run/systemd/system ]; then
+	systemctl daemon-reload >/dev/null 2>&1 || :
+	if [ $1 -eq 1 ]; then
+		systemctl start 'foo.service' >/dev/null 2>&1 || :
+	fi
+fi
+`, postin)
+
+	preun, err := rpm.Header.GetString(rpmutils.PREUN)
+	require.NoError(t, err)
+	require.Contains(t, preun, `#!/bin/bash
+if [ $1 -eq 0 ]; then
+	systemctl --no-reload disable 'foo.service' 'foo-cleanup.timer' >/dev/null 2>&1 || :
+	if [ -d /run/systemd/system ]; then
+		systemctl stop 'foo.service' 'foo-cleanup.timer' >/dev/null 2>&1 || :
+	fi
+fi
+`)
+
+	postun, err := rpm.Header.GetString(rpmutils.POSTUN)
+	require.NoError(t, err)
+	require.Contains(t, postun, "\tif [ $1 -ge 1 ]; then\n\t\tsystemctl try-restart 'foo.service' >/dev/null 2>&1 || :\n\tfi\n")
+
+	data, err := extractFileFromRpm(rpmFileBuffer.Bytes(), "/usr/lib/systemd/system/foo.service")
+	require.NoError(t, err)
+	expected, err := os.ReadFile("../testdata/systemd/foo.service")
+	require.NoError(t, err)
+	require.Equal(t, expected, data)
+}
+
+func TestRPMAlternatives(t *testing.T) {
+	info := exampleInfo()
+	info.Scripts.PostInstall = ""
+	info.Alternatives = []nfpm.Alternative{
+		{LinkName: "/usr/bin/java", Target: "/usr/lib/jvm/java-17/bin/java", Priority: 1700},
+	}
+
+	var rpmFileBuffer bytes.Buffer
+	require.NoError(t, Default.Package(info, &rpmFileBuffer))
+
+	rpm, err := rpmutils.ReadRpm(bytes.NewReader(rpmFileBuffer.Bytes()))
+	require.NoError(t, err)
+
+	postin, err := rpm.Header.GetString(rpmutils.POSTIN)
+	require.NoError(t, err)
+	require.Equal(t, "#!/bin/sh\n/usr/sbin/update-alternatives --install '/usr/bin/java' 'java' '/usr/lib/jvm/java-17/bin/java' 1700\n", postin)
+
+	postun, err := rpm.Header.GetString(rpmutils.POSTUN)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(postun, "#!/bin/bash\nif [ $1 -eq 0 ]; then\n\t/usr/sbin/update-alternatives --remove 'java' '/usr/lib/jvm/java-17/bin/java'\nfi\n"), postun)
+
+	requires, err := rpm.Header.GetStrings(rpmutils.REQUIRENAME)
+	require.NoError(t, err)
+	flags, err := rpm.Header.GetInts(rpmutils.REQUIREFLAGS)
+	require.NoError(t, err)
+	require.Contains(t, requires, "/usr/sbin/update-alternatives")
+	for i, name := range requires {
+		if name == "/usr/sbin/update-alternatives" {
+			require.Equal(t, senseScriptPost|senseScriptPostun, flags[i])
+		}
+	}
+
+	fileInfos, err := rpm.Header.GetFiles()
+	require.NoError(t, err)
+	ghosts := map[string]rpmutils.FileInfo{}
+	for _, fi := range fileInfos {
+		if fi.Flags()&int(rpmpack.GhostFile) != 0 {
+			ghosts[fi.Name()] = fi
+		}
+	}
+	require.Len(t, ghosts, 2)
+	for name, target := range map[string]string{
+		"/usr/bin/java":          "/etc/alternatives/java",
+		"/etc/alternatives/java": "/usr/lib/jvm/java-17/bin/java",
+	} {
+		require.Contains(t, ghosts, name)
+		require.Equal(t, tagLink|0o777, ghosts[name].Mode(), name)
+		require.Equal(t, target, ghosts[name].Linkname(), name)
+	}
+}
+
+func TestRPMNumericOwnerWithoutName(t *testing.T) {
+	info := exampleInfo()
+	info.Contents = append(info.Contents, &files.Content{
+		Destination: "/var/lib/foo",
+		Type:        files.TypeDir,
+		FileInfo:    &files.ContentFileInfo{UID: 950, GID: 950},
+	})
+	require.EqualError(t, Default.Package(info, io.Discard), "/var/lib/foo/: rpm records owners and groups by name, uid and gid must come with owner and group")
+}
+
 func TestRPMChangelog(t *testing.T) {
 	info := exampleInfo()
 	info.Changelog = "../testdata/changelog.yaml"
@@ -845,6 +1244,125 @@ func TestRPMSignature(t *testing.T) {
 	require.Len(t, sigs, 2)
 }
 
+func TestRPMSignatureKeys(t *testing.T) {
+	pubkeyFileContent, err := os.ReadFile("../internal/sign/testdata/pubkey.gpg")
+	require.NoError(t, err)
+	keyring, err := openpgp.ReadKeyRing(bytes.NewReader(pubkeyFileContent))
+	require.NoError(t, err)
+
+	for name, packager := range map[string]nfpm.Packager{
+		"rpm":  Default,
+		"srpm": DefaultSRPM,
+	} {
+		t.Run(name, func(t *testing.T) {
+			info := exampleInfo()
+			info.RPM.Signature.KeyFile = "../internal/sign/testdata/privkey.asc"
+			info.RPM.Signature.KeyPassphrase = "hunter2"
+			info.RPM.Signature.Keys = []nfpm.PackageSignature{
+				{KeyFile: "../internal/sign/testdata/privkey_second.asc"},
+			}
+
+			var rpmBuffer bytes.Buffer
+			require.NoError(t, packager.Package(info, &rpmBuffer))
+
+			// the signing key fills the signature tags of older rpm versions
+			_, sigs, err := rpmutils.Verify(bytes.NewReader(rpmBuffer.Bytes()), keyring)
+			require.NoError(t, err)
+			require.Len(t, sigs, 2)
+
+			data := rpmBuffer.Bytes()
+			signatures, sigSize, err := readHeader(data[leadSize:])
+			require.NoError(t, err)
+			rest := data[leadSize+sigSize+(8-sigSize%8)%8:]
+			_, headerSize, err := readHeader(rest)
+			require.NoError(t, err)
+
+			entry, ok := signatures.entries[sigTagOpenPGP]
+			require.True(t, ok)
+			require.Equal(t, int32(2), entry.count)
+			encoded := strings.Split(strings.TrimSuffix(string(entry.data), "\x00"), "\x00")
+			require.Len(t, encoded, 2)
+			for i, pubKey := range []string{"pubkey.asc", "pubkey_second.asc"} {
+				sig, err := base64.StdEncoding.DecodeString(encoded[i])
+				require.NoError(t, err)
+				require.NoError(t, sign.PGPVerify(bytes.NewReader(rest[:headerSize]), sig, "../internal/sign/testdata/"+pubKey))
+			}
+		})
+	}
+}
+
+func TestRPMSignatureEdDSA(t *testing.T) {
+	entity, err := openpgp.NewEntity("nfpm", "", "nfpm@example.com", &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	require.NoError(t, err)
+	var privKey bytes.Buffer
+	require.NoError(t, entity.SerializePrivateWithoutSigning(&privKey, nil))
+	keyFile := filepath.Join(t.TempDir(), "key.gpg")
+	require.NoError(t, os.WriteFile(keyFile, privKey.Bytes(), 0o600))
+
+	info := exampleInfo()
+	info.RPM.Signature.KeyFile = keyFile
+
+	var rpmBuffer bytes.Buffer
+	require.NoError(t, Default.Package(info, &rpmBuffer))
+
+	_, sigs, err := rpmutils.Verify(bytes.NewReader(rpmBuffer.Bytes()), openpgp.EntityList{entity})
+	require.NoError(t, err)
+	require.Len(t, sigs, 2)
+
+	// like rpmsign, EdDSA signatures use the DSA and GPG tags
+	signatures, _, err := readHeader(rpmBuffer.Bytes()[leadSize:])
+	require.NoError(t, err)
+	for _, tag := range []int32{sigTagDSA, sigTagGPG} {
+		require.Contains(t, signatures.entries, tag)
+	}
+	for _, tag := range []int32{sigTagRSA, sigTagPGP} {
+		require.NotContains(t, signatures.entries, tag)
+	}
+}
+
+func TestRPMSign(t *testing.T) {
+	pubkeyFileContent, err := os.ReadFile("../internal/sign/testdata/pubkey.gpg")
+	require.NoError(t, err)
+
+	keyring, err := openpgp.ReadKeyRing(bytes.NewReader(pubkeyFileContent))
+	require.NoError(t, err)
+
+	for name, packager := range map[string]nfpm.Signer{
+		"rpm":  Default,
+		"srpm": DefaultSRPM,
+	} {
+		t.Run(name, func(t *testing.T) {
+			info := exampleInfo()
+
+			var rpmBuffer bytes.Buffer
+			require.NoError(t, packager.(nfpm.Packager).Package(info, &rpmBuffer))
+			require.ErrorIs(t, packager.Sign(info, bytes.NewReader(rpmBuffer.Bytes()), io.Discard), nfpm.ErrNoSigningKey)
+
+			info.RPM.Signature.KeyFile = "../internal/sign/testdata/privkey.asc"
+			info.RPM.Signature.KeyPassphrase = "hunter2"
+
+			var signed bytes.Buffer
+			require.NoError(t, packager.Sign(info, bytes.NewReader(rpmBuffer.Bytes()), &signed))
+
+			// signing again replaces the signatures
+			var resigned bytes.Buffer
+			require.NoError(t, packager.Sign(info, bytes.NewReader(signed.Bytes()), &resigned))
+
+			hdr, sigs, err := rpmutils.Verify(bytes.NewReader(resigned.Bytes()), keyring)
+			require.NoError(t, err)
+			require.Len(t, sigs, 2)
+			pkgName, err := hdr.GetString(rpmutils.NAME)
+			require.NoError(t, err)
+			require.Equal(t, info.Name, pkgName)
+		})
+	}
+
+	err = Default.Sign(&nfpm.Info{Overridables: nfpm.Overridables{RPM: nfpm.RPM{
+		Signature: nfpm.RPMSignature{PackageSignature: nfpm.PackageSignature{KeyFile: "../internal/sign/testdata/privkey.asc"}},
+	}}}, strings.NewReader("not an rpm"), io.Discard)
+	require.ErrorIs(t, err, errNotRPM)
+}
+
 func TestRPMSignatureError(t *testing.T) {
 	info := exampleInfo()
 	info.RPM.Signature.KeyFile = "../internal/sign/testdata/privkey.asc"
@@ -0,0 +1,131 @@
+package rpm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/goreleaser/nfpm/v2"
+)
+
+const (
+	leadSize = 96
+
+	sigTagDSA = 267
+	sigTagGPG = 1005
+	// sigTagOpenPGP holds the base64 encoded header signatures of all keys,
+	// rpm 6 and newer check it instead of the tags above.
+	sigTagOpenPGP = 278
+)
+
+var errNotRPM = errors.New("not an rpm package")
+
+// signatureTags returns the tags of the header and of the header with payload
+// signatures. Like rpmsign, only RSA signatures use the RSA and PGP tags, the
+// DSA and GPG tags hold the others, like ECDSA and EdDSA signatures.
+func signatureTags(signature []byte) (int32, int32) {
+	p, err := packet.Read(bytes.NewReader(signature))
+	if err != nil {
+		return sigTagRSA, sigTagPGP
+	}
+	if sig, ok := p.(*packet.Signature); ok {
+		switch sig.PubKeyAlgo {
+		case packet.PubKeyAlgoRSA, packet.PubKeyAlgoRSASignOnly:
+		default:
+			return sigTagDSA, sigTagGPG
+		}
+	}
+	return sigTagRSA, sigTagPGP
+}
+
+// Sign adds or replaces the signatures of an existing rpm package. The
+// header and payload are copied unchanged and signed like Package does.
+func (*RPM) Sign(info *nfpm.Info, r io.Reader, w io.Writer) error {
+	return signRPM(info, r, w)
+}
+
+// Sign adds or replaces the signatures of an existing source rpm package.
+func (*SRPM) Sign(info *nfpm.Info, r io.Reader, w io.Writer) error {
+	return signRPM(info, r, w)
+}
+
+func signRPM(info *nfpm.Info, r io.Reader, w io.Writer) error {
+	signers := pgpSigners(info)
+	if len(signers) == 0 {
+		return nfpm.ErrNoSigningKey
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if len(data) < leadSize || !bytes.Equal(data[:4], []byte{0xed, 0xab, 0xee, 0xdb}) {
+		return errNotRPM
+	}
+
+	signatures, sigSize, err := readHeader(data[leadSize:])
+	if err != nil {
+		return fmt.Errorf("cannot read signature header: %w", err)
+	}
+	// signatures are padded to 8-byte boundaries
+	rest := data[leadSize+sigSize+(8-sigSize%8)%8:]
+	_, headerSize, err := readHeader(rest)
+	if err != nil {
+		return fmt.Errorf("cannot read header: %w", err)
+	}
+
+	for _, tag := range []int32{sigTagRSA, sigTagPGP, sigTagDSA, sigTagGPG, sigTagOpenPGP} {
+		delete(signatures.entries, tag)
+	}
+	if err := addSignatures(signers, signatures, rest[:headerSize], rest[headerSize:]); err != nil {
+		return &nfpm.ErrSigningFailure{Err: err}
+	}
+
+	sigHeader := signatures.Bytes()
+	for _, b := range [][]byte{
+		data[:leadSize],
+		sigHeader,
+		make([]byte, (8-len(sigHeader)%8)%8),
+		rest,
+	} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addSignatures signs the header, and the header with the payload, with the
+// first signer for the signature tags that all rpm versions check. If there
+// are more signers, the header signatures of all of them are added to the
+// OpenPGP tag as well.
+func addSignatures(signers []func([]byte) ([]byte, error), sig *header, hb, payload []byte) error {
+	headerSig, err := signers[0](hb)
+	if err != nil {
+		return err
+	}
+	bodySig, err := signers[0](append(append([]byte{}, hb...), payload...))
+	if err != nil {
+		return err
+	}
+	headerTag, bodyTag := signatureTags(headerSig)
+	sig.addBinary(headerTag, headerSig)
+	sig.addBinary(bodyTag, bodySig)
+
+	if len(signers) == 1 {
+		return nil
+	}
+	openpgp := []string{base64.StdEncoding.EncodeToString(headerSig)}
+	for _, signer := range signers[1:] {
+		headerSig, err := signer(hb)
+		if err != nil {
+			return err
+		}
+		openpgp = append(openpgp, base64.StdEncoding.EncodeToString(headerSig))
+	}
+	sig.addStrings(sigTagOpenPGP, openpgp...)
+	return nil
+}
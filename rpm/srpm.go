@@ -0,0 +1,688 @@
+package rpm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/cavaliergopher/cpio"
+	"github.com/google/rpmpack"
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/goreleaser/nfpm/v2/internal/modtime"
+)
+
+// https://github.com/rpm-software-management/rpm/blob/master/include/rpm/rpmtag.h
+const (
+	tagI18NTable         = 100
+	tagName              = 1000
+	tagVersion           = 1001
+	tagRelease           = 1002
+	tagEpoch             = 1003
+	tagSummary           = 1004
+	tagDescription       = 1005
+	tagBuildTime         = 1006
+	tagBuildHost         = 1007
+	tagSize              = 1009
+	tagVendor            = 1011
+	tagLicense           = 1014
+	tagPackager          = 1015
+	tagGroup             = 1016
+	tagSource            = 1018
+	tagURL               = 1020
+	tagOS                = 1021
+	tagArch              = 1022
+	tagFileSizes         = 1028
+	tagFileModes         = 1030
+	tagFileRDevs         = 1033
+	tagFileMTimes        = 1034
+	tagFileDigests       = 1035
+	tagFileLinkTos       = 1036
+	tagFileFlags         = 1037
+	tagFileUserName      = 1039
+	tagFileGroupName     = 1040
+	tagFileVerifyFlags   = 1045
+	tagRequireFlags      = 1048
+	tagRequireName       = 1049
+	tagRequireVersion    = 1050
+	tagFileDevices       = 1095
+	tagFileINodes        = 1096
+	tagFileLangs         = 1097
+	tagSourcePackage     = 1106
+	tagDirIndexes        = 1116
+	tagBaseNames         = 1117
+	tagDirNames          = 1118
+	tagPayloadFormat     = 1124
+	tagPayloadCompressor = 1125
+	tagPayloadFlags      = 1126
+	tagFileDigestAlgo    = 5011
+	tagPayloadDigest     = 5092
+	tagPayloadDigestAlgo = 5093
+
+	sigTagRSA         = 268
+	sigTagSHA256      = 273
+	sigTagSize        = 1000
+	sigTagPGP         = 1002
+	sigTagPayloadSize = 1007
+
+	hashAlgoSHA256 = 8
+	// RPMSENSE_LESS | RPMSENSE_EQUAL | RPMSENSE_RPMLIB
+	senseRPMLib = 0x02 | 0x08 | 0x1000000
+)
+
+const srpmPackagerName = "srpm"
+
+// nolint: gochecknoinits
+func init() {
+	nfpm.RegisterPackager(srpmPackagerName, DefaultSRPM)
+}
+
+// DefaultSRPM source RPM packager.
+// nolint: gochecknoglobals
+var DefaultSRPM = &SRPM{}
+
+// SRPM is a source RPM packager implementation. The source RPM contains a
+// generated spec file and a tarball with the package contents.
+type SRPM struct{}
+
+// ConventionalFileName returns a file name according
+// to the conventions for source RPM packages.
+func (*SRPM) ConventionalFileName(info *nfpm.Info) string {
+	info = setDefaults(info)
+
+	// name-version-release.src.rpm
+	return fmt.Sprintf("%s-%s-%s.src.rpm", info.Name, formatVersion(info), info.Release)
+}
+
+// ConventionalExtension returns the file name conventionally used for source
+// RPM packages.
+func (*SRPM) ConventionalExtension() string {
+	return ".src.rpm"
+}
+
+type srpmFile struct {
+	name  string
+	body  []byte
+	flags rpmpack.FileType
+}
+
+// Package writes a new source RPM package to the given writer using the
+// given info.
+func (*SRPM) Package(info *nfpm.Info, w io.Writer) error {
+	info = setDefaults(info)
+
+	if err := nfpm.PrepareForPackager(withAlternativeGhosts(withCopyrightIfRequested(info)), srpmPackagerName); err != nil {
+		return err
+	}
+
+	sourceName := fmt.Sprintf("%s-%s.tar.gz", info.Name, formatVersion(info))
+	source, err := createSourceTarball(info)
+	if err != nil {
+		return err
+	}
+
+	var spec bytes.Buffer
+	if err := writeSpec(&spec, info, sourceName); err != nil {
+		return err
+	}
+
+	srcFiles := []srpmFile{
+		{name: info.Name + ".spec", body: spec.Bytes(), flags: rpmpack.SpecFile},
+		{name: sourceName, body: source},
+	}
+
+	payload, payloadSize, err := createSRPMPayload(srcFiles)
+	if err != nil {
+		return err
+	}
+
+	h, err := srpmHeader(info, srcFiles, payload)
+	if err != nil {
+		return err
+	}
+	hb := h.Bytes()
+
+	sig := newHeader(regionSignatures)
+	sig.addInt32(sigTagSize, int32(len(hb)+len(payload)))
+	sig.addString(sigTagSHA256, fmt.Sprintf("%x", sha256.Sum256(hb)))
+	sig.addInt32(sigTagPayloadSize, int32(payloadSize))
+	if err := signSRPM(info, sig, hb, payload); err != nil {
+		return err
+	}
+	sb := sig.Bytes()
+
+	for _, part := range [][]byte{
+		sourceLead(fmt.Sprintf("%s-%s-%s", info.Name, formatVersion(info), info.Release)),
+		sb,
+		// signatures are padded to 8-byte boundaries
+		make([]byte, (8-len(sb)%8)%8),
+		hb,
+		payload,
+	} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func signSRPM(info *nfpm.Info, sig *header, hb, payload []byte) error {
+	signers := pgpSigners(info)
+	if len(signers) == 0 {
+		return nil
+	}
+
+	if err := addSignatures(signers, sig, hb, payload); err != nil {
+		return fmt.Errorf("call to signer failed: %w", err)
+	}
+	return nil
+}
+
+// nolint: funlen
+func srpmHeader(info *nfpm.Info, srcFiles []srpmFile, payload []byte) (*header, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	mtime := modtime.Get(info.MTime)
+
+	h := newHeader(regionImmutable)
+	h.addString(tagI18NTable, "C")
+	h.addString(tagName, info.Name)
+	h.addString(tagVersion, formatVersion(info))
+	h.addString(tagRelease, info.Release)
+	if info.Epoch != "" {
+		epoch, err := strconv.ParseUint(info.Epoch, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		h.addInt32(tagEpoch, int32(epoch))
+	}
+	h.addString(tagSummary, specSummary(info))
+	h.addString(tagDescription, defaultTo(info.Description, specSummary(info)))
+	h.addInt32(tagBuildTime, int32(mtime.Unix()))
+	h.addString(tagBuildHost, hostname)
+	h.addString(tagLicense, defaultTo(info.License, "Unknown"))
+	h.addString(tagOS, info.Platform)
+	h.addString(tagArch, info.Arch)
+	if info.Vendor != "" {
+		h.addString(tagVendor, info.Vendor)
+	}
+	if packager := defaultTo(info.RPM.Packager, info.Maintainer); packager != "" {
+		h.addString(tagPackager, packager)
+	}
+	if info.RPM.Group != "" {
+		h.addString(tagGroup, info.RPM.Group)
+	}
+	if info.Homepage != "" {
+		h.addString(tagURL, info.Homepage)
+	}
+	h.addStrings(tagSource, srcFiles[1].name)
+	h.addInt32(tagSourcePackage, 1)
+	h.addStrings(tagRequireName, "rpmlib(CompressedFileNames)", "rpmlib(FileDigests)")
+	h.addStrings(tagRequireVersion, "3.0.4-1", "4.6.0-1")
+	h.addInt32(tagRequireFlags, senseRPMLib, senseRPMLib)
+
+	if info.Changelog != "" {
+		times, titles, changes, err := changelogEntries(info)
+		if err != nil {
+			return nil, err
+		}
+		if len(times) > 0 {
+			ts := make([]int32, len(times))
+			for i, t := range times {
+				ts[i] = int32(t)
+			}
+			h.addInt32(tagChangelogTime, ts...)
+			h.addStrings(tagChangelogName, titles...)
+			h.addStrings(tagChangelogText, changes...)
+		}
+	}
+
+	var (
+		size                                int32
+		sizes, mtimes, inodes, flags, vfy   []int32
+		modes, rdevs                        []uint16
+		names, digests, links, users, langs []string
+		dirIndexes                          []int32
+	)
+	for i, f := range srcFiles {
+		size += int32(len(f.body))
+		sizes = append(sizes, int32(len(f.body)))
+		modes = append(modes, 0o100644)
+		rdevs = append(rdevs, 0)
+		mtimes = append(mtimes, int32(mtime.Unix()))
+		digests = append(digests, fmt.Sprintf("%x", sha256.Sum256(f.body)))
+		links = append(links, "")
+		flags = append(flags, int32(f.flags))
+		users = append(users, "root")
+		vfy = append(vfy, -1)
+		inodes = append(inodes, int32(i+1))
+		langs = append(langs, "")
+		dirIndexes = append(dirIndexes, 0)
+		names = append(names, f.name)
+	}
+	h.addInt32(tagSize, size)
+	h.addInt32(tagFileSizes, sizes...)
+	h.addInt16(tagFileModes, modes...)
+	h.addInt16(tagFileRDevs, rdevs...)
+	h.addInt32(tagFileMTimes, mtimes...)
+	h.addStrings(tagFileDigests, digests...)
+	h.addStrings(tagFileLinkTos, links...)
+	h.addInt32(tagFileFlags, flags...)
+	h.addStrings(tagFileUserName, users...)
+	h.addStrings(tagFileGroupName, users...)
+	h.addInt32(tagFileVerifyFlags, vfy...)
+	h.addInt32(tagFileINodes, inodes...)
+	h.addStrings(tagFileLangs, langs...)
+	h.addInt32(tagDirIndexes, dirIndexes...)
+	h.addStrings(tagBaseNames, names...)
+	h.addStrings(tagDirNames, "")
+	h.addInt32(tagFileDigestAlgo, hashAlgoSHA256)
+	h.addString(tagPayloadFormat, "cpio")
+	h.addString(tagPayloadCompressor, "gzip")
+	h.addString(tagPayloadFlags, "9")
+	h.addStrings(tagPayloadDigest, fmt.Sprintf("%x", sha256.Sum256(payload)))
+	h.addInt32(tagPayloadDigestAlgo, hashAlgoSHA256)
+	return h, nil
+}
+
+// createSRPMPayload returns the gzip compressed cpio archive with the given
+// files and its uncompressed size.
+func createSRPMPayload(srcFiles []srpmFile) ([]byte, int, error) {
+	var cpioBuf bytes.Buffer
+	cw := cpio.NewWriter(&cpioBuf)
+	for i, f := range srcFiles {
+		if err := cw.WriteHeader(&cpio.Header{
+			Name:  f.name,
+			Mode:  cpio.FileMode(0o644) | cpio.TypeReg,
+			Size:  int64(len(f.body)),
+			Links: 1,
+			Inode: int64(i + 1),
+		}); err != nil {
+			return nil, 0, fmt.Errorf("failed to write payload file header: %w", err)
+		}
+		if _, err := cw.Write(f.body); err != nil {
+			return nil, 0, fmt.Errorf("failed to write payload file content: %w", err)
+		}
+	}
+	if err := cw.Close(); err != nil {
+		return nil, 0, fmt.Errorf("failed to close cpio payload: %w", err)
+	}
+
+	var buf bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := gz.Write(cpioBuf.Bytes()); err != nil {
+		return nil, 0, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, 0, fmt.Errorf("failed to close gzip payload: %w", err)
+	}
+	return buf.Bytes(), cpioBuf.Len(), nil
+}
+
+// createSourceTarball creates a tarball with all contents placed relative to
+// a name-version directory, as expected by %setup.
+func createSourceTarball(info *nfpm.Info) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	prefix := fmt.Sprintf("%s-%s", info.Name, formatVersion(info))
+	mtime := modtime.Get(info.MTime)
+
+	// hard links are added last as their targets must come first in the tar
+	contents := make(files.Contents, 0, len(info.Contents))
+	var hardlinks files.Contents
+	for _, content := range info.Contents {
+		if content.Type == files.TypeHardlink {
+			hardlinks = append(hardlinks, content)
+			continue
+		}
+		contents = append(contents, content)
+	}
+
+	for _, content := range append(contents, hardlinks...) {
+		rel := strings.Trim(files.AsRelativePath(content.Destination), "/")
+		// device nodes are declared with %dev in the spec file instead, as
+		// creating them requires root privileges
+		if rel == "" || content.Type == files.TypeRPMGhost ||
+			content.Type == files.TypeCharDevice || content.Type == files.TypeBlockDevice {
+			continue
+		}
+
+		header := &tar.Header{
+			Name:    path.Join(prefix, rel),
+			Mode:    int64(content.Mode() & 0o7777),
+			ModTime: modtime.Get(content.ModTime(), mtime),
+			Format:  tar.FormatPAX,
+		}
+		var body []byte
+		switch content.Type {
+		case files.TypeDir, files.TypeImplicitDir:
+			header.Typeflag = tar.TypeDir
+			header.Name += "/"
+		case files.TypeSymlink:
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = content.Source
+		case files.TypeFIFO:
+			header.Typeflag = tar.TypeFifo
+		case files.TypeHardlink:
+			header.Typeflag = tar.TypeLink
+			header.Linkname = path.Join(prefix, strings.Trim(files.AsRelativePath(content.Source), "/"))
+		case files.TypeCopyright:
+			data, err := info.FormatCopyright()
+			if err != nil {
+				return nil, err
+			}
+			body = data
+			header.Typeflag = tar.TypeReg
+			header.Size = int64(len(data))
+		default:
+			data, err := content.ReadData()
+			if err != nil {
+				return nil, err
+			}
+			body = data
+			header.Typeflag = tar.TypeReg
+			header.Size = int64(len(data))
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, fmt.Errorf("cannot write header of %s to source tarball: %w", header.Name, err)
+		}
+		if _, err := tw.Write(body); err != nil {
+			return nil, fmt.Errorf("cannot write %s to source tarball: %w", header.Name, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("closing source tarball: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("closing source tarball: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func specSummary(info *nfpm.Info) string {
+	return defaultTo(defaultTo(info.RPM.Summary, strings.Split(info.Description, "\n")[0]), info.Name)
+}
+
+const specTemplate = `%global debug_package %{nil}
+
+Name: {{ .Info.Name }}
+{{- with .Info.Epoch }}
+Epoch: {{ . }}
+{{- end }}
+Version: {{ .Version }}
+Release: {{ .Info.Release }}
+Summary: {{ escape .Summary }}
+License: {{ escape (or .Info.License "Unknown") }}
+{{- with .Info.Homepage }}
+URL: {{ escape . }}
+{{- end }}
+{{- with .Info.Vendor }}
+Vendor: {{ escape . }}
+{{- end }}
+{{- with .Packager }}
+Packager: {{ escape . }}
+{{- end }}
+{{- with .Info.RPM.Group }}
+Group: {{ escape . }}
+{{- end }}
+Source0: {{ .Source }}
+{{- if eq .Info.Arch "noarch" }}
+BuildArch: noarch
+{{- else }}
+ExclusiveArch: {{ .Info.Arch }}
+{{- end }}
+{{- range .Info.RPM.Prefixes }}
+Prefix: {{ . }}
+{{- end }}
+{{- range .Info.Depends }}
+Requires: {{ escape . }}
+{{- end }}
+{{- range .PreRequires }}
+Requires(pre): {{ escape . }}
+{{- end }}
+{{- if .Info.Alternatives }}
+Requires(post): {{ .UpdateAlternatives }}
+Requires(postun): {{ .UpdateAlternatives }}
+{{- end }}
+{{- range .Info.Provides }}
+Provides: {{ escape . }}
+{{- end }}
+{{- range .UserProvides }}
+Provides: {{ escape . }}
+{{- end }}
+{{- range .Info.Conflicts }}
+Conflicts: {{ escape . }}
+{{- end }}
+{{- range .Info.Replaces }}
+Obsoletes: {{ escape . }}
+{{- end }}
+{{- range .Info.Recommends }}
+Recommends: {{ escape . }}
+{{- end }}
+{{- range .Info.Suggests }}
+Suggests: {{ escape . }}
+{{- end }}
+
+%description
+{{ escape .Description }}
+
+%prep
+%setup -q
+
+%build
+
+%install
+mkdir -p %{buildroot}
+cp -a . %{buildroot}/
+{{- range .Ghosts }}
+mkdir -p "%{buildroot}{{ dir .Path }}"
+{{- if .Target }}
+ln -sfn "{{ .Target }}" "%{buildroot}{{ .Path }}"
+{{- else }}
+touch "%{buildroot}{{ .Path }}"
+{{- end }}
+{{- end }}
+{{- range .Scripts }}
+
+%{{ .Name }}
+{{ escape .Body }}
+{{- end }}
+
+%files
+{{- range .Files }}
+{{ . }}
+{{- end }}
+{{- with .Changelog }}
+
+%changelog
+{{- range . }}
+* {{ .Date }} {{ escape .Title }}
+{{ escape .Text }}
+{{- end }}
+{{- end }}
+`
+
+// specGhost is a %ghost file created in the build root, a symlink if it has
+// a target.
+type specGhost struct {
+	Path   string
+	Target string
+}
+
+type specScript struct {
+	Name string
+	Body string
+}
+
+type specChangelogEntry struct {
+	Date  string
+	Title string
+	Text  string
+}
+
+func writeSpec(w io.Writer, info *nfpm.Info, source string) error {
+	data := struct {
+		Info               *nfpm.Info
+		Version            string
+		Summary            string
+		Description        string
+		Packager           string
+		Source             string
+		Ghosts             []specGhost
+		Scripts            []specScript
+		Files              []string
+		Changelog          []specChangelogEntry
+		PreRequires        []string
+		UserProvides       []string
+		UpdateAlternatives string
+	}{
+		Info:               info,
+		PreRequires:        userPreRequires(info),
+		UserProvides:       userCapabilities(info),
+		UpdateAlternatives: updateAlternatives,
+		Version:            formatVersion(info),
+		Summary:            specSummary(info),
+		Description:        strings.TrimSpace(defaultTo(info.Description, specSummary(info))),
+		Packager:           defaultTo(info.RPM.Packager, info.Maintainer),
+		Source:             source,
+	}
+
+	for _, content := range info.Contents {
+		if err := checkOwnerNames(content); err != nil {
+			return err
+		}
+		if content.Type == files.TypeRPMGhost {
+			ghost := specGhost{Path: content.Destination}
+			if content.Mode()&os.ModeSymlink != 0 {
+				ghost.Target = content.Source
+			}
+			data.Ghosts = append(data.Ghosts, ghost)
+		}
+		if line := specFileLine(content); line != "" {
+			data.Files = append(data.Files, line)
+		}
+	}
+
+	for _, script := range []struct {
+		name, path, before, after string
+	}{
+		{"pretrans", info.RPM.Scripts.PreTrans, "", ""},
+		{"pre", info.Scripts.PreInstall, usersScript(info), ""},
+		{"post", info.Scripts.PostInstall, alternativesPost(info), systemdPost(info)},
+		{"preun", info.Scripts.PreRemove, systemdPreun(info), ""},
+		{"postun", info.Scripts.PostRemove, alternativesPostun(info), systemdPostun(info)},
+		{"posttrans", info.RPM.Scripts.PostTrans, "", ""},
+		{"verifyscript", info.RPM.Scripts.Verify, "", ""},
+	} {
+		body, err := nfpm.MergeScript(script.path, script.before, script.after)
+		if err != nil {
+			return err
+		}
+		if body == nil {
+			continue
+		}
+		data.Scripts = append(data.Scripts, specScript{
+			Name: script.name,
+			Body: strings.TrimSpace(string(body)),
+		})
+	}
+
+	if info.Changelog != "" {
+		times, titles, changes, err := changelogEntries(info)
+		if err != nil {
+			return err
+		}
+		for i := range times {
+			data.Changelog = append(data.Changelog, specChangelogEntry{
+				Date:  time.Unix(int64(times[i]), 0).UTC().Format("Mon Jan 02 2006"),
+				Title: titles[i],
+				Text:  changes[i],
+			})
+		}
+	}
+
+	tmpl := template.New("spec").Funcs(template.FuncMap{
+		"escape": func(s string) string {
+			return strings.ReplaceAll(s, "%", "%%")
+		},
+		"dir": path.Dir,
+	})
+	return template.Must(tmpl.Parse(specTemplate)).Execute(w, data)
+}
+
+// specFileLine returns the %files entry for the given content, using the
+// same file flags as the binary RPM.
+func specFileLine(content *files.Content) string {
+	var directives []string
+	switch content.Type {
+	case files.TypeImplicitDir:
+		return ""
+	case files.TypeDir:
+		directives = append(directives, "%dir")
+	case files.TypeCharDevice:
+		directives = append(directives, fmt.Sprintf("%%dev(c, %d, %d)", content.FileInfo.DevMajor, content.FileInfo.DevMinor))
+	case files.TypeBlockDevice:
+		directives = append(directives, fmt.Sprintf("%%dev(b, %d, %d)", content.FileInfo.DevMajor, content.FileInfo.DevMinor))
+	}
+
+	ft := fileType(content)
+	if ft&rpmpack.ConfigFile != 0 {
+		switch {
+		case ft&rpmpack.NoReplaceFile != 0:
+			directives = append(directives, "%config(noreplace)")
+		case ft&rpmpack.MissingOkFile != 0:
+			directives = append(directives, "%config(missingok)")
+		default:
+			directives = append(directives, "%config")
+		}
+	}
+	for _, flag := range []struct {
+		fileType  rpmpack.FileType
+		directive string
+	}{
+		{rpmpack.GhostFile, "%ghost"},
+		{rpmpack.DocFile, "%doc"},
+		{rpmpack.LicenceFile, "%license"},
+		{rpmpack.ReadmeFile, "%readme"},
+	} {
+		if ft&flag.fileType != 0 {
+			directives = append(directives, flag.directive)
+		}
+	}
+
+	mode := fmt.Sprintf("%04o", content.Mode()&0o7777)
+	if content.Type == files.TypeSymlink || content.Mode()&os.ModeSymlink != 0 {
+		mode = "-"
+	} else if content.Type == files.TypeRPMGhost && content.Mode()&0o7777 == 0 {
+		mode = "0644"
+	}
+	directives = append(directives, fmt.Sprintf(
+		"%%attr(%s, %s, %s)",
+		mode,
+		defaultTo(content.FileInfo.Owner, "root"),
+		defaultTo(content.FileInfo.Group, "root"),
+	))
+
+	dst := strings.ReplaceAll(path.Clean(files.ToNixPath(content.Destination)), "%", "%%")
+	if strings.ContainsAny(dst, " \t") {
+		dst = `"` + dst + `"`
+	}
+	return strings.Join(append(directives, dst), " ")
+}
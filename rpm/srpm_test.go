@@ -0,0 +1,251 @@
+package rpm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/google/rpmpack"
+	"github.com/goreleaser/nfpm/v2"
+	"github.com/goreleaser/nfpm/v2/files"
+	"github.com/sassoftware/go-rpmutils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSRPMConventionalFileName(t *testing.T) {
+	info := exampleInfo()
+	info.Prerelease = "rc1"
+	require.Equal(t, "foo-1.0.0~rc1-1.src.rpm", DefaultSRPM.ConventionalFileName(info))
+	require.Equal(t, ".src.rpm", DefaultSRPM.ConventionalExtension())
+}
+
+func readSRPM(tb testing.TB, srpm []byte) (*rpmutils.Rpm, map[string][]byte) {
+	tb.Helper()
+
+	require.Equal(tb, []byte{0, 1}, srpm[6:8], "lead must be of type source")
+
+	rpm, err := rpmutils.ReadRpm(bytes.NewReader(srpm))
+	require.NoError(tb, err)
+
+	payload, err := rpm.PayloadReaderExtended()
+	require.NoError(tb, err)
+	contents := map[string][]byte{}
+	for {
+		fi, err := payload.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(tb, err)
+		data, err := io.ReadAll(payload)
+		require.NoError(tb, err)
+		contents[fi.Name()] = data
+	}
+	return rpm, contents
+}
+
+func TestSRPM(t *testing.T) {
+	info := exampleInfo()
+	info.Changelog = "../testdata/changelog.yaml"
+	info.Scripts.PostInstall = "../testdata/scripts/postinstall.sh"
+	info.Contents = append(info.Contents, &files.Content{
+		Destination: "/var/log/foo.log",
+		Type:        files.TypeRPMGhost,
+	})
+	info.Copyright.Files = []nfpm.CopyrightFiles{{
+		Paths:     []string{"*"},
+		Copyright: []string{"2023 Carlos A Becker"},
+		License:   "MIT",
+	}}
+
+	var buf bytes.Buffer
+	require.NoError(t, DefaultSRPM.Package(info, &buf))
+
+	rpm, contents := readSRPM(t, buf.Bytes())
+	require.False(t, rpm.Header.HasTag(rpmutils.SOURCERPM))
+	sourcePackage, err := rpm.Header.GetInts(tagSourcePackage)
+	require.NoError(t, err)
+	require.Equal(t, []int{1}, sourcePackage)
+	name, err := rpm.Header.GetString(rpmutils.NAME)
+	require.NoError(t, err)
+	require.Equal(t, "foo", name)
+	changelogNames, err := rpm.Header.GetStrings(rpmutils.CHANGELOGNAME)
+	require.NoError(t, err)
+	require.Len(t, changelogNames, 2)
+
+	fileInfos, err := rpm.Header.GetFiles()
+	require.NoError(t, err)
+	require.Len(t, fileInfos, 2)
+	require.Equal(t, "foo.spec", fileInfos[0].Name())
+	require.Equal(t, int(rpmpack.SpecFile), fileInfos[0].Flags())
+	require.Equal(t, "foo-1.0.0.tar.gz", fileInfos[1].Name())
+
+	spec := string(contents["foo.spec"])
+	for _, s := range []string{
+		"Name: foo\n",
+		"Version: 1.0.0\n",
+		"Release: 1\n",
+		"Source0: foo-1.0.0.tar.gz\n",
+		"ExclusiveArch: x86_64\n",
+		"Requires: bash\n",
+		"Obsoletes: svn\n",
+		"\n%post\n",
+		"%dir %attr(0755, root, root) /var/log/whatever\n",
+		"%ghost %attr(0644, root, root) /var/log/foo.log\n",
+		"%license %attr(0644, root, root) /usr/share/licenses/foo/copyright\n",
+		"touch \"%{buildroot}/var/log/foo.log\"\n",
+		"\n%changelog\n* Tue Dec 08 2009 Carlos A Becker <pkg@carlosbecker.com> - 1.1.0-1\n- note 1\n- note 2\n",
+	} {
+		require.Contains(t, spec, s)
+	}
+	require.Regexp(t, `\n%attr\(0[0-7]{3}, root, root\) /usr/bin/fake\n`, spec)
+	require.Regexp(t, `\n%config %attr\(0[0-7]{3}, root, root\) /etc/fake/fake.conf\n`, spec)
+	require.Contains(t, spec, "%dir %attr(0755, root, root) /usr/share/whatever\n")
+	require.NotContains(t, spec, "%dir %attr(0755, root, root) /usr/share\n")
+
+	gz, err := gzip.NewReader(bytes.NewReader(contents["foo-1.0.0.tar.gz"]))
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+		if hdr.Name == "foo-1.0.0/usr/bin/fake" {
+			data, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			expected, err := os.ReadFile("../testdata/fake")
+			require.NoError(t, err)
+			require.Equal(t, expected, data)
+		}
+	}
+	require.Contains(t, names, "foo-1.0.0/usr/bin/fake")
+	require.Contains(t, names, "foo-1.0.0/usr/share/licenses/foo/copyright")
+	require.Contains(t, names, "foo-1.0.0/var/log/whatever/")
+	require.NotContains(t, names, "foo-1.0.0/var/log/foo.log")
+}
+
+func TestSRPMUsers(t *testing.T) {
+	info := exampleInfo()
+	info.Users = []nfpm.User{{Name: "foo", UID: 950}}
+	info.Contents = append(info.Contents, &files.Content{
+		Destination: "/var/lib/foo",
+		Type:        files.TypeDir,
+		FileInfo:    &files.ContentFileInfo{Owner: "foo", Group: "foo"},
+	})
+
+	var buf bytes.Buffer
+	require.NoError(t, DefaultSRPM.Package(info, &buf))
+
+	_, contents := readSRPM(t, buf.Bytes())
+	spec := string(contents["foo.spec"])
+	for _, s := range []string{
+		"Requires(pre): user(foo)\n",
+		"Requires(pre): group(foo)\n",
+		"Requires(pre): /usr/sbin/useradd\n",
+		"Provides: user(foo)\n",
+		"Provides: group(foo)\n",
+		"\n%pre\n#!/bin/bash\ngetent group 'foo' >/dev/null || groupadd -r -g 950 'foo'\n" +
+			"getent passwd 'foo' >/dev/null || useradd -r -u 950 -g 'foo' 'foo'\n",
+		"%dir %attr(0755, foo, foo) /var/lib/foo\n",
+		"%attr(0644, root, root) /usr/lib/sysusers.d/foo.conf\n",
+	} {
+		require.Contains(t, spec, s)
+	}
+}
+
+func TestSRPMAlternatives(t *testing.T) {
+	info := exampleInfo()
+	info.Alternatives = []nfpm.Alternative{
+		{LinkName: "/usr/bin/java", Target: "/usr/lib/jvm/java-17/bin/java", Priority: 1700},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, DefaultSRPM.Package(info, &buf))
+
+	_, contents := readSRPM(t, buf.Bytes())
+	spec := string(contents["foo.spec"])
+	for _, s := range []string{
+		"ln -sfn \"/etc/alternatives/java\" \"%{buildroot}/usr/bin/java\"\n",
+		"ln -sfn \"/usr/lib/jvm/java-17/bin/java\" \"%{buildroot}/etc/alternatives/java\"\n",
+		"%ghost %attr(-, root, root) /usr/bin/java\n",
+		"%ghost %attr(-, root, root) /etc/alternatives/java\n",
+	} {
+		require.Contains(t, spec, s)
+	}
+}
+
+func TestSRPMSignature(t *testing.T) {
+	info := exampleInfo()
+	info.RPM.Signature.KeyFile = "../internal/sign/testdata/privkey.asc"
+	info.RPM.Signature.KeyPassphrase = "hunter2"
+
+	pubkeyFileContent, err := os.ReadFile("../internal/sign/testdata/pubkey.gpg")
+	require.NoError(t, err)
+	keyring, err := openpgp.ReadKeyRing(bytes.NewReader(pubkeyFileContent))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, DefaultSRPM.Package(info, &buf))
+
+	_, sigs, err := rpmutils.Verify(bytes.NewReader(buf.Bytes()), keyring)
+	require.NoError(t, err)
+	require.Len(t, sigs, 2)
+}
+
+func TestSpecFileLine(t *testing.T) {
+	for expected, content := range map[string]*files.Content{
+		"%config(noreplace) %attr(0600, root, adm) /etc/foo.conf": {
+			Destination: "/etc/foo.conf",
+			Type:        files.TypeConfigNoReplace,
+			FileInfo:    &files.ContentFileInfo{Mode: 0o600, Group: "adm"},
+		},
+		"%config(missingok) %attr(0644, root, root) /etc/bar.conf": {
+			Destination: "/etc/bar.conf",
+			Type:        files.TypeConfigMissingOK,
+			FileInfo:    &files.ContentFileInfo{Mode: 0o644},
+		},
+		"%doc %attr(0644, root, root) /usr/share/doc/foo/README": {
+			Destination: "/usr/share/doc/foo/README",
+			Type:        files.TypeRPMDoc,
+			FileInfo:    &files.ContentFileInfo{Mode: 0o644},
+		},
+		"%license %attr(0644, root, root) /usr/share/licenses/foo/LICENSE": {
+			Destination: "/usr/share/licenses/foo/LICENSE",
+			Type:        files.TypeRPMLicense,
+			FileInfo:    &files.ContentFileInfo{Mode: 0o644},
+		},
+		"%attr(-, root, root) /usr/bin/bar": {
+			Destination: "/usr/bin/bar",
+			Type:        files.TypeSymlink,
+			FileInfo:    &files.ContentFileInfo{},
+		},
+		`%attr(0644, root, root) "/usr/share/foo/with space 100%%"`: {
+			Destination: "/usr/share/foo/with space 100%",
+			FileInfo:    &files.ContentFileInfo{Mode: 0o644},
+		},
+		"": {
+			Destination: "/usr/share/foo/",
+			Type:        files.TypeImplicitDir,
+		},
+		"%dev(c, 1, 3) %attr(0666, root, root) /dev/null": {
+			Destination: "/dev/null",
+			Type:        files.TypeCharDevice,
+			FileInfo:    &files.ContentFileInfo{Mode: 0o666, DevMajor: 1, DevMinor: 3},
+		},
+		"%dev(b, 7, 0) %attr(0660, root, disk) /dev/loop0": {
+			Destination: "/dev/loop0",
+			Type:        files.TypeBlockDevice,
+			FileInfo:    &files.ContentFileInfo{Mode: 0o660, Group: "disk", DevMajor: 7},
+		},
+	} {
+		require.Equal(t, expected, specFileLine(content))
+	}
+}